@@ -1,6 +1,9 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
@@ -15,9 +18,15 @@ import (
 	basicmodel "github.com/oneclickvirt/basics/model"
 	cputestmodel "github.com/oneclickvirt/cputest/model"
 	disktestmodel "github.com/oneclickvirt/disktest/disk"
+	"github.com/oneclickvirt/ecs/internal/cleanup"
+	"github.com/oneclickvirt/ecs/internal/collector"
+	"github.com/oneclickvirt/ecs/internal/iperf"
 	menu "github.com/oneclickvirt/ecs/internal/menu"
 	params "github.com/oneclickvirt/ecs/internal/params"
+	"github.com/oneclickvirt/ecs/internal/report"
 	"github.com/oneclickvirt/ecs/internal/runner"
+	"github.com/oneclickvirt/ecs/internal/schedule"
+	"github.com/oneclickvirt/ecs/internal/server"
 	"github.com/oneclickvirt/ecs/utils"
 	gostunmodel "github.com/oneclickvirt/gostun/model"
 	memorytestmodel "github.com/oneclickvirt/memorytest/memory"
@@ -47,17 +56,38 @@ func initLogger() {
 	}
 }
 
-func handleLanguageSpecificSettings() {
-	if configs.Language == "en" {
-		configs.BacktraceStatus = false
-		configs.Nt3Status = false
+// runSubcommand 处理子命令，返回是否已处理
+func runSubcommand(args []string) bool {
+	if len(args) == 0 {
+		return false
 	}
-	if !configs.EnableUpload {
-		configs.SecurityTestStatus = false
+	var err error
+	switch args[0] {
+	case "serve":
+		err = server.Main(args[1:], ecsVersion)
+	case "collector":
+		err = collector.Main(args[1:], ecsVersion)
+	case "schedule":
+		err = schedule.Main(args[1:], ecsVersion)
+	case "iperf-server":
+		err = iperf.Main(args[1:], ecsVersion)
+	default:
+		return false
+	}
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintln(os.Stderr, err)
+		cleanup.Run()
+		os.Exit(1)
 	}
+	return true
 }
 
 func main() {
+	// 正常退出或 panic 时删除仍登记的临时测试文件
+	defer cleanup.Run()
+	if runSubcommand(os.Args[1:]) {
+		return
+	}
 	configs.ParseFlags(os.Args[1:])
 	if configs.HandleHelpAndVersion("goecs") {
 		return
@@ -73,32 +103,28 @@ func main() {
 		menu.HandleMenuMode(preCheck, configs)
 	} else {
 		configs.OnlyIpInfoCheck = true
+		// 菜单模式在 RestoreUserSetParams 中校验参数
+		configs.ValidateParams()
 	}
-	handleLanguageSpecificSettings()
+	configs.HandleLanguageSpecificSettings()
 	if !preCheck.Connected {
 		configs.EnableUpload = false
 	}
 	var (
-		wg1, wg2, wg3                                         sync.WaitGroup
-		basicInfo, securityInfo, emailInfo, mediaInfo, ptInfo string
-		output, tempOutput                                    string
-		outputMutex                                           sync.Mutex
-		infoMutex                                             sync.Mutex // 保护并发字符串写入
+		output, tempOutput string
+		outputMutex        sync.Mutex
 	)
 	startTime := time.Now()
 	uploadDone := make(chan bool, 1)
 	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 	go runner.HandleSignalInterrupt(sig, configs, &startTime, &output, tempOutput, uploadDone, &outputMutex)
-	switch configs.Language {
-	case "zh":
-		runner.RunChineseTests(preCheck, configs, &wg1, &wg2, &wg3, &basicInfo, &securityInfo, &emailInfo, &mediaInfo, &ptInfo, &output, tempOutput, startTime, &outputMutex, &infoMutex)
-	case "en":
-		runner.RunEnglishTests(preCheck, configs, &wg1, &wg2, &wg3, &basicInfo, &securityInfo, &emailInfo, &mediaInfo, &ptInfo, &output, tempOutput, startTime, &outputMutex, &infoMutex)
-	default:
-		fmt.Println("Unsupported language")
-	}
-	if preCheck.Connected {
+	rep := report.New(configs.EcsVersion, configs.Language, startTime)
+	runner.RunTests(context.Background(), preCheck, configs, rep, &output, tempOutput, startTime, &outputMutex)
+	rep.Finish()
+	if configs.ReportTo != "" {
+		runner.HandleReportResults(configs, output, rep)
+	} else if preCheck.Connected {
 		runner.HandleUploadResults(configs, output)
 	}
 	configs.Finish = true
@@ -0,0 +1,389 @@
+// Package blockdev identifies the block devices behind a disk test path from sysfs
+package blockdev
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/oneclickvirt/ecs/internal/diskpath"
+)
+
+// sysRoot is the sysfs mount point, replaced in tests
+var sysRoot = "/sys"
+
+// maxDepth bounds the walk through stacked devices
+const maxDepth = 8
+
+// Layer is one virtual layer between the filesystem and the disks, such as lvm, raid1, crypt or overlay
+type Layer struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// Health is the state the kernel exposes for a disk in sysfs. It is not a SMART or NVMe health log check,
+// those need root and vendor tools, so wear, reallocated sectors and media errors are not covered
+type Health struct {
+	State       string  `json:"state,omitempty"`
+	Temperature float64 `json:"temperature_celsius,omitempty"`
+	// IOErrors is nil when the driver does not count errors
+	IOErrors *int64 `json:"io_errors,omitempty"`
+}
+
+// Disk is a physical or virtual disk at the bottom of the stack
+type Disk struct {
+	Name        string `json:"name"`
+	Kind        string `json:"kind,omitempty"` // NVMe、SSD 或 HDD
+	Bus         string `json:"bus,omitempty"`
+	Model       string `json:"model,omitempty"`
+	Firmware    string `json:"firmware,omitempty"`
+	SizeBytes   uint64 `json:"size_bytes,omitempty"`
+	Scheduler   string `json:"scheduler,omitempty"`
+	QueueDepth  int    `json:"queue_depth,omitempty"`  // 块层请求队列长度 nr_requests
+	DeviceDepth int    `json:"device_depth,omitempty"` // SCSI 设备队列深度
+	ReadAheadKB int    `json:"read_ahead_kb,omitempty"`
+	Health      Health `json:"health"`
+}
+
+// Info is the storage stack behind one test path
+type Info struct {
+	Path   string  `json:"path"`
+	FSType string  `json:"fs_type,omitempty"`
+	Layers []Layer `json:"layers,omitempty"`
+	Disks  []Disk  `json:"disks,omitempty"`
+}
+
+// Identify walks from the mount of a test path down to its disks,
+// the result has no disks when sysfs is unavailable or the filesystem has no block device
+func Identify(mount diskpath.Info) *Info {
+	info := &Info{Path: mount.Path, FSType: mount.FSType}
+	switch mount.FSType {
+	case "overlay":
+		info.addLayer("overlay", mount.Device)
+		// 容器内以 upperdir 所在的挂载为准，写入都落在该层
+		for _, option := range mount.Options {
+			if upper, ok := strings.CutPrefix(option, "upperdir="); ok {
+				lower := diskpath.Lookup(upper)
+				if lower.FSType == "overlay" {
+					break
+				}
+				mount = lower
+				info.FSType = lower.FSType
+			}
+		}
+	case "zfs":
+		// ZFS 的 vdev 不在 sysfs 中体现，只记录存储池
+		pool, _, _ := strings.Cut(mount.Device, "/")
+		info.addLayer("zfs", pool)
+		return info
+	}
+	name := deviceName(mount)
+	if name == "" {
+		return info
+	}
+	seen := make(map[string]bool)
+	info.walk(name, 0, seen)
+	sort.SliceStable(info.Disks, func(i, j int) bool { return info.Disks[i].Name < info.Disks[j].Name })
+	return info
+}
+
+// deviceName finds the sysfs name of the block device holding the mount
+func deviceName(mount diskpath.Info) string {
+	// 优先按 st_dev 查找，可解析 /dev/root 这类不存在的设备节点
+	if number := deviceNumber(mount.Path); number != "" {
+		if real, err := filepath.EvalSymlinks(filepath.Join(sysRoot, "dev/block", number)); err == nil {
+			return filepath.Base(real)
+		}
+	}
+	if !strings.HasPrefix(mount.Device, "/dev/") {
+		return ""
+	}
+	device := mount.Device
+	if real, err := filepath.EvalSymlinks(device); err == nil {
+		device = real
+	}
+	name := filepath.Base(device)
+	if _, err := os.Stat(filepath.Join(sysRoot, "class/block", name)); err != nil {
+		return ""
+	}
+	return name
+}
+
+func (info *Info) addLayer(kind, name string) {
+	for _, layer := range info.Layers {
+		if layer.Kind == kind && layer.Name == name {
+			return
+		}
+	}
+	info.Layers = append(info.Layers, Layer{Kind: kind, Name: name})
+}
+
+// walk descends through partitions, device-mapper, md and loop devices to the disks below name
+func (info *Info) walk(name string, depth int, seen map[string]bool) {
+	if depth > maxDepth || seen[name] {
+		return
+	}
+	seen[name] = true
+	dir := filepath.Join(sysRoot, "class/block", name)
+	if _, err := os.Stat(filepath.Join(dir, "partition")); err == nil {
+		// 分区目录位于整盘目录之下
+		if real, err := filepath.EvalSymlinks(dir); err == nil {
+			info.walk(filepath.Base(filepath.Dir(real)), depth+1, seen)
+		}
+		return
+	}
+	if uuid, err := readTrimmed(filepath.Join(dir, "dm/uuid")); err == nil {
+		kind := "dm"
+		switch {
+		case strings.HasPrefix(uuid, "LVM-"):
+			kind = "lvm"
+		case strings.HasPrefix(uuid, "CRYPT-"):
+			kind = "crypt"
+		case strings.HasPrefix(uuid, "mpath-"):
+			kind = "multipath"
+		}
+		dmName, _ := readTrimmed(filepath.Join(dir, "dm/name"))
+		if dmName == "" {
+			dmName = name
+		}
+		info.addLayer(kind, dmName)
+		info.walkSlaves(dir, depth, seen)
+		return
+	}
+	if level, err := readTrimmed(filepath.Join(dir, "md/level")); err == nil {
+		info.addLayer(level, name)
+		info.walkSlaves(dir, depth, seen)
+		return
+	}
+	if backing, err := readTrimmed(filepath.Join(dir, "loop/backing_file")); err == nil {
+		info.addLayer("loop", backing)
+		return
+	}
+	info.Disks = append(info.Disks, describe(name))
+}
+
+func (info *Info) walkSlaves(dir string, depth int, seen map[string]bool) {
+	slaves, _ := filepath.Glob(filepath.Join(dir, "slaves/*"))
+	for _, slave := range slaves {
+		info.walk(filepath.Base(slave), depth+1, seen)
+	}
+}
+
+// describe reads the identity, queue settings and health of a whole disk
+func describe(name string) Disk {
+	dir := filepath.Join(sysRoot, "class/block", name)
+	disk := Disk{Name: name}
+	real, _ := filepath.EvalSymlinks(dir)
+	disk.Bus = bus(name, real)
+	rotational, _ := readTrimmed(filepath.Join(dir, "queue/rotational"))
+	switch {
+	case strings.HasPrefix(name, "nvme"):
+		disk.Kind = "NVMe"
+	case rotational == "0":
+		disk.Kind = "SSD"
+	case rotational == "1":
+		disk.Kind = "HDD"
+	}
+	vendor, _ := readTrimmed(filepath.Join(dir, "device/vendor"))
+	model, _ := readTrimmed(filepath.Join(dir, "device/model"))
+	// SCSI 厂商字段常为 ATA 或填充空格，无参考意义
+	if vendor != "" && vendor != "ATA" && !strings.HasPrefix(vendor, "0x") && !strings.HasPrefix(model, vendor) {
+		model = strings.TrimSpace(vendor + " " + model)
+	}
+	disk.Model = model
+	disk.Firmware, _ = readTrimmed(filepath.Join(dir, "device/firmware_rev"))
+	if disk.Firmware == "" {
+		disk.Firmware, _ = readTrimmed(filepath.Join(dir, "device/rev"))
+	}
+	if sectors, err := readInt(filepath.Join(dir, "size")); err == nil {
+		// sysfs 中的 size 总以 512 字节扇区计
+		disk.SizeBytes = uint64(sectors) * 512
+	}
+	if scheduler, err := readTrimmed(filepath.Join(dir, "queue/scheduler")); err == nil {
+		disk.Scheduler = activeScheduler(scheduler)
+	}
+	if depth, err := readInt(filepath.Join(dir, "queue/nr_requests")); err == nil {
+		disk.QueueDepth = int(depth)
+	}
+	if depth, err := readInt(filepath.Join(dir, "device/queue_depth")); err == nil {
+		disk.DeviceDepth = int(depth)
+	}
+	if readAhead, err := readInt(filepath.Join(dir, "queue/read_ahead_kb")); err == nil {
+		disk.ReadAheadKB = int(readAhead)
+	}
+	disk.Health = health(dir)
+	return disk
+}
+
+// bus guesses the transport from the device path in sysfs
+func bus(name, real string) string {
+	switch {
+	case strings.Contains(real, "/nvme"):
+		return "nvme"
+	case strings.Contains(real, "/virtio"):
+		if strings.HasPrefix(name, "sd") {
+			return "virtio-scsi"
+		}
+		return "virtio"
+	case strings.Contains(real, "/xen") || strings.Contains(real, "/vbd-") || strings.HasPrefix(name, "xvd"):
+		return "xen"
+	case strings.Contains(real, "/usb"):
+		return "usb"
+	case strings.Contains(real, "/mmc") || strings.HasPrefix(name, "mmcblk"):
+		return "mmc"
+	case strings.Contains(real, "/ata"):
+		return "sata"
+	case strings.HasPrefix(name, "vd"):
+		return "virtio"
+	case strings.HasPrefix(name, "sd"):
+		return "scsi"
+	}
+	return ""
+}
+
+// activeScheduler picks the bracketed entry of queue/scheduler, such as "none [mq-deadline] kyber"
+func activeScheduler(text string) string {
+	if start := strings.Index(text, "["); start >= 0 {
+		if end := strings.Index(text[start:], "]"); end > 0 {
+			return text[start+1 : start+end]
+		}
+	}
+	return text
+}
+
+// health reads the device state, hwmon temperature and the SCSI I/O error counter
+func health(dir string) Health {
+	var result Health
+	result.State, _ = readTrimmed(filepath.Join(dir, "device/state"))
+	// NVMe 与 drivetemp 驱动通过 hwmon 暴露温度，单位为千分之一摄氏度
+	if inputs, _ := filepath.Glob(filepath.Join(dir, "device/hwmon/hwmon*/temp1_input")); len(inputs) > 0 {
+		if milli, err := readInt(inputs[0]); err == nil {
+			result.Temperature = float64(milli) / 1000
+		}
+	}
+	if text, err := readTrimmed(filepath.Join(dir, "device/ioerr_cnt")); err == nil {
+		if count, err := strconv.ParseInt(text, 0, 64); err == nil {
+			result.IOErrors = &count
+		}
+	}
+	return result
+}
+
+func readTrimmed(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func readInt(path string) (int64, error) {
+	text, err := readTrimmed(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(text, 10, 64)
+}
+
+// Format renders the layers, disks and kernel reported disk state of the path
+func (info *Info) Format(language string) string {
+	if info == nil {
+		return ""
+	}
+	var builder strings.Builder
+	zh := language == "zh"
+	if len(info.Layers) > 0 {
+		stack := []string{info.FSType}
+		for _, layer := range info.Layers {
+			stack = append(stack, layer.Kind+" "+layer.Name)
+		}
+		label := "Layers"
+		if zh {
+			label = "存储层次"
+		}
+		builder.WriteString(fmt.Sprintf("[%s] %s %s\n", info.Path, label, strings.Join(stack, " -> ")))
+	}
+	for _, disk := range info.Disks {
+		var parts []string
+		kind := strings.TrimSpace(disk.Kind + " " + disk.Bus)
+		if kind != "" {
+			parts = append(parts, kind)
+		}
+		if disk.Model != "" {
+			parts = append(parts, disk.Model)
+		}
+		if disk.SizeBytes > 0 {
+			parts = append(parts, diskpath.FormatBytes(disk.SizeBytes))
+		}
+		if disk.Scheduler != "" {
+			if zh {
+				parts = append(parts, "调度器 "+disk.Scheduler)
+			} else {
+				parts = append(parts, "scheduler "+disk.Scheduler)
+			}
+		}
+		if disk.QueueDepth > 0 {
+			if zh {
+				parts = append(parts, fmt.Sprintf("队列长度 %d", disk.QueueDepth))
+			} else {
+				parts = append(parts, fmt.Sprintf("queue depth %d", disk.QueueDepth))
+			}
+		}
+		if disk.DeviceDepth > 0 {
+			if zh {
+				parts = append(parts, fmt.Sprintf("设备队列深度 %d", disk.DeviceDepth))
+			} else {
+				parts = append(parts, fmt.Sprintf("device queue depth %d", disk.DeviceDepth))
+			}
+		}
+		if disk.ReadAheadKB > 0 {
+			if zh {
+				parts = append(parts, fmt.Sprintf("预读 %d KB", disk.ReadAheadKB))
+			} else {
+				parts = append(parts, fmt.Sprintf("read-ahead %d KB", disk.ReadAheadKB))
+			}
+		}
+		if zh {
+			builder.WriteString(fmt.Sprintf("[%s] 硬盘 %s %s\n", info.Path, disk.Name, strings.Join(parts, ", ")))
+		} else {
+			builder.WriteString(fmt.Sprintf("[%s] Disk %s %s\n", info.Path, disk.Name, strings.Join(parts, ", ")))
+		}
+		if line := disk.Health.format(zh); line != "" {
+			if zh {
+				builder.WriteString(fmt.Sprintf("[%s] 内核状态 %s %s (非 SMART 检测)\n", info.Path, disk.Name, line))
+			} else {
+				builder.WriteString(fmt.Sprintf("[%s] Kernel status %s %s (not a SMART check)\n", info.Path, disk.Name, line))
+			}
+		}
+	}
+	return builder.String()
+}
+
+func (h Health) format(zh bool) string {
+	var parts []string
+	if h.State != "" {
+		if zh {
+			parts = append(parts, "状态 "+h.State)
+		} else {
+			parts = append(parts, "state "+h.State)
+		}
+	}
+	if h.Temperature > 0 {
+		if zh {
+			parts = append(parts, fmt.Sprintf("温度 %.0f°C", h.Temperature))
+		} else {
+			parts = append(parts, fmt.Sprintf("temperature %.0f°C", h.Temperature))
+		}
+	}
+	if h.IOErrors != nil {
+		if zh {
+			parts = append(parts, fmt.Sprintf("IO 错误 %d 次", *h.IOErrors))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d I/O errors", *h.IOErrors))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
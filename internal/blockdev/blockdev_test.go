@@ -0,0 +1,75 @@
+package blockdev
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/oneclickvirt/ecs/internal/diskpath"
+	"github.com/oneclickvirt/ecs/internal/testutil"
+)
+
+// link adds /sys/class/block/name pointing at the device directory, as the kernel does
+func link(t *testing.T, root, name, target string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(root, "class/block"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, target), filepath.Join(root, "class/block", name)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIdentify(t *testing.T) {
+	root := testutil.Root(t, &sysRoot)
+	// lvm 卷位于 raid1 之上，raid1 由两块 virtio 硬盘的分区组成
+	for _, name := range []string{"vda", "vdb"} {
+		dir := filepath.Join("devices/pci0000:00/virtio1/block", name)
+		testutil.WriteFile(t, filepath.Join(root, dir, "queue/rotational"), "1")
+		testutil.WriteFile(t, filepath.Join(root, dir, "queue/scheduler"), "[mq-deadline] none")
+		testutil.WriteFile(t, filepath.Join(root, dir, "queue/nr_requests"), "256")
+		testutil.WriteFile(t, filepath.Join(root, dir, "queue/read_ahead_kb"), "128")
+		testutil.WriteFile(t, filepath.Join(root, dir, "size"), "209715200")
+		testutil.WriteFile(t, filepath.Join(root, dir, name+"1/partition"), "1")
+		link(t, root, name, dir)
+		link(t, root, name+"1", filepath.Join(dir, name+"1"))
+	}
+	testutil.WriteFile(t, filepath.Join(root, "devices/virtual/block/md0/md/level"), "raid1")
+	link(t, root, "md0", "devices/virtual/block/md0")
+	for _, slave := range []string{"vda1", "vdb1"} {
+		testutil.WriteFile(t, filepath.Join(root, "devices/virtual/block/md0/slaves", slave), "")
+	}
+	testutil.WriteFile(t, filepath.Join(root, "devices/virtual/block/dm-0/dm/uuid"), "LVM-abc")
+	testutil.WriteFile(t, filepath.Join(root, "devices/virtual/block/dm-0/dm/name"), "vg-data")
+	testutil.WriteFile(t, filepath.Join(root, "devices/virtual/block/dm-0/slaves/md0"), "")
+	link(t, root, "dm-0", "devices/virtual/block/dm-0")
+
+	info := Identify(diskpath.Info{Path: "/nonexistent/data", Device: "/dev/dm-0", FSType: "xfs"})
+	if len(info.Layers) != 2 || len(info.Disks) != 2 || info.Disks[1].Name != "vdb" {
+		t.Fatalf("Identify = %+v", info)
+	}
+	want := "[/nonexistent/data] Layers xfs -> lvm vg-data -> raid1 md0\n" +
+		"[/nonexistent/data] Disk vda HDD virtio, 100.0 GB, scheduler mq-deadline, queue depth 256, read-ahead 128 KB\n" +
+		"[/nonexistent/data] Disk vdb HDD virtio, 100.0 GB, scheduler mq-deadline, queue depth 256, read-ahead 128 KB\n"
+	if got := info.Format("en"); got != want {
+		t.Fatalf("Format = %q, want %q", got, want)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	dir := t.TempDir()
+	testutil.WriteFile(t, filepath.Join(dir, "device/state"), "live")
+	testutil.WriteFile(t, filepath.Join(dir, "device/hwmon/hwmon2/temp1_input"), "41850")
+	testutil.WriteFile(t, filepath.Join(dir, "device/ioerr_cnt"), "0x3")
+	if got := health(dir).format(false); got != "state live, temperature 42°C, 3 I/O errors" {
+		t.Fatalf("health = %q", got)
+	}
+	info := &Info{Path: "/data", Disks: []Disk{{Name: "nvme0n1", Health: health(dir)}}}
+	if got := info.Format("en"); !strings.HasSuffix(got, "[/data] Kernel status nvme0n1 state live, temperature 42°C, 3 I/O errors (not a SMART check)\n") {
+		t.Fatalf("Format = %q", got)
+	}
+	if got := activeScheduler("none"); got != "none" {
+		t.Fatalf("activeScheduler = %q", got)
+	}
+}
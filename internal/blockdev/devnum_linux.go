@@ -0,0 +1,17 @@
+package blockdev
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// deviceNumber returns the "major:minor" of the device holding path, empty when it cannot be read
+func deviceNumber(path string) string {
+	var stat unix.Stat_t
+	if err := unix.Stat(path, &stat); err != nil {
+		return ""
+	}
+	// btrfs 等文件系统使用匿名设备号，在 /sys/dev/block 中找不到，随后回退到设备名
+	return fmt.Sprintf("%d:%d", unix.Major(uint64(stat.Dev)), unix.Minor(uint64(stat.Dev)))
+}
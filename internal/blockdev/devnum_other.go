@@ -0,0 +1,8 @@
+//go:build !linux
+
+package blockdev
+
+// deviceNumber is only available on Linux
+func deviceNumber(path string) string {
+	return ""
+}
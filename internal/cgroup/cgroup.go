@@ -0,0 +1,77 @@
+// Package cgroup finds the cgroup directories whose limits apply to the current process
+package cgroup
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Dirs returns the directories of the cgroup v2 hierarchy and of the v1 hierarchy of controller that
+// contain the current process, each from its own cgroup up to the root of the mount, a limit set on any of
+// them applies. sysRoot and procRoot are the sysfs and procfs mount points.
+// Without a cgroup namespace, as for systemd units, /proc/self/cgroup gives the full path below the mount
+func Dirs(sysRoot, procRoot, controller string) []string {
+	mount := filepath.Join(sysRoot, "fs/cgroup")
+	data, err := os.ReadFile(filepath.Join(procRoot, "self/cgroup"))
+	if err != nil {
+		// 无法读取时只检查各层级的根目录
+		dirs := []string{mount}
+		entries, _ := os.ReadDir(mount)
+		for _, entry := range entries {
+			if hasController(entry.Name(), controller) {
+				dirs = append(dirs, filepath.Join(mount, entry.Name()))
+			}
+		}
+		return dirs
+	}
+	var dirs []string
+	// 每行格式为 层级ID:控制器列表:路径，cgroup v2 的控制器列表为空
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		switch {
+		case fields[0] == "0" && fields[1] == "":
+			dirs = append(dirs, walk(mount, fields[2])...)
+		case hasController(fields[1], controller):
+			// v1 的控制器可能共同挂载，如 cpu,cpuacct
+			dir := filepath.Join(mount, fields[1])
+			if _, err := os.Stat(dir); err != nil {
+				dir = filepath.Join(mount, controller)
+			}
+			dirs = append(dirs, walk(dir, fields[2])...)
+		}
+	}
+	return dirs
+}
+
+func hasController(list, controller string) bool {
+	for _, name := range strings.Split(list, ",") {
+		if name == controller {
+			return true
+		}
+	}
+	return false
+}
+
+// walk returns mount joined with path and its parents up to mount. In a container without a cgroup namespace
+// its own cgroup is mounted as the root, so leading components are dropped until the path exists below mount
+func walk(mount, path string) []string {
+	rel := strings.Trim(filepath.Clean("/"+path), "/")
+	for rel != "" {
+		if _, err := os.Stat(filepath.Join(mount, rel)); err == nil {
+			break
+		}
+		_, rel, _ = strings.Cut(rel, "/")
+	}
+	dirs := []string{filepath.Join(mount, rel)}
+	for rel != "" {
+		if rel = filepath.Dir(rel); rel == "." {
+			rel = ""
+		}
+		dirs = append(dirs, filepath.Join(mount, rel))
+	}
+	return dirs
+}
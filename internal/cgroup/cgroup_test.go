@@ -0,0 +1,33 @@
+package cgroup
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/oneclickvirt/ecs/internal/testutil"
+)
+
+func TestDirs(t *testing.T) {
+	root := t.TempDir()
+	sys, proc := filepath.Join(root, "sys"), filepath.Join(root, "proc")
+	mount := filepath.Join(sys, "fs/cgroup")
+	// systemd 服务没有独立的 cgroup 命名空间，路径从层级根开始
+	testutil.WriteFile(t, filepath.Join(mount, "system.slice/app.service/memory.max"), "max")
+	testutil.WriteFile(t, filepath.Join(proc, "self/cgroup"), "0::/system.slice/app.service")
+	got := Dirs(sys, proc, "memory")
+	want := []string{filepath.Join(mount, "system.slice/app.service"), filepath.Join(mount, "system.slice"), mount}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("Dirs v2 = %q, want %q", got, want)
+	}
+	// cgroup v1 容器中自身的 cgroup 挂载为根目录，/proc/self/cgroup 中的路径在挂载点下不存在
+	testutil.WriteFile(t, filepath.Join(mount, "cpu,cpuacct/cpu.cfs_quota_us"), "50000")
+	testutil.WriteFile(t, filepath.Join(proc, "self/cgroup"), "4:memory:/docker/abc\n3:cpu,cpuacct:/docker/abc\n1:name=systemd:/docker/abc")
+	if got := Dirs(sys, proc, "cpu"); fmt.Sprint(got) != fmt.Sprint([]string{filepath.Join(mount, "cpu,cpuacct")}) {
+		t.Fatalf("Dirs v1 = %q", got)
+	}
+	testutil.WriteFile(t, filepath.Join(proc, "self/cgroup"), "garbage")
+	if got := Dirs(sys, proc, "pids"); len(got) != 0 {
+		t.Fatalf("Dirs without the controller = %q", got)
+	}
+}
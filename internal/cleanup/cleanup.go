@@ -0,0 +1,76 @@
+// Package cleanup removes temporary test files on normal exit, after panics and on SIGINT/SIGTERM
+package cleanup
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	mu      sync.Mutex
+	nextID  int
+	pending = make(map[int]func())
+)
+
+// register adds remove to the registry and returns a function that runs it once and unregisters it
+func register(remove func()) func() {
+	mu.Lock()
+	id := nextID
+	nextID++
+	pending[id] = remove
+	mu.Unlock()
+	return func() {
+		mu.Lock()
+		remove, ok := pending[id]
+		delete(pending, id)
+		mu.Unlock()
+		if ok {
+			remove()
+		}
+	}
+}
+
+// Path registers a file or directory created by goecs, the returned function removes it
+func Path(path string) func() {
+	return register(func() { os.RemoveAll(path) })
+}
+
+// Track registers the files matching patterns in dir that do not exist yet, so files created by
+// the test libraries are removed while files the user already had are left alone
+func Track(dir string, patterns ...string) func() {
+	existing := make(map[string]bool)
+	for _, match := range glob(dir, patterns) {
+		existing[match] = true
+	}
+	return register(func() {
+		for _, match := range glob(dir, patterns) {
+			if !existing[match] {
+				os.Remove(match)
+			}
+		}
+	})
+}
+
+func glob(dir string, patterns []string) []string {
+	var matches []string
+	for _, pattern := range patterns {
+		found, _ := filepath.Glob(filepath.Join(dir, pattern))
+		matches = append(matches, found...)
+	}
+	return matches
+}
+
+// Run removes everything still registered, it is safe to call more than once
+func Run() {
+	mu.Lock()
+	removes := make([]func(), 0, len(pending))
+	for id, remove := range pending {
+		removes = append(removes, remove)
+		delete(pending, id)
+	}
+	mu.Unlock()
+	for _, remove := range removes {
+		remove()
+	}
+}
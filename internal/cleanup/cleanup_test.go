@@ -0,0 +1,37 @@
+package cleanup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTrack(t *testing.T) {
+	dir := t.TempDir()
+	userFile := filepath.Join(dir, "keep.test")
+	if err := os.WriteFile(userFile, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	release := Track(dir, "*.test", "test.fio")
+	// 模拟测试库在中断前留下的文件
+	for _, name := range []string{"1GB.test", "test.fio"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	Run()
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 || entries[0].Name() != "keep.test" {
+		t.Fatalf("after Run %v remain", entries)
+	}
+	// Run 之后再调用 release 不应重复执行
+	release()
+	created := filepath.Join(dir, "created")
+	if err := os.Mkdir(created, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	Path(created)()
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Fatalf("Path release left %s", created)
+	}
+}
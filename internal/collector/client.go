@@ -0,0 +1,54 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/oneclickvirt/ecs/internal/report"
+)
+
+// ResultsPath is the collector endpoint that accepts reports
+const ResultsPath = "/api/results"
+
+// Submit sends rep to the collector at baseURL and returns the stored submission ID
+func Submit(baseURL, token string, rep *report.Report) (string, error) {
+	if rep == nil {
+		return "", fmt.Errorf("no report to submit")
+	}
+	data, err := json.Marshal(rep.Clone())
+	if err != nil {
+		return "", err
+	}
+	endpoint := strings.TrimRight(baseURL, "/")
+	if !strings.HasSuffix(endpoint, ResultsPath) {
+		endpoint += ResultsPath
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("collector returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var submission Submission
+	if err := json.Unmarshal(body, &submission); err != nil {
+		return "", err
+	}
+	return submission.ID, nil
+}
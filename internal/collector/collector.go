@@ -0,0 +1,165 @@
+package collector
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/oneclickvirt/ecs/internal/listenaddr"
+	"github.com/oneclickvirt/ecs/internal/report"
+)
+
+// maxReportSize limits the body of a single submission
+const maxReportSize = 16 << 20
+
+// Collector receives reports from agents and serves them back for comparison
+type Collector struct {
+	token string
+	store *Store
+}
+
+// New creates a collector backed by store, token is required on every request when not empty
+func New(token string, store *Store) *Collector {
+	return &Collector{
+		token: token,
+		store: store,
+	}
+}
+
+// Main parses the collector subcommand flags and serves until interrupted
+func Main(args []string, version string) error {
+	collectorFlag := flag.NewFlagSet("collector", flag.ContinueOnError)
+	listen := collectorFlag.String("listen", "127.0.0.1:8090", "Address to listen on, e.g., -listen 0.0.0.0:8090 (requires -token)")
+	token := collectorFlag.String("token", "", "Shared token agents must send, also the password of the web UI (any username)")
+	dataDir := collectorFlag.String("data", "goecs-results", "Directory to store submitted results")
+	maxResults := collectorFlag.Int("max-results", 1000, "Keep only the newest results, older ones are deleted (0 keeps all)")
+	if err := collectorFlag.Parse(args); err != nil {
+		return err
+	}
+	// 收集端会保存并公开所有提交的结果，监听非回环地址时必须设置令牌
+	if *token == "" && !listenaddr.IsLoopback(*listen) {
+		return errors.New("-token is required when -listen is not a loopback address")
+	}
+	if *maxResults < 0 {
+		return errors.New("-max-results must not be negative")
+	}
+	store, err := OpenStore(*dataDir, *maxResults)
+	if err != nil {
+		return err
+	}
+	httpServer := &http.Server{
+		Addr:              *listen,
+		Handler:           New(*token, store).Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errChan := make(chan error, 1)
+	go func() {
+		fmt.Fprintf(os.Stderr, "goecs %s collector serving on %s, storing results in %s\n", version, *listen, *dataDir)
+		errChan <- httpServer.ListenAndServe()
+	}()
+	select {
+	case err := <-errChan:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return httpServer.Shutdown(shutdownCtx)
+}
+
+// Handler returns the HTTP routes of the collector
+func (c *Collector) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+ResultsPath, c.handleSubmit)
+	mux.HandleFunc("GET "+ResultsPath, c.handleList)
+	mux.HandleFunc("GET "+ResultsPath+"/{id}", c.handleGet)
+	mux.HandleFunc("GET /compare", c.handleCompare)
+	mux.HandleFunc("GET /{$}", c.handleIndex)
+	return c.authorize(mux)
+}
+
+// authorize accepts the token as a bearer token for agents or as the basic auth password for browsers
+func (c *Collector) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.token == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		given := r.Header.Get("Authorization")
+		if _, password, ok := r.BasicAuth(); ok {
+			given = "Bearer " + password
+		}
+		if subtle.ConstantTimeCompare([]byte(given), []byte("Bearer "+c.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="goecs collector"`)
+			writeError(w, http.StatusUnauthorized, "invalid or missing token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *Collector) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	var rep report.Report
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxReportSize)).Decode(&rep); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid report: "+err.Error())
+		return
+	}
+	if rep.Version == "" || len(rep.Sections) == 0 {
+		writeError(w, http.StatusBadRequest, "invalid report: missing version or sections")
+		return
+	}
+	source, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		source = r.RemoteAddr
+	}
+	submission, err := c.store.Add(&rep, source)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Location", ResultsPath+"/"+submission.ID)
+	summary := *submission
+	summary.Report = nil
+	writeJSON(w, http.StatusCreated, summary)
+}
+
+func (c *Collector) handleList(w http.ResponseWriter, r *http.Request) {
+	list := c.store.List(r.URL.Query().Get("host"))
+	summaries := make([]Summary, 0, len(list))
+	for _, submission := range list {
+		summaries = append(summaries, summarize(submission))
+	}
+	writeJSON(w, http.StatusOK, summaries)
+}
+
+func (c *Collector) handleGet(w http.ResponseWriter, r *http.Request) {
+	submission, ok := c.store.Get(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "result not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, submission)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, map[string]string{"error": message})
+}
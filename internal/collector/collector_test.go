@@ -0,0 +1,106 @@
+package collector
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oneclickvirt/ecs/internal/report"
+)
+
+func testReport(hostname, cpuOutput string) *report.Report {
+	rep := report.New("v0.0.0", "en", time.Now())
+	rep.Hostname = hostname
+	rep.Add(report.NewSection("cpu", "sysbench", cpuOutput))
+	rep.Finish()
+	return rep
+}
+
+func TestSubmitAndCompare(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenStore(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(New("secret", store).Handler())
+	defer ts.Close()
+
+	if _, err := Submit(ts.URL, "wrong", testReport("a", "x")); err == nil {
+		t.Fatal("submit with wrong token succeeded")
+	}
+	idA, err := Submit(ts.URL, "secret", testReport("host-a", "1 Thread(s) Test: 1000"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idB, err := Submit(ts.URL+ResultsPath, "secret", testReport("host-b", "1 Thread(s) Test: 2000"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/compare?id="+idA+"&id="+idB, nil)
+	req.SetBasicAuth("admin", "secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /compare status = %d", resp.StatusCode)
+	}
+	for _, want := range []string{"host-a", "host-b", "Test: 1000", "Test: 2000"} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("compare page does not contain %q", want)
+		}
+	}
+
+	// 重新打开目录应能加载已保存的结果
+	reopened, err := OpenStore(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list := reopened.List(""); len(list) != 2 {
+		t.Fatalf("reopened store has %d results, want 2", len(list))
+	}
+	if list := reopened.List("host-b"); len(list) != 1 || list[0].ID != idB {
+		t.Fatalf("List(host-b) = %v", list)
+	}
+}
+
+func TestStoreLimit(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenStore(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, host := range []string{"host-a", "host-b", "host-c"} {
+		submission, err := store.Add(testReport(host, "x"), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, submission.ID)
+		time.Sleep(time.Millisecond)
+	}
+	if _, ok := store.Get(ids[0]); ok {
+		t.Error("oldest result was not evicted")
+	}
+	if list := store.List(""); len(list) != 2 || list[0].ID != ids[2] {
+		t.Fatalf("List = %v", list)
+	}
+	reopened, err := OpenStore(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list := reopened.List(""); len(list) != 1 || list[0].ID != ids[2] {
+		t.Fatalf("reopened List = %v", list)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("%d result files left, want 1", len(files))
+	}
+}
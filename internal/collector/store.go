@@ -0,0 +1,155 @@
+package collector
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oneclickvirt/ecs/internal/report"
+)
+
+// Submission is one report received from an agent
+type Submission struct {
+	ID         string         `json:"id"`
+	ReceivedAt time.Time      `json:"received_at"`
+	Source     string         `json:"source,omitempty"`
+	Report     *report.Report `json:"report,omitempty"`
+}
+
+// Store keeps submissions in memory and persists each one as a JSON file under dir
+type Store struct {
+	dir         string
+	limit       int
+	submissions map[string]*Submission
+	mu          sync.RWMutex
+}
+
+// OpenStore creates dir if needed and loads the submissions already stored there,
+// only the newest limit submissions are kept when limit is positive
+func OpenStore(dir string, limit int) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &Store{
+		dir:         dir,
+		limit:       limit,
+		submissions: make(map[string]*Submission),
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var submission Submission
+		if err := json.Unmarshal(data, &submission); err != nil || submission.ID == "" || submission.Report == nil {
+			// 跳过损坏的文件，不影响其余结果的加载
+			fmt.Fprintf(os.Stderr, "skip invalid result file %s\n", file)
+			continue
+		}
+		s.submissions[submission.ID] = &submission
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.evict(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Add assigns an ID to the report and persists it
+func (s *Store) Add(rep *report.Report, source string) (*Submission, error) {
+	id, err := newSubmissionID()
+	if err != nil {
+		return nil, err
+	}
+	submission := &Submission{
+		ID:         id,
+		ReceivedAt: time.Now(),
+		Source:     source,
+		Report:     rep,
+	}
+	data, err := json.MarshalIndent(submission, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	// 先写临时文件再改名，避免中断时留下半个文件
+	path := filepath.Join(s.dir, submission.ID+".json")
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.submissions[submission.ID] = submission
+	if err := s.evict(); err != nil {
+		return nil, err
+	}
+	return submission, nil
+}
+
+// evict removes the oldest submissions and their files beyond the limit, the caller must hold s.mu
+func (s *Store) evict() error {
+	if s.limit <= 0 || len(s.submissions) <= s.limit {
+		return nil
+	}
+	list := make([]*Submission, 0, len(s.submissions))
+	for _, submission := range s.submissions {
+		list = append(list, submission)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ReceivedAt.Before(list[j].ReceivedAt)
+	})
+	for _, submission := range list[:len(list)-s.limit] {
+		err := os.Remove(filepath.Join(s.dir, submission.ID+".json"))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		delete(s.submissions, submission.ID)
+	}
+	return nil
+}
+
+// Get returns the submission with the given ID
+func (s *Store) Get(id string) (*Submission, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	submission, ok := s.submissions[id]
+	return submission, ok
+}
+
+// List returns submissions newest first, only those from host when host is not empty
+func (s *Store) List(host string) []*Submission {
+	s.mu.RLock()
+	list := make([]*Submission, 0, len(s.submissions))
+	for _, submission := range s.submissions {
+		if host == "" || strings.EqualFold(submission.Report.Hostname, host) {
+			list = append(list, submission)
+		}
+	}
+	s.mu.RUnlock()
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ReceivedAt.After(list[j].ReceivedAt)
+	})
+	return list
+}
+
+func newSubmissionID() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(b), nil
+}
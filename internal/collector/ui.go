@@ -0,0 +1,144 @@
+package collector
+
+import (
+	"html/template"
+	"net/http"
+	"time"
+)
+
+// Summary is the listing entry of a submission
+type Summary struct {
+	ID         string        `json:"id"`
+	Hostname   string        `json:"hostname"`
+	Source     string        `json:"source,omitempty"`
+	Version    string        `json:"version"`
+	Language   string        `json:"language"`
+	StartTime  time.Time     `json:"start_time"`
+	Duration   time.Duration `json:"duration"`
+	ReceivedAt time.Time     `json:"received_at"`
+	Sections   []string      `json:"sections"`
+}
+
+func summarize(submission *Submission) Summary {
+	rep := submission.Report
+	summary := Summary{
+		ID:         submission.ID,
+		Hostname:   rep.Hostname,
+		Source:     submission.Source,
+		Version:    rep.Version,
+		Language:   rep.Language,
+		StartTime:  rep.StartTime,
+		ReceivedAt: submission.ReceivedAt,
+	}
+	if !rep.EndTime.IsZero() {
+		summary.Duration = rep.EndTime.Sub(rep.StartTime).Round(time.Second)
+	}
+	for _, section := range rep.Sections {
+		summary.Sections = append(summary.Sections, section.Name)
+	}
+	return summary
+}
+
+// compareRow holds the output of one section for every compared submission
+type compareRow struct {
+	Name    string
+	Title   string
+	Outputs []string
+}
+
+func (c *Collector) handleIndex(w http.ResponseWriter, r *http.Request) {
+	host := r.URL.Query().Get("host")
+	list := c.store.List(host)
+	summaries := make([]Summary, 0, len(list))
+	for _, submission := range list {
+		summaries = append(summaries, summarize(submission))
+	}
+	renderHTML(w, indexTemplate, map[string]interface{}{
+		"Host":      host,
+		"Summaries": summaries,
+	})
+}
+
+// handleCompare shows the selected submissions side by side, one row per test section
+func (c *Collector) handleCompare(w http.ResponseWriter, r *http.Request) {
+	var (
+		columns []Summary
+		rows    []*compareRow
+	)
+	rowByName := make(map[string]*compareRow)
+	ids := r.URL.Query()["id"]
+	for column, id := range ids {
+		submission, ok := c.store.Get(id)
+		if !ok {
+			http.Error(w, "result not found: "+id, http.StatusNotFound)
+			return
+		}
+		columns = append(columns, summarize(submission))
+		for _, section := range submission.Report.Sections {
+			row, ok := rowByName[section.Name]
+			if !ok {
+				row = &compareRow{Name: section.Name, Title: section.Title, Outputs: make([]string, len(ids))}
+				rowByName[section.Name] = row
+				rows = append(rows, row)
+			}
+			row.Outputs[column] = section.Output
+		}
+	}
+	if len(columns) == 0 {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	renderHTML(w, compareTemplate, map[string]interface{}{
+		"Columns": columns,
+		"Rows":    rows,
+	})
+}
+
+func renderHTML(w http.ResponseWriter, tmpl *template.Template, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+const pageStyle = `<style>
+body{font-family:sans-serif;margin:1em}
+table{border-collapse:collapse}
+th,td{border:1px solid #ccc;padding:4px 8px;vertical-align:top;text-align:left}
+pre{margin:0;font-size:12px;white-space:pre}
+.scroll{overflow-x:auto}
+</style>`
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>goecs collector</title>` + pageStyle + `</head><body>
+<h2>goecs results{{if .Host}} - {{.Host}} <a href="/">(all)</a>{{end}}</h2>
+<form action="/compare" method="get">
+<table>
+<tr><th></th><th>Host</th><th>Source</th><th>Version</th><th>Language</th><th>Start Time</th><th>Duration</th><th>Sections</th><th>JSON</th></tr>
+{{range .Summaries}}<tr>
+<td><input type="checkbox" name="id" value="{{.ID}}"></td>
+<td><a href="/?host={{.Hostname}}">{{.Hostname}}</a></td>
+<td>{{.Source}}</td>
+<td>{{.Version}}</td>
+<td>{{.Language}}</td>
+<td>{{.StartTime.Format "2006-01-02 15:04:05"}}</td>
+<td>{{if .Duration}}{{.Duration}}{{end}}</td>
+<td>{{range $i, $name := .Sections}}{{if $i}}, {{end}}{{$name}}{{end}}</td>
+<td><a href="/api/results/{{.ID}}">{{.ID}}</a></td>
+</tr>{{else}}<tr><td colspan="9">No results yet</td></tr>{{end}}
+</table>
+<p><button type="submit">Compare selected</button></p>
+</form>
+</body></html>`))
+
+var compareTemplate = template.Must(template.New("compare").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>goecs compare</title>` + pageStyle + `</head><body>
+<h2><a href="/">goecs results</a> - compare</h2>
+<div class="scroll"><table>
+<tr><th>Section</th>{{range .Columns}}<th>{{.Hostname}}<br>{{.Version}} {{.StartTime.Format "2006-01-02 15:04:05"}}</th>{{end}}</tr>
+{{range .Rows}}<tr>
+<th>{{.Name}}{{if .Title}}<br>{{.Title}}{{end}}</th>
+{{range .Outputs}}<td><pre>{{.}}</pre></td>{{end}}
+</tr>{{end}}
+</table></div>
+</body></html>`))
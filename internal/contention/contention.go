@@ -0,0 +1,242 @@
+package contention
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sampleInterval is how often /proc/stat is read to find the peak steal
+const sampleInterval = 500 * time.Millisecond
+
+// Result is the contention observed while a test ran, all values are percentages
+type Result struct {
+	Duration  time.Duration `json:"duration"`
+	Steal     float64       `json:"steal"`
+	PeakSteal float64       `json:"peak_steal"`
+	IOWait    float64       `json:"iowait"`
+	// PSI "some" stall time of each resource, absent when the kernel has no /proc/pressure
+	CPUPressure    *float64 `json:"cpu_pressure,omitempty"`
+	MemoryPressure *float64 `json:"memory_pressure,omitempty"`
+	IOPressure     *float64 `json:"io_pressure,omitempty"`
+}
+
+// cpuTimes holds the aggregate jiffies of the "cpu" line of /proc/stat
+type cpuTimes struct {
+	total, steal, iowait uint64
+}
+
+// pressure holds the cumulative "some" stall time in microseconds of each resource
+type pressure map[string]uint64
+
+var pressureResources = []string{"cpu", "memory", "io"}
+
+// Monitor samples CPU steal, iowait and pressure stall information in the background while a test runs
+type Monitor struct {
+	start      time.Time
+	startTimes cpuTimes
+	startPSI   pressure
+	peakSteal  float64
+	stop       chan struct{}
+	done       chan struct{}
+	mu         sync.Mutex
+}
+
+// Start begins sampling, it returns nil when /proc/stat is unavailable (non-Linux systems)
+func Start() *Monitor {
+	times, err := readCPUTimes()
+	if err != nil {
+		return nil
+	}
+	m := &Monitor{
+		start:      time.Now(),
+		startTimes: times,
+		startPSI:   readPressure(),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go m.sample(times)
+	return m
+}
+
+func (m *Monitor) sample(last cpuTimes) {
+	defer close(m.done)
+	ticker := time.NewTicker(sampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+		}
+		times, err := readCPUTimes()
+		if err != nil {
+			continue
+		}
+		if steal := percent(sub(times.steal, last.steal), sub(times.total, last.total)); steal > m.peak() {
+			m.mu.Lock()
+			m.peakSteal = steal
+			m.mu.Unlock()
+		}
+		last = times
+	}
+}
+
+func (m *Monitor) peak() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.peakSteal
+}
+
+// Stop ends sampling and returns the contention over the whole period, a nil Monitor returns nil
+func (m *Monitor) Stop() *Result {
+	if m == nil {
+		return nil
+	}
+	close(m.stop)
+	<-m.done
+	times, err := readCPUTimes()
+	if err != nil {
+		return nil
+	}
+	elapsed := time.Since(m.start)
+	result := &Result{
+		Duration:  elapsed.Round(time.Millisecond),
+		Steal:     percent(sub(times.steal, m.startTimes.steal), sub(times.total, m.startTimes.total)),
+		IOWait:    percent(sub(times.iowait, m.startTimes.iowait), sub(times.total, m.startTimes.total)),
+		PeakSteal: m.peak(),
+	}
+	// 采样间隔内的峰值不应低于整体平均值
+	if result.PeakSteal < result.Steal {
+		result.PeakSteal = result.Steal
+	}
+	endPSI := readPressure()
+	fields := map[string]**float64{
+		"cpu":    &result.CPUPressure,
+		"memory": &result.MemoryPressure,
+		"io":     &result.IOPressure,
+	}
+	for _, resource := range pressureResources {
+		before, ok1 := m.startPSI[resource]
+		after, ok2 := endPSI[resource]
+		if !ok1 || !ok2 || elapsed <= 0 {
+			continue
+		}
+		value := percent(sub(after, before), uint64(elapsed.Microseconds()))
+		*fields[resource] = &value
+	}
+	return result
+}
+
+// Format returns the "contention during test" line of a section
+func (r *Result) Format(language string) string {
+	if r == nil {
+		return ""
+	}
+	var builder strings.Builder
+	if language == "zh" {
+		builder.WriteString(fmt.Sprintf("测试期间争抢: steal %.1f%% (峰值 %.1f%%), iowait %.1f%%", r.Steal, r.PeakSteal, r.IOWait))
+	} else {
+		builder.WriteString(fmt.Sprintf("Contention during test: steal %.1f%% (peak %.1f%%), iowait %.1f%%", r.Steal, r.PeakSteal, r.IOWait))
+	}
+	if r.CPUPressure != nil || r.MemoryPressure != nil || r.IOPressure != nil {
+		builder.WriteString(", PSI some")
+		for _, item := range []struct {
+			name  string
+			value *float64
+		}{{"cpu", r.CPUPressure}, {"memory", r.MemoryPressure}, {"io", r.IOPressure}} {
+			if item.value != nil {
+				builder.WriteString(fmt.Sprintf(" %s %.1f%%", item.name, *item.value))
+			}
+		}
+	}
+	builder.WriteString("\n")
+	return builder.String()
+}
+
+func readCPUTimes() (cpuTimes, error) {
+	data, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return cpuTimes{}, err
+	}
+	return parseCPUTimes(string(data))
+}
+
+// parseCPUTimes parses the aggregate line "cpu user nice system idle iowait irq softirq steal guest guest_nice"
+func parseCPUTimes(data string) (cpuTimes, error) {
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 || fields[0] != "cpu" {
+			continue
+		}
+		var times cpuTimes
+		// guest 时间已计入 user，不重复累加
+		for i, field := range fields[1:] {
+			if i >= 8 {
+				break
+			}
+			value, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return cpuTimes{}, err
+			}
+			times.total += value
+			switch i {
+			case 4:
+				times.iowait = value
+			case 7:
+				times.steal = value
+			}
+		}
+		return times, nil
+	}
+	return cpuTimes{}, fmt.Errorf("no cpu line in /proc/stat")
+}
+
+func readPressure() pressure {
+	result := make(pressure)
+	for _, resource := range pressureResources {
+		data, err := os.ReadFile("/proc/pressure/" + resource)
+		if err != nil {
+			continue
+		}
+		if total, ok := parsePressure(string(data)); ok {
+			result[resource] = total
+		}
+	}
+	return result
+}
+
+// parsePressure returns the total of the line "some avg10=0.00 avg60=0.00 avg300=0.00 total=12345"
+func parsePressure(data string) (uint64, bool) {
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != "some" {
+			continue
+		}
+		for _, field := range fields[1:] {
+			if value, ok := strings.CutPrefix(field, "total="); ok {
+				total, err := strconv.ParseUint(value, 10, 64)
+				return total, err == nil
+			}
+		}
+	}
+	return 0, false
+}
+
+func percent(part, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total) * 100
+}
+
+// sub returns a-b, or 0 when a counter went backwards
+func sub(a, b uint64) uint64 {
+	if a < b {
+		return 0
+	}
+	return a - b
+}
@@ -0,0 +1,37 @@
+package contention
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	times, err := parseCPUTimes("cpu  100 0 50 800 20 0 0 30 10 0\ncpu0 100 0 50 800 20 0 0 30 10 0\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if times.total != 1000 || times.iowait != 20 || times.steal != 30 {
+		t.Fatalf("parseCPUTimes = %+v", times)
+	}
+	total, ok := parsePressure("some avg10=1.00 avg60=0.50 avg300=0.10 total=123456\nfull avg10=0.00 avg60=0.00 avg300=0.00 total=99\n")
+	if !ok || total != 123456 {
+		t.Fatalf("parsePressure = %d, %v", total, ok)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	io := 2.5
+	result := &Result{Steal: 12.34, PeakSteal: 40, IOWait: 1, IOPressure: &io}
+	got := result.Format("en")
+	want := "Contention during test: steal 12.3% (peak 40.0%), iowait 1.0%, PSI some io 2.5%\n"
+	if got != want {
+		t.Fatalf("Format = %q, want %q", got, want)
+	}
+	if !strings.HasPrefix(result.Format("zh"), "测试期间争抢") {
+		t.Fatalf("unexpected zh format %q", result.Format("zh"))
+	}
+	var empty *Result
+	if empty.Format("en") != "" {
+		t.Fatal("nil result should format as empty")
+	}
+}
@@ -0,0 +1,232 @@
+package cpubench
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultDuration is how long each workload runs per thread mode
+const DefaultDuration = time.Second
+
+// workload is one sub-test, run returns the amount of work one call performed in units of unit
+type workload struct {
+	name      string
+	zhName    string
+	unit      string
+	reference float64 // 该成绩计 1000 分，取值使总分与 sysbench 得分量级相近
+	newRunner func() func() float64
+}
+
+var workloads = []workload{
+	{"Integer", "整数运算", "Mops/s", 1200, newIntegerRunner},
+	{"Float", "浮点运算", "Mops/s", 1400, newFloatRunner},
+	{"SHA256", "SHA256哈希", "MB/s", 580, newHashRunner},
+	{"Deflate", "Deflate压缩", "MB/s", 35, newCompressRunner},
+	{"Sort", "排序", "Melem/s", 5.5, newSortRunner},
+}
+
+// SubScore is the throughput of one workload
+type SubScore struct {
+	Name  string
+	Unit  string
+	Value float64
+}
+
+// Result is the outcome of running all workloads with a number of threads
+type Result struct {
+	Threads   int
+	Score     float64
+	SubScores []SubScore
+}
+
+// sink keeps the compiler from optimizing the workloads away
+var sink atomic.Uint64
+
+// Run executes every workload on threads goroutines for duration each,
+// the score is the geometric mean of the throughput relative to the reference machine times 1000
+func Run(threads int, duration time.Duration) Result {
+	if threads < 1 {
+		threads = 1
+	}
+	result := Result{Threads: threads}
+	logSum := 0.0
+	for _, w := range workloads {
+		value := measure(w, threads, duration)
+		result.SubScores = append(result.SubScores, SubScore{Name: w.name, Unit: w.unit, Value: value})
+		logSum += math.Log(math.Max(value, 1e-9) / w.reference)
+	}
+	result.Score = math.Exp(logSum/float64(len(workloads))) * 1000
+	return result
+}
+
+// measure returns the combined throughput of all threads in millions of units per second
+func measure(w workload, threads int, duration time.Duration) float64 {
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		total float64
+	)
+	start := time.Now()
+	for i := 0; i < threads; i++ {
+		run := w.newRunner()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var done float64
+			for time.Since(start) < duration {
+				done += run()
+			}
+			mu.Lock()
+			total += done
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return total / time.Since(start).Seconds() / 1e6
+}
+
+// newIntegerRunner mixes xorshift, multiply, divide and bit operations
+func newIntegerRunner() func() float64 {
+	const iterations = 1 << 16
+	x := uint64(88172645463325252)
+	return func() float64 {
+		var acc uint64
+		for i := uint64(1); i <= iterations; i++ {
+			x ^= x << 13
+			x ^= x >> 7
+			x ^= x << 17
+			acc += x%(i|1) + (x*i)>>3 ^ uint64(i&0xff)
+		}
+		sink.Add(acc)
+		// 每次迭代约 10 次整数运算
+		return iterations * 10
+	}
+}
+
+// newFloatRunner renders a small Mandelbrot set tile
+func newFloatRunner() func() float64 {
+	const size, maxIter = 32, 64
+	return func() float64 {
+		var ops float64
+		escaped := 0
+		for py := 0; py < size; py++ {
+			for px := 0; px < size; px++ {
+				cr := -2.0 + 2.5*float64(px)/size
+				ci := -1.25 + 2.5*float64(py)/size
+				zr, zi := 0.0, 0.0
+				n := 0
+				for ; n < maxIter && zr*zr+zi*zi <= 4; n++ {
+					zr, zi = zr*zr-zi*zi+cr, 2*zr*zi+ci
+				}
+				// 每次迭代约 10 次浮点运算
+				ops += float64(n) * 10
+				if n < maxIter {
+					escaped++
+				}
+			}
+		}
+		sink.Add(uint64(escaped))
+		return ops
+	}
+}
+
+// newHashRunner hashes a 64 KiB buffer
+func newHashRunner() func() float64 {
+	buf := make([]byte, 64<<10)
+	for i := range buf {
+		buf[i] = byte(i * 31)
+	}
+	return func() float64 {
+		sum := sha256.Sum256(buf)
+		sink.Add(uint64(sum[0]))
+		return float64(len(buf))
+	}
+}
+
+// newCompressRunner compresses 256 KiB of repetitive text at the default level
+func newCompressRunner() func() float64 {
+	var text bytes.Buffer
+	words := strings.Fields("the quick brown fox jumps over a lazy dog while seven wizards box and jolly vexed quails")
+	seed := uint32(2463534242)
+	for text.Len() < 256<<10 {
+		seed ^= seed << 13
+		seed ^= seed >> 17
+		seed ^= seed << 5
+		text.WriteString(words[seed%uint32(len(words))])
+		text.WriteByte(' ')
+	}
+	input := text.Bytes()
+	writer, _ := flate.NewWriter(io.Discard, flate.DefaultCompression)
+	return func() float64 {
+		writer.Reset(io.Discard)
+		writer.Write(input)
+		writer.Close()
+		return float64(len(input))
+	}
+}
+
+// newSortRunner sorts 16k pseudo-random integers
+func newSortRunner() func() float64 {
+	const count = 16 << 10
+	source := make([]int, count)
+	seed := uint64(0x9E3779B97F4A7C15)
+	for i := range source {
+		seed ^= seed << 13
+		seed ^= seed >> 7
+		seed ^= seed << 17
+		source[i] = int(seed >> 1)
+	}
+	work := make([]int, count)
+	return func() float64 {
+		copy(work, source)
+		slices.Sort(work)
+		sink.Add(uint64(work[count/2]))
+		return count
+	}
+}
+
+// Test runs the benchmark like the other CPU methods: single thread, plus all threads when
+// testThread is "multi" on a multi-core machine, and formats the scores
+func Test(language, testThread string, duration time.Duration) string {
+	results := []Result{Run(1, duration)}
+	if threads := runtime.NumCPU(); testThread == "multi" && threads > 1 {
+		results = append(results, Run(threads, duration))
+	}
+	var builder strings.Builder
+	for _, result := range results {
+		builder.WriteString(Format(language, result))
+	}
+	return builder.String()
+}
+
+// Format renders the total score in the same form as sysbench results, followed by the sub-scores
+func Format(language string, result Result) string {
+	var builder strings.Builder
+	if language == "zh" {
+		if result.Threads == 1 {
+			builder.WriteString(fmt.Sprintf("1 线程测试(单核)得分: %.2f\n", result.Score))
+		} else {
+			builder.WriteString(fmt.Sprintf("%d 线程测试(多核)得分: %.2f\n", result.Threads, result.Score))
+		}
+	} else {
+		builder.WriteString(fmt.Sprintf("%d Thread(s) Test: %.2f\n", result.Threads, result.Score))
+	}
+	for i, sub := range result.SubScores {
+		if language == "zh" {
+			builder.WriteString(fmt.Sprintf("  %d 线程%s: %.2f %s\n", result.Threads, workloads[i].zhName, sub.Value, sub.Unit))
+		} else {
+			builder.WriteString(fmt.Sprintf("  %d Thread(s) %s: %.2f %s\n", result.Threads, sub.Name, sub.Value, sub.Unit))
+		}
+	}
+	return builder.String()
+}
@@ -0,0 +1,86 @@
+package cpubench
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oneclickvirt/ecs/internal/report"
+	"github.com/oneclickvirt/ecs/internal/testutil"
+)
+
+func TestRun(t *testing.T) {
+	result := Run(2, 20*time.Millisecond)
+	if result.Threads != 2 || result.Score <= 0 || len(result.SubScores) != len(workloads) {
+		t.Fatalf("Run = %+v", result)
+	}
+	for _, sub := range result.SubScores {
+		if sub.Value <= 0 {
+			t.Errorf("%s = %v", sub.Name, sub.Value)
+		}
+	}
+	// 输出格式需与 sysbench 一致，以便统计与基线比较
+	text := Format("en", result)
+	if !strings.HasPrefix(text, "2 Thread(s) Test: ") {
+		t.Fatalf("Format = %q", text)
+	}
+	metrics := report.ParseOutput("cpu", text)
+	if len(metrics) != 1+len(workloads) {
+		t.Fatalf("parsed %d metrics from %q", len(metrics), text)
+	}
+}
+
+func TestDetectTopology(t *testing.T) {
+	root := testutil.Root(t, &sysRoot)
+	// 1 个插槽，2 个物理核心，每核 2 个超线程
+	for cpu := 0; cpu < 4; cpu++ {
+		dir := filepath.Join(root, "devices/system/cpu", fmt.Sprintf("cpu%d", cpu))
+		testutil.WriteFile(t, filepath.Join(dir, "topology/physical_package_id"), "0")
+		testutil.WriteFile(t, filepath.Join(dir, "topology/core_id"), fmt.Sprint(cpu/2))
+		testutil.WriteFile(t, filepath.Join(dir, "cache/index0/level"), "1")
+		testutil.WriteFile(t, filepath.Join(dir, "cache/index0/type"), "Data")
+		testutil.WriteFile(t, filepath.Join(dir, "cache/index0/size"), "32K")
+		testutil.WriteFile(t, filepath.Join(dir, "cache/index0/shared_cpu_list"), fmt.Sprintf("%d-%d", cpu/2*2, cpu/2*2+1))
+		testutil.WriteFile(t, filepath.Join(dir, "cache/index1/level"), "3")
+		testutil.WriteFile(t, filepath.Join(dir, "cache/index1/type"), "Unified")
+		testutil.WriteFile(t, filepath.Join(dir, "cache/index1/size"), "8192K")
+		testutil.WriteFile(t, filepath.Join(dir, "cache/index1/shared_cpu_list"), "0-3")
+	}
+	// 限制设置在进程所在 cgroup 的上层 slice 上
+	proc := testutil.Root(t, &procRoot)
+	testutil.WriteFile(t, filepath.Join(proc, "self/cgroup"), "0::/app.slice/app.service")
+	testutil.WriteFile(t, filepath.Join(root, "fs/cgroup/cpu.max"), "max 100000")
+	testutil.WriteFile(t, filepath.Join(root, "fs/cgroup/app.slice/cpu.max"), "150000 100000")
+	testutil.WriteFile(t, filepath.Join(root, "fs/cgroup/app.slice/app.service/cpu.max"), "400000 100000")
+	topology := DetectTopology()
+	if topology == nil || topology.Sockets != 1 || topology.Cores != 2 || topology.Threads != 4 || topology.QuotaCPU != 1.5 {
+		t.Fatalf("DetectTopology = %+v", topology)
+	}
+	want := "CPU Topology: 1 socket(s), 2 core(s), 4 thread(s), 2 thread(s) per core\n" +
+		"CPU Cache: L1d 32K x2, L3 8192K x1\n" +
+		"cgroup CPU Quota: 1.50 CPU(s)\n"
+	if got := topology.Format("en"); got != want {
+		t.Fatalf("Format = %q, want %q", got, want)
+	}
+	if counts := fmt.Sprint(threadCounts(6)); counts != "[1 2 4 6]" {
+		t.Fatalf("threadCounts(6) = %s", counts)
+	}
+}
+
+func TestSustainedSummary(t *testing.T) {
+	result := SustainedResult{Threads: 4, MaxFreqMHz: 3000, OnsetBucket: -1}
+	for i, score := range []float64{1000, 990, 850, 800, 780, 800, 790, 800} {
+		start := time.Duration(i) * 30 * time.Second
+		result.Buckets = append(result.Buckets, Bucket{Start: start, End: start + 30*time.Second, Score: score, FreqMHz: 3000 - float64(i)*100, TempCelsius: 60 + float64(i)})
+	}
+	result.summarize()
+	if result.Burst != 1000 || result.Sustained != 795 || result.OnsetBucket != 2 {
+		t.Fatalf("summarize = %+v", result)
+	}
+	text := FormatSustained("en", 4*time.Minute, result)
+	if !strings.Contains(text, "Sustained/Burst: 79.5%") || !strings.Contains(text, "Throttling starts after 1m0s, score below 90% of burst (2800/3000 MHz, 62.0°C)") {
+		t.Fatalf("FormatSustained = %q", text)
+	}
+}
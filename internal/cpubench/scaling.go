@@ -0,0 +1,82 @@
+package cpubench
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// ScalingStep is the result of one thread count
+type ScalingStep struct {
+	Result
+	// Efficiency is the score relative to threads times the single thread score, in percent
+	Efficiency float64
+}
+
+// threadCounts returns 1, 2, 4 ... up to n, always ending with n
+func threadCounts(n int) []int {
+	var counts []int
+	for threads := 1; threads < n; threads *= 2 {
+		counts = append(counts, threads)
+	}
+	return append(counts, n)
+}
+
+// RunScaling runs the workloads at 1, 2, 4 ... maxThreads threads
+func RunScaling(maxThreads int, duration time.Duration) []ScalingStep {
+	var steps []ScalingStep
+	for _, threads := range threadCounts(maxThreads) {
+		step := ScalingStep{Result: Run(threads, duration)}
+		if len(steps) > 0 && steps[0].Score > 0 {
+			step.Efficiency = step.Score / (float64(threads) * steps[0].Score) * 100
+		} else {
+			step.Efficiency = 100
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+// ScalingTest reports the topology followed by the throughput and scaling efficiency at each thread count
+func ScalingTest(language string, duration time.Duration) string {
+	topology := DetectTopology()
+	steps := RunScaling(runtime.NumCPU(), duration)
+	var builder strings.Builder
+	builder.WriteString(topology.Format(language))
+	for _, step := range steps {
+		if language == "zh" {
+			builder.WriteString(fmt.Sprintf("%d 线程测试得分: %.2f (单线程平均 %.2f, 扩展效率 %.1f%%)\n",
+				step.Threads, step.Score, step.Score/float64(step.Threads), step.Efficiency))
+		} else {
+			builder.WriteString(fmt.Sprintf("%d Thread(s) Test: %.2f (per thread %.2f, efficiency %.1f%%)\n",
+				step.Threads, step.Score, step.Score/float64(step.Threads), step.Efficiency))
+		}
+	}
+	builder.WriteString(scalingHint(language, topology, steps))
+	return builder.String()
+}
+
+// scalingHint explains poor scaling by the cgroup quota or SMT siblings when possible
+func scalingHint(language string, topology *Topology, steps []ScalingStep) string {
+	last := steps[len(steps)-1]
+	switch {
+	case topology != nil && topology.QuotaCPU > 0 && topology.QuotaCPU < float64(last.Threads):
+		if language == "zh" {
+			return fmt.Sprintf("注意: 可见 %d 个vCPU，但cgroup配额仅 %.2f 核\n", last.Threads, topology.QuotaCPU)
+		}
+		return fmt.Sprintf("Note: %d vCPUs are visible but the cgroup quota allows only %.2f\n", last.Threads, topology.QuotaCPU)
+	case last.Threads == 1 || last.Efficiency >= 70:
+		return ""
+	case topology != nil && topology.Threads > topology.Cores:
+		if language == "zh" {
+			return fmt.Sprintf("注意: 扩展效率仅 %.1f%%，%d 个vCPU由 %d 个物理核心的超线程提供\n", last.Efficiency, topology.Threads, topology.Cores)
+		}
+		return fmt.Sprintf("Note: efficiency is only %.1f%%, the %d vCPUs are hyperthreads of %d physical cores\n", last.Efficiency, topology.Threads, topology.Cores)
+	default:
+		if language == "zh" {
+			return fmt.Sprintf("注意: 扩展效率仅 %.1f%%，vCPU可能为超线程或被超售\n", last.Efficiency)
+		}
+		return fmt.Sprintf("Note: efficiency is only %.1f%%, the vCPUs are likely hyperthreads or oversold\n", last.Efficiency)
+	}
+}
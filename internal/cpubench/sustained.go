@@ -0,0 +1,197 @@
+package cpubench
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oneclickvirt/ecs/internal/report"
+)
+
+// throttleRatio is the fraction of the burst score below which a bucket counts as throttled
+const throttleRatio = 0.9
+
+// Bucket is the score and sensor readings of one time slice of the sustained test
+type Bucket struct {
+	Start       time.Duration
+	End         time.Duration
+	Score       float64
+	FreqMHz     float64 // 所有核心的平均当前频率，0 表示无法读取
+	TempCelsius float64 // 所有温区中的最高温度，0 表示无法读取
+}
+
+// SustainedResult is the outcome of a long CPU run
+type SustainedResult struct {
+	Threads     int
+	Buckets     []Bucket
+	MaxFreqMHz  float64
+	Burst       float64
+	Sustained   float64
+	Ratio       float64 // Sustained / Burst，百分比
+	OnsetBucket int     // 首个低于爆发成绩 90% 的时间段，-1 表示未降频
+}
+
+// bucketLength splits duration into about ten buckets of at least five seconds
+func bucketLength(duration time.Duration) time.Duration {
+	bucket := (duration / 10).Round(time.Second)
+	if bucket < 5*time.Second {
+		bucket = 5 * time.Second
+	}
+	return bucket
+}
+
+// RunSustained loads all threads for duration, scoring each bucket and sampling cpufreq and thermal zones
+func RunSustained(threads int, duration time.Duration) SustainedResult {
+	result := SustainedResult{Threads: threads, MaxFreqMHz: readMaxFreq(), OnsetBucket: -1}
+	bucket := bucketLength(duration)
+	perWorkload := bucket / time.Duration(len(workloads))
+	for elapsed := time.Duration(0); elapsed < duration; elapsed += bucket {
+		score := Run(threads, perWorkload).Score
+		result.Buckets = append(result.Buckets, Bucket{
+			Start:       elapsed,
+			End:         elapsed + bucket,
+			Score:       score,
+			FreqMHz:     readCurrentFreq(),
+			TempCelsius: readMaxTemp(),
+		})
+	}
+	result.summarize()
+	return result
+}
+
+// summarize takes the first bucket as burst and the median of the last quarter as sustained
+func (r *SustainedResult) summarize() {
+	if len(r.Buckets) == 0 {
+		return
+	}
+	r.Burst = r.Buckets[0].Score
+	tail := len(r.Buckets) / 4
+	if tail < 1 {
+		tail = 1
+	}
+	scores := make([]float64, 0, tail)
+	for _, bucket := range r.Buckets[len(r.Buckets)-tail:] {
+		scores = append(scores, bucket.Score)
+	}
+	r.Sustained = report.Summarize(scores).Median
+	if r.Burst > 0 {
+		r.Ratio = r.Sustained / r.Burst * 100
+	}
+	for i, bucket := range r.Buckets {
+		if bucket.Score < r.Burst*throttleRatio {
+			r.OnsetBucket = i
+			break
+		}
+	}
+}
+
+// SustainedTest runs the sustained test on all threads and formats it for the CPU section
+func SustainedTest(language string, duration time.Duration) string {
+	return FormatSustained(language, duration, RunSustained(runtime.NumCPU(), duration))
+}
+
+// FormatSustained renders the score over time and the throttling summary
+func FormatSustained(language string, duration time.Duration, result SustainedResult) string {
+	var builder strings.Builder
+	if language == "zh" {
+		builder.WriteString(fmt.Sprintf("持续负载测试(%s, %d 线程)\n", duration, result.Threads))
+	} else {
+		builder.WriteString(fmt.Sprintf("Sustained Test (%s, %d thread(s))\n", duration, result.Threads))
+	}
+	for _, bucket := range result.Buckets {
+		line := fmt.Sprintf("  [%6s - %6s] score %.2f", bucket.Start, bucket.End, bucket.Score)
+		if bucket.FreqMHz > 0 {
+			line += fmt.Sprintf(", %.0f MHz", bucket.FreqMHz)
+		}
+		if bucket.TempCelsius > 0 {
+			line += fmt.Sprintf(", %.1f°C", bucket.TempCelsius)
+		}
+		builder.WriteString(line + "\n")
+	}
+	if language == "zh" {
+		builder.WriteString(fmt.Sprintf("爆发得分: %.2f\n持续得分: %.2f\n持续/爆发: %.1f%%\n", result.Burst, result.Sustained, result.Ratio))
+	} else {
+		builder.WriteString(fmt.Sprintf("Burst Score: %.2f\nSustained Score: %.2f\nSustained/Burst: %.1f%%\n", result.Burst, result.Sustained, result.Ratio))
+	}
+	if result.OnsetBucket < 0 {
+		if language == "zh" {
+			builder.WriteString("未检测到降频\n")
+		} else {
+			builder.WriteString("No throttling detected\n")
+		}
+		return builder.String()
+	}
+	onset := result.Buckets[result.OnsetBucket]
+	var detail []string
+	if onset.FreqMHz > 0 && result.MaxFreqMHz > 0 {
+		detail = append(detail, fmt.Sprintf("%.0f/%.0f MHz", onset.FreqMHz, result.MaxFreqMHz))
+	}
+	if onset.TempCelsius > 0 {
+		detail = append(detail, fmt.Sprintf("%.1f°C", onset.TempCelsius))
+	}
+	suffix := ""
+	if len(detail) > 0 {
+		suffix = " (" + strings.Join(detail, ", ") + ")"
+	}
+	if language == "zh" {
+		builder.WriteString(fmt.Sprintf("降频开始于 %s 后，得分低于爆发得分的 %.0f%%%s\n", onset.Start, throttleRatio*100, suffix))
+	} else {
+		builder.WriteString(fmt.Sprintf("Throttling starts after %s, score below %.0f%% of burst%s\n", onset.Start, throttleRatio*100, suffix))
+	}
+	return builder.String()
+}
+
+// readCurrentFreq returns the average scaling_cur_freq of all CPUs in MHz
+func readCurrentFreq() float64 {
+	return averageFreq("scaling_cur_freq")
+}
+
+// readMaxFreq returns the average cpuinfo_max_freq of all CPUs in MHz
+func readMaxFreq() float64 {
+	return averageFreq("cpuinfo_max_freq")
+}
+
+func averageFreq(name string) float64 {
+	files, _ := filepath.Glob(filepath.Join(sysRoot, "devices/system/cpu/cpu[0-9]*/cpufreq", name))
+	var sum float64
+	count := 0
+	for _, file := range files {
+		text, err := readTrimmed(file)
+		if err != nil {
+			continue
+		}
+		kHz, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			continue
+		}
+		sum += kHz / 1000
+		count++
+	}
+	if count == 0 {
+		return 0
+	}
+	return sum / float64(count)
+}
+
+// readMaxTemp returns the highest thermal zone temperature in Celsius
+func readMaxTemp() float64 {
+	files, _ := filepath.Glob(filepath.Join(sysRoot, "class/thermal/thermal_zone*/temp"))
+	var highest float64
+	for _, file := range files {
+		text, err := readTrimmed(file)
+		if err != nil {
+			continue
+		}
+		milli, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			continue
+		}
+		if celsius := milli / 1000; celsius > highest && celsius < 150 {
+			highest = celsius
+		}
+	}
+	return highest
+}
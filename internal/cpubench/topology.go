@@ -0,0 +1,172 @@
+package cpubench
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/oneclickvirt/ecs/internal/cgroup"
+)
+
+// sysRoot and procRoot are the sysfs and procfs mount points, replaced in tests
+var (
+	sysRoot  = "/sys"
+	procRoot = "/proc"
+)
+
+// Cache describes one cache level and how many separate instances exist
+type Cache struct {
+	Level     int
+	Type      string
+	Size      string
+	Instances int
+}
+
+// Topology is the CPU layout found under /sys/devices/system/cpu
+type Topology struct {
+	Sockets  int
+	Cores    int
+	Threads  int
+	Caches   []Cache
+	QuotaCPU float64 // cgroup CPU 配额折算的核数，0 表示不限制或未知
+}
+
+// DetectTopology reads sockets, cores, SMT siblings, caches and the cgroup CPU quota,
+// it returns nil when sysfs is unavailable
+func DetectTopology() *Topology {
+	cpuDirs, err := filepath.Glob(filepath.Join(sysRoot, "devices/system/cpu/cpu[0-9]*"))
+	if err != nil || len(cpuDirs) == 0 {
+		return nil
+	}
+	topology := &Topology{}
+	sockets := make(map[string]bool)
+	cores := make(map[string]bool)
+	cacheInstances := make(map[string]map[string]bool)
+	cacheInfo := make(map[string]Cache)
+	for _, dir := range cpuDirs {
+		// 离线的 CPU 没有 topology 目录
+		socket, err := readTrimmed(filepath.Join(dir, "topology/physical_package_id"))
+		if err != nil {
+			continue
+		}
+		core, _ := readTrimmed(filepath.Join(dir, "topology/core_id"))
+		topology.Threads++
+		sockets[socket] = true
+		cores[socket+"/"+core] = true
+		indexDirs, _ := filepath.Glob(filepath.Join(dir, "cache/index[0-9]*"))
+		for _, indexDir := range indexDirs {
+			levelText, err1 := readTrimmed(filepath.Join(indexDir, "level"))
+			cacheType, err2 := readTrimmed(filepath.Join(indexDir, "type"))
+			size, err3 := readTrimmed(filepath.Join(indexDir, "size"))
+			if err1 != nil || err2 != nil || err3 != nil {
+				continue
+			}
+			level, _ := strconv.Atoi(levelText)
+			shared, _ := readTrimmed(filepath.Join(indexDir, "shared_cpu_list"))
+			key := levelText + "/" + cacheType + "/" + size
+			if cacheInstances[key] == nil {
+				cacheInstances[key] = make(map[string]bool)
+				cacheInfo[key] = Cache{Level: level, Type: cacheType, Size: size}
+			}
+			cacheInstances[key][shared] = true
+		}
+	}
+	if topology.Threads == 0 {
+		return nil
+	}
+	topology.Sockets = len(sockets)
+	topology.Cores = len(cores)
+	for key, cache := range cacheInfo {
+		cache.Instances = len(cacheInstances[key])
+		topology.Caches = append(topology.Caches, cache)
+	}
+	sort.Slice(topology.Caches, func(i, j int) bool {
+		if topology.Caches[i].Level != topology.Caches[j].Level {
+			return topology.Caches[i].Level < topology.Caches[j].Level
+		}
+		return topology.Caches[i].Type < topology.Caches[j].Type
+	})
+	topology.QuotaCPU = cgroupQuota()
+	return topology
+}
+
+// cgroupQuota returns the smallest CPU limit of the cgroup v2 cpu.max or v1 cfs quota files
+// of the cgroup of the process and its ancestors, 0 when none is set
+func cgroupQuota() float64 {
+	var limit float64
+	for _, dir := range cgroup.Dirs(sysRoot, procRoot, "cpu") {
+		var quotaText, periodText string
+		if text, err := readTrimmed(filepath.Join(dir, "cpu.max")); err == nil {
+			// cgroup v2 为 "配额 周期"，不限制时配额为 max
+			fields := strings.Fields(text)
+			if len(fields) == 2 {
+				quotaText, periodText = fields[0], fields[1]
+			}
+		} else {
+			quotaText, _ = readTrimmed(filepath.Join(dir, "cpu.cfs_quota_us"))
+			periodText, _ = readTrimmed(filepath.Join(dir, "cpu.cfs_period_us"))
+		}
+		// v1 不限制时配额为 -1
+		quota, err1 := strconv.ParseFloat(quotaText, 64)
+		period, err2 := strconv.ParseFloat(periodText, 64)
+		if err1 == nil && err2 == nil && quota > 0 && period > 0 && (limit == 0 || quota/period < limit) {
+			limit = quota / period
+		}
+	}
+	return limit
+}
+
+func readTrimmed(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	return strings.TrimSpace(string(data)), err
+}
+
+// cacheName returns names like L1d, L1i and L2
+func (c Cache) cacheName() string {
+	switch c.Type {
+	case "Data":
+		return fmt.Sprintf("L%dd", c.Level)
+	case "Instruction":
+		return fmt.Sprintf("L%di", c.Level)
+	}
+	return fmt.Sprintf("L%d", c.Level)
+}
+
+// Format renders the topology lines
+func (t *Topology) Format(language string) string {
+	if t == nil {
+		return ""
+	}
+	var builder strings.Builder
+	smt := 1
+	if t.Cores > 0 {
+		smt = t.Threads / t.Cores
+	}
+	if language == "zh" {
+		builder.WriteString(fmt.Sprintf("CPU拓扑: %d 个插槽, %d 个物理核心, %d 个线程, 每核 %d 线程\n", t.Sockets, t.Cores, t.Threads, smt))
+	} else {
+		builder.WriteString(fmt.Sprintf("CPU Topology: %d socket(s), %d core(s), %d thread(s), %d thread(s) per core\n", t.Sockets, t.Cores, t.Threads, smt))
+	}
+	if len(t.Caches) > 0 {
+		caches := make([]string, 0, len(t.Caches))
+		for _, cache := range t.Caches {
+			caches = append(caches, fmt.Sprintf("%s %s x%d", cache.cacheName(), cache.Size, cache.Instances))
+		}
+		if language == "zh" {
+			builder.WriteString("CPU缓存: " + strings.Join(caches, ", ") + "\n")
+		} else {
+			builder.WriteString("CPU Cache: " + strings.Join(caches, ", ") + "\n")
+		}
+	}
+	if t.QuotaCPU > 0 {
+		if language == "zh" {
+			builder.WriteString(fmt.Sprintf("cgroup CPU配额: %.2f 核\n", t.QuotaCPU))
+		} else {
+			builder.WriteString(fmt.Sprintf("cgroup CPU Quota: %.2f CPU(s)\n", t.QuotaCPU))
+		}
+	}
+	return builder.String()
+}
@@ -0,0 +1,24 @@
+package disklatency
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// openDirect opens name with O_DIRECT, falling back to buffered I/O on filesystems such as tmpfs
+func openDirect(name string) (*os.File, bool) {
+	if file, err := os.OpenFile(name, os.O_RDWR|syscall.O_DIRECT, 0o600); err == nil {
+		return file, true
+	}
+	file, err := os.OpenFile(name, os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, false
+	}
+	return file, false
+}
+
+func fdatasync(file *os.File) error {
+	return unix.Fdatasync(int(file.Fd()))
+}
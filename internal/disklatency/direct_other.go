@@ -0,0 +1,18 @@
+//go:build !linux
+
+package disklatency
+
+import "os"
+
+// openDirect opens name with buffered I/O, O_DIRECT is only used on Linux
+func openDirect(name string) (*os.File, bool) {
+	file, err := os.OpenFile(name, os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, false
+	}
+	return file, false
+}
+
+func fdatasync(file *os.File) error {
+	return file.Sync()
+}
@@ -0,0 +1,227 @@
+// Package disklatency measures 4k completion latency percentiles and the fdatasync rate in pure Go
+package disklatency
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/oneclickvirt/ecs/internal/cleanup"
+	"github.com/oneclickvirt/ecs/internal/diskpath"
+)
+
+const (
+	blockSize = 4096
+	// syncWriteSize matches the small appends of an etcd write ahead log
+	syncWriteSize = 2300
+)
+
+// Test size and duration, tests shrink them
+var (
+	// fileSize is the span of the random reads and writes, a multiple of 1 MB
+	fileSize = 128 << 20
+	// phaseDuration and phaseOps bound each of the three phases
+	phaseDuration = 5 * time.Second
+	phaseOps      = 200000
+)
+
+// Percentiles are completion latencies in microseconds
+type Percentiles struct {
+	Ops  int     `json:"ops"`
+	P50  float64 `json:"p50_us"`
+	P95  float64 `json:"p95_us"`
+	P99  float64 `json:"p99_us"`
+	P999 float64 `json:"p999_us"`
+}
+
+// Result is the outcome of the latency and fdatasync test on one path
+type Result struct {
+	Path string `json:"path"`
+	// Direct is false when O_DIRECT is unavailable and the page cache may serve the requests
+	Direct      bool        `json:"direct"`
+	RandomRead  Percentiles `json:"random_read"`
+	RandomWrite Percentiles `json:"random_write"`
+	// SyncPerSecond is the rate of write plus fdatasync pairs, SyncLatency their latency
+	SyncPerSecond float64     `json:"sync_per_second"`
+	SyncLatency   Percentiles `json:"sync_latency"`
+}
+
+// alignedBuffer returns a block of size bytes aligned to blockSize as O_DIRECT requires
+func alignedBuffer(size int) []byte {
+	buffer := make([]byte, size+blockSize)
+	offset := 0
+	if remainder := int(uintptr(unsafe.Pointer(&buffer[0])) % blockSize); remainder != 0 {
+		offset = blockSize - remainder
+	}
+	return buffer[offset : offset+size]
+}
+
+// percentiles sorts samples and picks the nearest rank percentiles
+func percentiles(samples []time.Duration) Percentiles {
+	result := Percentiles{Ops: len(samples)}
+	if len(samples) == 0 {
+		return result
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	pick := func(p float64) float64 {
+		// 减去极小值避免 99.9/100*1000 这类浮点误差多进一位
+		index := int(math.Ceil(p*float64(len(samples))/100-1e-9)) - 1
+		if index < 0 {
+			index = 0
+		}
+		return float64(samples[index].Nanoseconds()) / 1000
+	}
+	result.P50, result.P95, result.P99, result.P999 = pick(50), pick(95), pick(99), pick(99.9)
+	return result
+}
+
+// timed runs op until phaseDuration or phaseOps is reached and returns the latency of every call
+func timed(op func(i int) error) ([]time.Duration, time.Duration, error) {
+	samples := make([]time.Duration, 0, 4096)
+	start := time.Now()
+	for i := 0; i < phaseOps && time.Since(start) < phaseDuration; i++ {
+		opStart := time.Now()
+		if err := op(i); err != nil {
+			return nil, 0, err
+		}
+		samples = append(samples, time.Since(opStart))
+	}
+	return samples, time.Since(start), nil
+}
+
+// Run measures random 4k read and write latency and the fdatasync rate in a temporary file under path
+func Run(path string) (*Result, error) {
+	if path == "" {
+		path = diskpath.Default()
+	}
+	file, err := os.CreateTemp(path, "goecs-latency-*.dat")
+	if err != nil {
+		return nil, err
+	}
+	name := file.Name()
+	file.Close()
+	defer cleanup.Path(name)()
+	result := &Result{Path: path}
+	// 先顺序写满测试文件，随机读才不会落在空洞上
+	buffer := alignedBuffer(1 << 20)
+	for i := range buffer {
+		buffer[i] = byte(rand.Uint32())
+	}
+	file, err = os.OpenFile(name, os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	for written := 0; written < fileSize; written += len(buffer) {
+		if _, err := file.Write(buffer); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	err = file.Sync()
+	file.Close()
+	if err != nil {
+		return nil, err
+	}
+	file, result.Direct = openDirect(name)
+	if file == nil {
+		return nil, fmt.Errorf("open %s failed", name)
+	}
+	block := alignedBuffer(blockSize)
+	blocks := fileSize / blockSize
+	samples, _, err := timed(func(int) error {
+		_, err := file.ReadAt(block, int64(rand.IntN(blocks))*blockSize)
+		return err
+	})
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	result.RandomRead = percentiles(samples)
+	samples, _, err = timed(func(int) error {
+		_, err := file.WriteAt(block, int64(rand.IntN(blocks))*blockSize)
+		return err
+	})
+	file.Close()
+	if err != nil {
+		return nil, err
+	}
+	result.RandomWrite = percentiles(samples)
+	// fdatasync 测试与 etcd 相同，使用普通文件追加小块写入后立即落盘
+	file, err = os.OpenFile(name, os.O_RDWR|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	record := buffer[:syncWriteSize]
+	samples, elapsed, err := timed(func(int) error {
+		if _, err := file.Write(record); err != nil {
+			return err
+		}
+		return fdatasync(file)
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.SyncLatency = percentiles(samples)
+	if elapsed > 0 {
+		result.SyncPerSecond = float64(len(samples)) / elapsed.Seconds()
+	}
+	return result, nil
+}
+
+// Format renders one line per percentile and the fdatasync rate
+func (r *Result) Format(language string) string {
+	if r == nil {
+		return ""
+	}
+	var builder strings.Builder
+	rows := []struct {
+		zh, en string
+		value  Percentiles
+	}{
+		{"4K随机读延迟", "4K Random Read Latency", r.RandomRead},
+		{"4K随机写延迟", "4K Random Write Latency", r.RandomWrite},
+		{"fdatasync延迟", "Fdatasync Latency", r.SyncLatency},
+	}
+	for _, row := range rows {
+		label := row.en
+		if language == "zh" {
+			label = row.zh
+		}
+		for _, p := range []struct {
+			name  string
+			value float64
+		}{{"p50", row.value.P50}, {"p95", row.value.P95}, {"p99", row.value.P99}, {"p99.9", row.value.P999}} {
+			builder.WriteString(fmt.Sprintf("%s %s: %.2f us\n", label, p.name, p.value))
+		}
+	}
+	if language == "zh" {
+		builder.WriteString(fmt.Sprintf("fdatasync速率: %.2f ops/s\n", r.SyncPerSecond))
+		if !r.Direct {
+			builder.WriteString("注意: 无法使用 O_DIRECT，随机读写可能命中页缓存\n")
+		}
+	} else {
+		builder.WriteString(fmt.Sprintf("Fdatasync Rate: %.2f ops/s\n", r.SyncPerSecond))
+		if !r.Direct {
+			builder.WriteString("Note: O_DIRECT is unavailable, random reads and writes may hit the page cache\n")
+		}
+	}
+	return builder.String()
+}
+
+// Test runs the latency test on path and formats the result or the error for the disk section
+func Test(language, path string) (*Result, string) {
+	result, err := Run(path)
+	if err != nil {
+		if language == "zh" {
+			return nil, fmt.Sprintf("延迟测试失败: %v\n", err)
+		}
+		return nil, fmt.Sprintf("Latency test failed: %v\n", err)
+	}
+	return result, result.Format(language)
+}
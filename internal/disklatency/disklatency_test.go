@@ -0,0 +1,57 @@
+package disklatency
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPercentiles(t *testing.T) {
+	samples := make([]time.Duration, 1000)
+	for i := range samples {
+		// 倒序放入，验证会先排序
+		samples[i] = time.Duration(1000-i) * time.Microsecond
+	}
+	result := percentiles(samples)
+	if result.Ops != 1000 || result.P50 != 500 || result.P95 != 950 || result.P99 != 990 || result.P999 != 999 {
+		t.Fatalf("percentiles = %+v", result)
+	}
+	if empty := percentiles(nil); empty.Ops != 0 || empty.P99 != 0 {
+		t.Fatalf("percentiles(nil) = %+v", empty)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	result := &Result{Direct: false, RandomRead: Percentiles{P50: 80, P99: 250.5}, SyncPerSecond: 1234.5}
+	text := result.Format("en")
+	for _, want := range []string{"4K Random Read Latency p99: 250.50 us\n", "Fdatasync Rate: 1234.50 ops/s\n", "O_DIRECT is unavailable"} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("Format = %q, missing %q", text, want)
+		}
+	}
+	if aligned := alignedBuffer(blockSize); len(aligned) != blockSize {
+		t.Fatalf("alignedBuffer length = %d", len(aligned))
+	}
+}
+
+func TestRun(t *testing.T) {
+	size, duration, ops := fileSize, phaseDuration, phaseOps
+	fileSize, phaseDuration, phaseOps = 1<<20, 100*time.Millisecond, 200
+	t.Cleanup(func() { fileSize, phaseDuration, phaseOps = size, duration, ops })
+	dir := t.TempDir()
+	result, err := Run(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Path != dir || result.RandomRead.Ops == 0 || result.RandomWrite.Ops == 0 || result.SyncLatency.Ops == 0 || result.SyncPerSecond <= 0 {
+		t.Fatalf("Run = %+v", result)
+	}
+	if result.RandomRead.Ops > phaseOps || result.SyncLatency.P50 <= 0 {
+		t.Fatalf("Run = %+v", result)
+	}
+	// 测试文件在返回前删除
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+		t.Fatalf("left behind %v, %v", entries, err)
+	}
+}
@@ -0,0 +1,243 @@
+// Package diskpath splits the -diskp list and describes the mount behind each test path
+package diskpath
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/shirou/gopsutil/v4/disk"
+)
+
+// Info describes the filesystem a test path lives on
+type Info struct {
+	Path       string   `json:"path"`
+	MountPoint string   `json:"mount_point,omitempty"`
+	Device     string   `json:"device,omitempty"`
+	FSType     string   `json:"fs_type,omitempty"`
+	Options    []string `json:"options,omitempty"`
+	FreeBytes  uint64   `json:"free_bytes,omitempty"`
+	TotalBytes uint64   `json:"total_bytes,omitempty"`
+	// Issues are the pre-flight findings, see the Issue constants
+	Issues []string `json:"issues,omitempty"`
+}
+
+// Pre-flight findings, the first three make the path unusable
+const (
+	IssueReadOnly    = "read-only"
+	IssueNotWritable = "not-writable"
+	IssueLowSpace    = "low-space"
+	IssueReducedSize = "reduced-size"
+	IssueMemoryFS    = "memory-fs"
+)
+
+// MinFreeBytes is the least free space the disk tests can still run with after shrinking their files
+const MinFreeBytes = 256 << 20
+
+// memoryFS lists filesystems backed by RAM, results there measure memory rather than the disk
+var memoryFS = map[string]bool{"tmpfs": true, "ramfs": true, "devtmpfs": true}
+
+// Default returns the path the fio and dd tests use when -diskp is empty
+func Default() string {
+	if runtime.GOOS == "windows" {
+		if profile := os.Getenv("USERPROFILE"); profile != "" {
+			return profile
+		}
+		return os.TempDir()
+	}
+	return "/root"
+}
+
+// Split parses a comma separated -diskp value, dropping blanks and duplicates
+func Split(value string) []string {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, path := range strings.Split(value, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" || seen[path] {
+			continue
+		}
+		seen[path] = true
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// within reports whether path is mount or below it
+func within(path, mount string) bool {
+	if strings.HasSuffix(mount, ":") {
+		// Windows 盘符形如 "C:"
+		mount += `\`
+	}
+	rel, err := filepath.Rel(mount, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Lookup finds the mount with the longest mount point containing path, fields stay empty when it cannot be found
+func Lookup(path string) Info {
+	info := Info{Path: path}
+	resolved, err := filepath.Abs(path)
+	if err != nil {
+		return info
+	}
+	if real, err := filepath.EvalSymlinks(resolved); err == nil {
+		resolved = real
+	}
+	partitions, _ := disk.Partitions(true)
+	info.fill(resolved, partitions)
+	if usage, err := disk.Usage(resolved); err == nil {
+		info.FreeBytes = usage.Free
+		info.TotalBytes = usage.Total
+	}
+	return info
+}
+
+// fill copies the partition with the longest mount point containing resolved
+func (i *Info) fill(resolved string, partitions []disk.PartitionStat) {
+	best := -1
+	for index, partition := range partitions {
+		if !within(resolved, partition.Mountpoint) {
+			continue
+		}
+		// 同一挂载点被多次挂载时以最后一次为准
+		if best < 0 || len(partition.Mountpoint) >= len(partitions[best].Mountpoint) {
+			best = index
+		}
+	}
+	if best < 0 {
+		return
+	}
+	i.MountPoint = partitions[best].Mountpoint
+	i.Device = partitions[best].Device
+	i.FSType = partitions[best].Fstype
+	i.Options = partitions[best].Opts
+}
+
+// Preflight checks the mount options, filesystem type, writability and free space against requiredBytes,
+// it records the findings in Issues and returns false when the path cannot be tested
+func (i *Info) Preflight(requiredBytes uint64) bool {
+	i.Issues = nil
+	for _, option := range i.Options {
+		if option == "ro" {
+			i.Issues = append(i.Issues, IssueReadOnly)
+			return false
+		}
+	}
+	// 路径不存在时由测试自行创建，只检查已存在的目录
+	if stat, err := os.Stat(i.Path); err == nil && stat.IsDir() {
+		file, err := os.CreateTemp(i.Path, "goecs-preflight-*")
+		if err != nil {
+			i.Issues = append(i.Issues, IssueNotWritable)
+			return false
+		}
+		file.Close()
+		os.Remove(file.Name())
+	}
+	if i.TotalBytes > 0 && i.FreeBytes < MinFreeBytes {
+		i.Issues = append(i.Issues, IssueLowSpace)
+		return false
+	}
+	// 与测试库相同，剩余空间不足所需的 1.5 倍时测试文件会被缩小
+	if i.TotalBytes > 0 && i.FreeBytes < requiredBytes*3/2 {
+		i.Issues = append(i.Issues, IssueReducedSize)
+	}
+	if memoryFS[i.FSType] {
+		i.Issues = append(i.Issues, IssueMemoryFS)
+	}
+	return true
+}
+
+// FormatIssues renders one warning line per pre-flight finding, skipped tells whether a path that failed
+// the pre-flight is skipped or still tested, as the default path is
+func (i Info) FormatIssues(language string, skipped bool) string {
+	var builder strings.Builder
+	zhOutcome, enOutcome := "跳过测试", "skipped"
+	if !skipped {
+		zhOutcome, enOutcome = "测试可能失败", "the test may fail"
+	}
+	for _, issue := range i.Issues {
+		var zh, en string
+		switch issue {
+		case IssueReadOnly:
+			zh, en = "以只读方式挂载，"+zhOutcome, "is mounted read-only, "+enOutcome
+		case IssueNotWritable:
+			zh, en = "不可写入，"+zhOutcome, "is not writable, "+enOutcome
+		case IssueLowSpace:
+			zh = fmt.Sprintf("剩余空间 %s 低于 %s，%s", FormatBytes(i.FreeBytes), FormatBytes(MinFreeBytes), zhOutcome)
+			en = fmt.Sprintf("has %s free, below %s, %s", FormatBytes(i.FreeBytes), FormatBytes(MinFreeBytes), enOutcome)
+		case IssueReducedSize:
+			zh = fmt.Sprintf("剩余空间 %s 不足，测试文件将被缩小，结果可能偏高", FormatBytes(i.FreeBytes))
+			en = fmt.Sprintf("has only %s free, test files are reduced and results may be inflated by caches", FormatBytes(i.FreeBytes))
+		case IssueMemoryFS:
+			zh = fmt.Sprintf("位于内存文件系统 %s，结果反映的是内存而非硬盘", i.FSType)
+			en = fmt.Sprintf("is on the memory filesystem %s, results measure memory rather than the disk", i.FSType)
+		default:
+			continue
+		}
+		if language == "zh" {
+			builder.WriteString(fmt.Sprintf("注意: 路径 %s %s\n", i.Path, zh))
+		} else {
+			builder.WriteString(fmt.Sprintf("Warning: path %s %s\n", i.Path, en))
+		}
+	}
+	return builder.String()
+}
+
+// FormatBytes renders a byte count with a binary unit such as "1.5 GB"
+func FormatBytes(bytes uint64) string {
+	const unit = 1024
+	value := float64(bytes)
+	for _, suffix := range []string{"B", "KB", "MB", "GB"} {
+		if value < unit {
+			return fmt.Sprintf("%.1f %s", value, suffix)
+		}
+		value /= unit
+	}
+	return fmt.Sprintf("%.1f TB", value)
+}
+
+// Format renders the header line shown above the results of a path
+func (i Info) Format(language string) string {
+	var parts []string
+	if i.Device != "" {
+		if language == "zh" {
+			parts = append(parts, "设备 "+i.Device)
+		} else {
+			parts = append(parts, "device "+i.Device)
+		}
+	}
+	if i.MountPoint != "" && i.MountPoint != i.Path {
+		if language == "zh" {
+			parts = append(parts, "挂载于 "+i.MountPoint)
+		} else {
+			parts = append(parts, "mounted on "+i.MountPoint)
+		}
+	}
+	if i.FSType != "" {
+		if language == "zh" {
+			parts = append(parts, "文件系统 "+i.FSType)
+		} else {
+			parts = append(parts, "filesystem "+i.FSType)
+		}
+	}
+	if len(i.Options) > 0 {
+		if language == "zh" {
+			parts = append(parts, "挂载选项 "+strings.Join(i.Options, ","))
+		} else {
+			parts = append(parts, "options "+strings.Join(i.Options, ","))
+		}
+	}
+	if i.TotalBytes > 0 {
+		if language == "zh" {
+			parts = append(parts, fmt.Sprintf("可用 %s / %s", FormatBytes(i.FreeBytes), FormatBytes(i.TotalBytes)))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s free of %s", FormatBytes(i.FreeBytes), FormatBytes(i.TotalBytes)))
+		}
+	}
+	if len(parts) == 0 {
+		return "[" + i.Path + "]\n"
+	}
+	return "[" + i.Path + "] " + strings.Join(parts, ", ") + "\n"
+}
@@ -0,0 +1,56 @@
+package diskpath
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/shirou/gopsutil/v4/disk"
+)
+
+func TestSplit(t *testing.T) {
+	if paths := fmt.Sprint(Split(" /, /data ,,/mnt/nvme,/data")); paths != "[/ /data /mnt/nvme]" {
+		t.Fatalf("Split = %s", paths)
+	}
+}
+
+func TestFill(t *testing.T) {
+	partitions := []disk.PartitionStat{
+		{Device: "/dev/vda1", Mountpoint: "/", Fstype: "ext4", Opts: []string{"rw", "relatime"}},
+		{Device: "/dev/nvme0n1", Mountpoint: "/mnt/nvme", Fstype: "xfs", Opts: []string{"rw", "noatime"}},
+		{Device: "/dev/vdb", Mountpoint: "/mnt/nvmex", Fstype: "ext4"},
+	}
+	info := Info{Path: "/mnt/nvme/bench"}
+	info.fill("/mnt/nvme/bench", partitions)
+	if info.Device != "/dev/nvme0n1" || info.FSType != "xfs" {
+		t.Fatalf("fill = %+v", info)
+	}
+	info.FreeBytes, info.TotalBytes = 100<<30, 400<<30
+	want := "[/mnt/nvme/bench] device /dev/nvme0n1, mounted on /mnt/nvme, filesystem xfs, options rw,noatime, 100.0 GB free of 400.0 GB\n"
+	if got := info.Format("en"); got != want {
+		t.Fatalf("Format = %q, want %q", got, want)
+	}
+	root := Info{Path: "/srv"}
+	root.fill("/srv", partitions)
+	if root.MountPoint != "/" {
+		t.Fatalf("fill /srv = %+v", root)
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	dir := t.TempDir()
+	info := Info{Path: dir, FSType: "tmpfs", FreeBytes: 1 << 30, TotalBytes: 4 << 30}
+	if !info.Preflight(2<<30) || fmt.Sprint(info.Issues) != "[reduced-size memory-fs]" {
+		t.Fatalf("Preflight = %v", info.Issues)
+	}
+	info.FreeBytes = 100 << 20
+	if info.Preflight(2<<30) || fmt.Sprint(info.Issues) != "[low-space]" {
+		t.Fatalf("Preflight low space = %v", info.Issues)
+	}
+	readOnly := Info{Path: dir, Options: []string{"ro", "relatime"}}
+	if readOnly.Preflight(0) || readOnly.FormatIssues("en", true) != "Warning: path "+dir+" is mounted read-only, skipped\n" {
+		t.Fatalf("Preflight read-only = %q", readOnly.FormatIssues("en", true))
+	}
+	if text := readOnly.FormatIssues("en", false); text != "Warning: path "+dir+" is mounted read-only, the test may fail\n" {
+		t.Fatalf("FormatIssues of a tested path = %q", text)
+	}
+}
@@ -0,0 +1,192 @@
+package dnsbench
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// verifyAnswer reports whether ip serves a certificate valid for domain, which tells a CDN answer of another
+// region apart from a forged one, tests replace it
+var verifyAnswer = func(domain, ip string) bool {
+	dialer := &net.Dialer{Timeout: 3 * time.Second}
+	conn, err := tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(ip, "443"), &tls.Config{ServerName: domain})
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// bogon reports addresses a public domain never resolves to
+func bogon(address string) bool {
+	ip := net.ParseIP(address)
+	return ip == nil || ip.IsPrivate() || ip.IsLoopback() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsMulticast()
+}
+
+func anyBogon(ips []string) bool {
+	for _, ip := range ips {
+		if bogon(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// detectPollution flags plain answers of the check domains that contain bogons, or whose address does not present
+// a valid certificate for the domain. Every answer is checked since domestic resolvers serve the forged records
+// themselves, even over DoT/DoH, so agreement between resolvers proves nothing
+func detectPollution(results []*ResolverResult) {
+	type answer struct{ domain, ip string }
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	// 多个解析器返回同一地址时只握手一次
+	verified := make(map[answer]bool)
+	var pending []answer
+	for _, domain := range checkDomains {
+		for _, r := range results {
+			ips := r.answers[domain]
+			if len(ips) == 0 || anyBogon(ips) {
+				continue
+			}
+			if key := (answer{domain, ips[0]}); !slices.Contains(pending, key) {
+				pending = append(pending, key)
+			}
+		}
+	}
+	for _, key := range pending {
+		wg.Add(1)
+		go func(key answer) {
+			defer wg.Done()
+			// 不同地区的 CDN 解析结果不同，证书有效即视为正常
+			ok := verifyAnswer(key.domain, key.ip)
+			mu.Lock()
+			verified[key] = ok
+			mu.Unlock()
+		}(key)
+	}
+	wg.Wait()
+	for _, domain := range checkDomains {
+		for _, r := range results {
+			ips := r.answers[domain]
+			if len(ips) == 0 {
+				continue
+			}
+			if !anyBogon(ips) && verified[answer{domain, ips[0]}] {
+				continue
+			}
+			r.Issues = append(r.Issues, Issue{Kind: IssuePolluted, Domain: domain, Answers: ips})
+		}
+	}
+}
+
+func cell(text string, width int) string {
+	return runewidth.FillLeft(text, width)
+}
+
+func formatMs(ms float64, ok bool) string {
+	if !ok {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f", ms)
+}
+
+func formatTransport(transport *Transport, language string) string {
+	switch {
+	case transport == nil:
+		return "-"
+	case transport.Available:
+		return fmt.Sprintf("%.0f", transport.LatencyMs)
+	case language == "zh":
+		return "不可用"
+	}
+	return "no"
+}
+
+// displayAddress drops the default port to keep the column short
+func displayAddress(address string) string {
+	if host, port, err := net.SplitHostPort(address); err == nil && port == "53" {
+		return host
+	}
+	return address
+}
+
+// Format renders one row per resolver followed by the hijacking and pollution findings
+func Format(result *Result, language string) string {
+	var builder strings.Builder
+	zh := language == "zh"
+	headers := []string{"Resolver (ms)", "Address", "Success", "Avg", "P95", "DNSSEC", "DoT", "DoH"}
+	if zh {
+		headers = []string{"解析器 (ms)", "地址", "成功率", "平均", "P95", "DNSSEC", "DoT", "DoH"}
+	}
+	widths := []int{14, 22, 8, 7, 7, 9, 7, 7}
+	builder.WriteString(runewidth.FillRight(headers[0], widths[0]) + runewidth.FillRight(headers[1], widths[1]))
+	for i := 2; i < len(headers); i++ {
+		builder.WriteString(cell(headers[i], widths[i]))
+	}
+	builder.WriteString("\n")
+	for _, resolver := range result.Resolvers {
+		name := resolver.Name
+		if resolver.System && zh {
+			name = "系统"
+		}
+		dnssec := "-"
+		switch {
+		case resolver.DNSSEC == "validating" && zh:
+			dnssec = "验证"
+		case resolver.DNSSEC == "validating":
+			dnssec = "yes"
+		case resolver.DNSSEC != "" && zh:
+			dnssec = "不验证"
+		case resolver.DNSSEC != "":
+			dnssec = "no"
+		}
+		answered := resolver.Answered > 0
+		values := []string{
+			fmt.Sprintf("%.0f%%", resolver.Success),
+			formatMs(resolver.AvgMs, answered),
+			formatMs(resolver.P95Ms, answered),
+			dnssec,
+			formatTransport(resolver.DoTResult, language),
+			formatTransport(resolver.DoHResult, language),
+		}
+		builder.WriteString(runewidth.FillRight(runewidth.Truncate(name, widths[0]-1, ""), widths[0]))
+		builder.WriteString(runewidth.FillRight(displayAddress(resolver.Address), widths[1]))
+		for i, value := range values {
+			builder.WriteString(cell(value, widths[i+2]))
+		}
+		builder.WriteString("\n")
+	}
+	var findings []string
+	for _, resolver := range result.Resolvers {
+		name := fmt.Sprintf("%s %s", resolver.Name, displayAddress(resolver.Address))
+		for _, issue := range resolver.Issues {
+			answers := strings.Join(issue.Answers, " ")
+			switch {
+			case issue.Kind == IssueNXDomainHijack && zh:
+				findings = append(findings, fmt.Sprintf("[%s] 不存在的域名被解析到 %s，疑似 DNS 劫持", name, answers))
+			case issue.Kind == IssueNXDomainHijack:
+				findings = append(findings, fmt.Sprintf("[%s] a nonexistent domain resolves to %s, DNS hijacking suspected", name, answers))
+			case zh:
+				findings = append(findings, fmt.Sprintf("[%s] %s 解析为 %s，与其他结果不一致，疑似 DNS 污染", name, issue.Domain, answers))
+			default:
+				findings = append(findings, fmt.Sprintf("[%s] %s resolves to %s, inconsistent with the other answers, DNS pollution suspected", name, issue.Domain, answers))
+			}
+		}
+	}
+	if len(findings) == 0 {
+		if zh {
+			findings = append(findings, "未发现 DNS 劫持或污染")
+		} else {
+			findings = append(findings, "No DNS hijacking or pollution found")
+		}
+	}
+	builder.WriteString(strings.Join(findings, "\n") + "\n")
+	return builder.String()
+}
@@ -0,0 +1,332 @@
+// Package dnsbench measures latency, success rate, DNSSEC validation and DoT/DoH availability of DNS resolvers
+// and looks for hijacked or polluted answers by comparing them across resolvers
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/oneclickvirt/ecs/internal/flaglist"
+)
+
+// queryTimeout bounds one query on any transport
+const queryTimeout = 2 * time.Second
+
+// Resolver is one resolver to benchmark
+type Resolver struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`       // 明文 DNS 的 host:port
+	DoT     string `json:"dot,omitempty"` // DNS over TLS 的 host:port，证书按 host 校验
+	DoH     string `json:"doh,omitempty"` // DNS over HTTPS 的地址
+	System  bool   `json:"system,omitempty"`
+}
+
+// PublicResolvers is the default list compared with the system resolver
+var PublicResolvers = []Resolver{
+	{Name: "AliDNS", Address: "223.5.5.5:53", DoT: "dns.alidns.com:853", DoH: "https://dns.alidns.com/dns-query"},
+	{Name: "DNSPod", Address: "119.29.29.29:53", DoT: "dot.pub:853", DoH: "https://doh.pub/dns-query"},
+	{Name: "114DNS", Address: "114.114.114.114:53"},
+	{Name: "Google", Address: "8.8.8.8:53", DoT: "dns.google:853", DoH: "https://dns.google/dns-query"},
+	{Name: "Cloudflare", Address: "1.1.1.1:53", DoT: "one.one.one.one:853", DoH: "https://cloudflare-dns.com/dns-query"},
+	{Name: "Quad9", Address: "9.9.9.9:53", DoT: "dns.quad9.net:853", DoH: "https://dns.quad9.net/dns-query"},
+}
+
+// benchmarkDomains are resolved by every resolver to measure latency and success rate
+var benchmarkDomains = []string{
+	"baidu.com", "qq.com", "taobao.com", "bilibili.com", "jd.com", "apple.com",
+	"microsoft.com", "github.com", "cloudflare.com", "google.com", "youtube.com", "facebook.com",
+	"twitter.com", "wikipedia.org",
+}
+
+// checkDomains are commonly polluted, their answers are compared across resolvers
+var checkDomains = []string{"google.com", "youtube.com", "facebook.com", "twitter.com", "wikipedia.org"}
+
+// DNSSEC test domains, a validating resolver sets AD for the signed one and fails the broken one
+const (
+	dnssecSigned = "isc.org"
+	dnssecBroken = "dnssec-failed.org"
+)
+
+// ParseList parses the -dns-servers value, IPs with an optional port separated by commas,
+// each optionally followed by "=label", invalid items are returned separately.
+// DoT is tried on port 853 and DoH on https://IP/dns-query of each server.
+func ParseList(value string) (resolvers []Resolver, invalid []string) {
+	for _, item := range flaglist.Split(value) {
+		host, port := flaglist.HostPort(item.Value, "53")
+		if net.ParseIP(host) == nil {
+			invalid = append(invalid, item.Raw)
+			continue
+		}
+		label := item.Label
+		if label == "" {
+			label = host
+		}
+		resolvers = append(resolvers, Resolver{
+			Name:    label,
+			Address: net.JoinHostPort(host, port),
+			DoT:     net.JoinHostPort(host, "853"),
+			DoH:     "https://" + net.JoinHostPort(host, "443") + "/dns-query",
+		})
+	}
+	return resolvers, invalid
+}
+
+// SystemResolvers returns the nameservers of resolv.conf, empty where there is none such as on Windows
+func SystemResolvers() []Resolver {
+	config, err := dns.ClientConfigFromFile("/etc/resolv.conf")
+	if err != nil {
+		return nil
+	}
+	var resolvers []Resolver
+	for _, server := range config.Servers {
+		resolvers = append(resolvers, Resolver{Name: "System", Address: net.JoinHostPort(server, config.Port), System: true})
+	}
+	return resolvers
+}
+
+// Transport is the outcome of one query over DoT or DoH, including the handshake
+type Transport struct {
+	Available bool    `json:"available"`
+	LatencyMs float64 `json:"latency_ms,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Issue is a suspicious answer of a resolver
+type Issue struct {
+	Kind    string   `json:"kind"` // nxdomain_hijack 或 polluted
+	Domain  string   `json:"domain"`
+	Answers []string `json:"answers"`
+}
+
+// Issue kinds
+const (
+	IssueNXDomainHijack = "nxdomain_hijack"
+	IssuePolluted       = "polluted"
+)
+
+// ResolverResult is the benchmark of one resolver
+type ResolverResult struct {
+	Resolver
+	Queries  int     `json:"queries"`
+	Answered int     `json:"answered"`
+	Success  float64 `json:"success_percent"`
+	AvgMs    float64 `json:"avg_ms"`
+	P95Ms    float64 `json:"p95_ms"`
+	// DNSSEC is "validating", "not_validating" or empty when the test queries got no answer
+	DNSSEC    string     `json:"dnssec,omitempty"`
+	DoTResult *Transport `json:"dot_result,omitempty"`
+	DoHResult *Transport `json:"doh_result,omitempty"`
+	Issues    []Issue    `json:"issues,omitempty"`
+
+	answers   map[string][]string // 明文查询的 A 记录
+}
+
+// Result is the benchmark of every resolver
+type Result struct {
+	Resolvers []*ResolverResult `json:"resolvers"`
+}
+
+func newQuery(name string, qtype uint16) *dns.Msg {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(name), qtype)
+	return msg
+}
+
+// exchangeUDP sends a plain query over UDP and retries over TCP when the answer is truncated
+func exchangeUDP(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+	client := &dns.Client{Net: "udp", Timeout: queryTimeout}
+	response, rtt, err := client.Exchange(msg, address)
+	if err == nil && response.Truncated {
+		client.Net = "tcp"
+		return client.Exchange(msg, address)
+	}
+	return response, rtt, err
+}
+
+// exchangeDoT sends a query over a new TLS connection
+func exchangeDoT(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, 0, err
+	}
+	client := &dns.Client{Net: "tcp-tls", Timeout: queryTimeout, TLSConfig: &tls.Config{ServerName: host}}
+	start := time.Now()
+	response, _, err := client.Exchange(msg, address)
+	return response, time.Since(start), err
+}
+
+// exchangeDoH posts a query in wire format as RFC 8484 describes, over a new connection
+func exchangeDoH(msg *dns.Msg, url string) (*dns.Msg, time.Duration, error) {
+	query := msg.Copy()
+	query.Id = 0
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, 0, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(packed))
+	if err != nil {
+		return nil, 0, err
+	}
+	request.Header.Set("Content-Type", "application/dns-message")
+	request.Header.Set("Accept", "application/dns-message")
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, DisableKeepAlives: true}}
+	start := time.Now()
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(response.Body, 64<<10))
+	elapsed := time.Since(start)
+	if err != nil {
+		return nil, 0, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("HTTP %s", response.Status)
+	}
+	answer := new(dns.Msg)
+	if err := answer.Unpack(body); err != nil {
+		return nil, 0, err
+	}
+	return answer, elapsed, nil
+}
+
+// addresses returns the A records of an answer
+func addresses(msg *dns.Msg) []string {
+	var ips []string
+	for _, rr := range msg.Answer {
+		if a, ok := rr.(*dns.A); ok {
+			ips = append(ips, a.A.String())
+		}
+	}
+	sort.Strings(ips)
+	return ips
+}
+
+// randomName returns a name that does not exist, used to detect resolvers answering NXDOMAIN with an address
+func randomName() string {
+	random := make([]byte, 8)
+	rand.Read(random)
+	return "goecs-" + hex.EncodeToString(random) + ".com"
+}
+
+// dnssec queries the signed and the broken test domain with the DO bit
+func dnssec(address string) string {
+	signed := newQuery(dnssecSigned, dns.TypeA)
+	signed.SetEdns0(4096, true)
+	signed.AuthenticatedData = true
+	broken := newQuery(dnssecBroken, dns.TypeA)
+	broken.SetEdns0(4096, true)
+	good, _, err1 := exchangeUDP(signed, address)
+	bad, _, err2 := exchangeUDP(broken, address)
+	if err1 != nil || err2 != nil {
+		return ""
+	}
+	if good.AuthenticatedData && bad.Rcode == dns.RcodeServerFailure {
+		return "validating"
+	}
+	return "not_validating"
+}
+
+// encryptedTransport checks one transport with the first check domain
+func encryptedTransport(endpoint string, exchange func(*dns.Msg, string) (*dns.Msg, time.Duration, error)) *Transport {
+	if endpoint == "" {
+		return nil
+	}
+	_, elapsed, err := exchange(newQuery(checkDomains[0], dns.TypeA), endpoint)
+	if err != nil {
+		return &Transport{Error: err.Error()}
+	}
+	return &Transport{Available: true, LatencyMs: float64(elapsed.Microseconds()) / 1000}
+}
+
+// Benchmark measures one resolver, the pollution check needs the results of all resolvers and is done by Run
+func Benchmark(resolver Resolver) *ResolverResult {
+	result := &ResolverResult{Resolver: resolver, answers: make(map[string][]string)}
+	var latencies []float64
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, domain := range benchmarkDomains {
+		wg.Add(1)
+		go func(domain string) {
+			defer wg.Done()
+			response, rtt, err := exchangeUDP(newQuery(domain, dns.TypeA), resolver.Address)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil || response.Rcode != dns.RcodeSuccess {
+				return
+			}
+			latencies = append(latencies, float64(rtt.Microseconds())/1000)
+			result.answers[domain] = addresses(response)
+		}(domain)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		name := randomName()
+		response, _, err := exchangeUDP(newQuery(name, dns.TypeA), resolver.Address)
+		if err == nil && response.Rcode == dns.RcodeSuccess && len(addresses(response)) > 0 {
+			mu.Lock()
+			result.Issues = append(result.Issues, Issue{Kind: IssueNXDomainHijack, Domain: name, Answers: addresses(response)})
+			mu.Unlock()
+		}
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		result.DNSSEC = dnssec(resolver.Address)
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		dot := encryptedTransport(resolver.DoT, exchangeDoT)
+		doh := encryptedTransport(resolver.DoH, exchangeDoH)
+		mu.Lock()
+		result.DoTResult, result.DoHResult = dot, doh
+		mu.Unlock()
+	}()
+	wg.Wait()
+
+	result.Queries, result.Answered = len(benchmarkDomains), len(latencies)
+	result.Success = float64(result.Answered) * 100 / float64(result.Queries)
+	if len(latencies) > 0 {
+		sort.Float64s(latencies)
+		var sum float64
+		for _, latency := range latencies {
+			sum += latency
+		}
+		result.AvgMs = sum / float64(len(latencies))
+		result.P95Ms = latencies[int(math.Ceil(0.95*float64(len(latencies))))-1]
+	}
+	return result
+}
+
+// Run benchmarks every resolver concurrently and then compares their answers
+func Run(resolvers []Resolver) *Result {
+	result := &Result{Resolvers: make([]*ResolverResult, len(resolvers))}
+	var wg sync.WaitGroup
+	for i, resolver := range resolvers {
+		wg.Add(1)
+		go func(i int, resolver Resolver) {
+			defer wg.Done()
+			result.Resolvers[i] = Benchmark(resolver)
+		}(i, resolver)
+	}
+	wg.Wait()
+	detectPollution(result.Resolvers)
+	return result
+}
@@ -0,0 +1,153 @@
+package dnsbench
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// answer builds the reply of a stub resolver, known names resolve to ip and other names to hijack if set
+func answer(query *dns.Msg, ip func(name string) string, hijack string) *dns.Msg {
+	reply := new(dns.Msg)
+	reply.SetReply(query)
+	name := strings.TrimSuffix(query.Question[0].Name, ".")
+	address := ip(name)
+	known := address != ""
+	for _, domain := range append(benchmarkDomains, dnssecSigned, dnssecBroken) {
+		known = known || domain == name
+	}
+	switch {
+	case !known && hijack == "":
+		reply.Rcode = dns.RcodeNameError
+		return reply
+	case !known:
+		address = hijack
+	case address == "":
+		address = "93.184.216.34"
+	}
+	reply.Answer = append(reply.Answer, &dns.A{
+		Hdr: dns.RR_Header{Name: query.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+		A:   net.ParseIP(address),
+	})
+	return reply
+}
+
+// stub starts a UDP resolver on a random local port
+func stub(t *testing.T, ip func(name string) string, hijack string) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &dns.Server{PacketConn: conn, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, query *dns.Msg) {
+		w.WriteMsg(answer(query, ip, hijack))
+	})}
+	go server.ActivateAndServe()
+	t.Cleanup(func() { server.Shutdown() })
+	return conn.LocalAddr().String()
+}
+
+func honest(string) string { return "" }
+
+// fakeVerify replaces the certificate check until the test ends
+func fakeVerify(t *testing.T, verify func(domain, ip string) bool) {
+	original := verifyAnswer
+	verifyAnswer = verify
+	t.Cleanup(func() { verifyAnswer = original })
+}
+
+func TestRun(t *testing.T) {
+	// 只有真实地址能提供有效证书
+	fakeVerify(t, func(_, ip string) bool { return ip == "93.184.216.34" })
+	doh := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		query := new(dns.Msg)
+		if r.Header.Get("Content-Type") != "application/dns-message" || query.Unpack(body) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		packed, _ := answer(query, honest, "").Pack()
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(packed)
+	}))
+	defer doh.Close()
+	polluted := func(name string) string {
+		for _, domain := range checkDomains {
+			if domain == name {
+				return "203.0.113.7"
+			}
+		}
+		return ""
+	}
+	result := Run([]Resolver{
+		{Name: "honest", Address: stub(t, honest, ""), DoH: doh.URL + "/dns-query"},
+		{Name: "plain", Address: stub(t, honest, "")},
+		{Name: "polluter", Address: stub(t, polluted, "198.51.100.1")},
+		{Name: "down", Address: "127.0.0.1:1"},
+	})
+	first, second, third, down := result.Resolvers[0], result.Resolvers[1], result.Resolvers[2], result.Resolvers[3]
+	if first.Success != 100 || first.Answered != len(benchmarkDomains) || first.DNSSEC != "not_validating" {
+		t.Fatalf("honest = %+v", first)
+	}
+	if first.DoHResult == nil || !first.DoHResult.Available || first.DoTResult != nil {
+		t.Fatalf("DoH = %+v, DoT = %+v", first.DoHResult, first.DoTResult)
+	}
+	if len(first.Issues) != 0 || len(second.Issues) != 0 || down.Success != 0 || len(down.Issues) != 0 {
+		t.Fatalf("issues = %+v %+v %+v", first.Issues, second.Issues, down.Issues)
+	}
+	kinds := make(map[string]int)
+	for _, issue := range third.Issues {
+		kinds[issue.Kind]++
+	}
+	if kinds[IssueNXDomainHijack] != 1 || kinds[IssuePolluted] != len(checkDomains) {
+		t.Fatalf("polluter issues = %+v", third.Issues)
+	}
+	text := Format(result, "en")
+	if !strings.Contains(text, "DNS hijacking suspected") || !strings.Contains(text, "google.com resolves to 203.0.113.7") {
+		t.Fatalf("Format = %s", text)
+	}
+}
+
+func TestBogon(t *testing.T) {
+	fakeVerify(t, func(string, string) bool { return true })
+	results := []*ResolverResult{
+		{answers: map[string][]string{"google.com": {"127.0.0.1"}}},
+		{answers: map[string][]string{"google.com": {"142.250.1.1"}}},
+	}
+	detectPollution(results)
+	// 证书有效也不能掩盖回环地址
+	if len(results[0].Issues) != 1 || len(results[1].Issues) != 0 {
+		t.Fatalf("issues = %+v, %+v", results[0].Issues, results[1].Issues)
+	}
+	// 所有解析器返回相同的伪造地址时仍需报告
+	fakeVerify(t, func(string, string) bool { return false })
+	results = []*ResolverResult{
+		{answers: map[string][]string{"google.com": {"203.0.113.7"}}},
+		{answers: map[string][]string{"google.com": {"203.0.113.7"}}},
+	}
+	detectPollution(results)
+	if len(results[0].Issues) != 1 || len(results[1].Issues) != 1 {
+		t.Fatalf("issues = %+v, %+v", results[0].Issues, results[1].Issues)
+	}
+}
+
+func TestParseList(t *testing.T) {
+	resolvers, invalid := ParseList("1.1.1.1, 10.0.0.2:5353=office, [2001:db8::1]=v6, dns.google")
+	if len(invalid) != 1 || invalid[0] != "dns.google" || len(resolvers) != 3 {
+		t.Fatalf("resolvers = %+v, invalid = %q", resolvers, invalid)
+	}
+	want := []Resolver{
+		{Name: "1.1.1.1", Address: "1.1.1.1:53", DoT: "1.1.1.1:853", DoH: "https://1.1.1.1:443/dns-query"},
+		{Name: "office", Address: "10.0.0.2:5353", DoT: "10.0.0.2:853", DoH: "https://10.0.0.2:443/dns-query"},
+		{Name: "v6", Address: "[2001:db8::1]:53", DoT: "[2001:db8::1]:853", DoH: "https://[2001:db8::1]:443/dns-query"},
+	}
+	for i := range want {
+		if resolvers[i] != want[i] {
+			t.Errorf("resolver %d = %+v, want %+v", i, resolvers[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,232 @@
+// Package fioprofile runs fio job files against the disk and parses fio's JSON output
+package fioprofile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/oneclickvirt/ecs/internal/cleanup"
+	"github.com/oneclickvirt/ecs/internal/diskpath"
+	"github.com/oneclickvirt/fio"
+)
+
+// timeout bounds a whole profile so a runaway job file cannot hang the run
+const timeout = 15 * time.Minute
+
+// Stats is the result of one direction of a job
+type Stats struct {
+	BandwidthMBps float64 `json:"bandwidth_mbps"`
+	IOPS          float64 `json:"iops"`
+	LatencyMeanUs float64 `json:"latency_mean_us"`
+}
+
+// Job is the result of one fio reporting group
+type Job struct {
+	Name  string `json:"name"`
+	Read  Stats  `json:"read"`
+	Write Stats  `json:"write"`
+}
+
+// Result is the outcome of a profile on one path
+type Result struct {
+	Profile string `json:"profile"`
+	Path    string `json:"path"`
+	Jobs    []Job  `json:"jobs"`
+}
+
+// Exists reports whether profile is a built-in name or a readable job file
+func Exists(profile string) bool {
+	if _, ok := profiles[profile]; ok {
+		return true
+	}
+	info, err := os.Stat(profile)
+	return err == nil && !info.IsDir()
+}
+
+// jobFile returns the content of a built-in profile or of a job file
+func jobFile(profile string) ([]byte, error) {
+	if content, ok := profiles[profile]; ok {
+		return []byte(content), nil
+	}
+	return os.ReadFile(profile)
+}
+
+// ioEngine returns the asynchronous engine of the platform for the built-in profiles
+func ioEngine() string {
+	switch runtime.GOOS {
+	case "linux":
+		return "libaio"
+	case "windows":
+		return "windowsaio"
+	}
+	return "posixaio"
+}
+
+// expand replaces ${FIO_IOENGINE} and ${FIO_SIZE} in a job file, the values are written into the file because
+// fio is often started through sudo, which drops the environment
+func expand(content []byte) []byte {
+	size := "1G"
+	if runtime.GOARCH == "arm64" || runtime.GOARCH == "arm" {
+		size = "512M"
+	}
+	return []byte(strings.NewReplacer("${FIO_IOENGINE}", ioEngine(), "${FIO_SIZE}", size).Replace(string(content)))
+}
+
+// Run executes profile in a temporary directory under path and parses the JSON output
+func Run(profile, path string) (*Result, error) {
+	content, err := jobFile(profile)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		path = diskpath.Default()
+	}
+	// 在临时子目录中运行，作业文件未指定绝对路径时产生的文件随目录一起清理
+	dir, err := os.MkdirTemp(path, "goecs-fio-")
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup.Path(dir)()
+	jobPath := dir + string(os.PathSeparator) + "profile.fio"
+	if err := os.WriteFile(jobPath, expand(content), 0o644); err != nil {
+		return nil, err
+	}
+	fioCmd, fioPath, err := fio.GetFIO()
+	defer fio.CleanFio(fioPath)
+	if err != nil || fioCmd == "" {
+		return nil, fmt.Errorf("fio is not available: %v", err)
+	}
+	args := strings.Fields(fioCmd)
+	args = append(args, "--output-format=json", jobPath)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("fio failed: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("fio failed: %w", err)
+	}
+	jobs, err := Parse(output)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Profile: profile, Path: path, Jobs: jobs}, nil
+}
+
+type fioDirection struct {
+	BwBytes float64 `json:"bw_bytes"`
+	IOPS    float64 `json:"iops"`
+	LatNs   struct {
+		Mean float64 `json:"mean"`
+	} `json:"lat_ns"`
+}
+
+type fioOutput struct {
+	Jobs []struct {
+		Jobname string       `json:"jobname"`
+		Error   int          `json:"error"`
+		Read    fioDirection `json:"read"`
+		Write   fioDirection `json:"write"`
+	} `json:"jobs"`
+}
+
+func (d fioDirection) stats() Stats {
+	return Stats{
+		BandwidthMBps: d.BwBytes / (1 << 20),
+		IOPS:          d.IOPS,
+		LatencyMeanUs: d.LatNs.Mean / 1000,
+	}
+}
+
+// Parse reads fio's JSON output, skipping any warnings printed before it
+func Parse(output []byte) ([]Job, error) {
+	start := strings.IndexByte(string(output), '{')
+	if start < 0 {
+		return nil, errors.New("fio printed no JSON output")
+	}
+	var parsed fioOutput
+	if err := json.Unmarshal(output[start:], &parsed); err != nil {
+		return nil, fmt.Errorf("parse fio output: %w", err)
+	}
+	var jobs []Job
+	for _, job := range parsed.Jobs {
+		if job.Error != 0 {
+			return nil, fmt.Errorf("fio job %s failed with error %d", job.Jobname, job.Error)
+		}
+		jobs = append(jobs, Job{Name: job.Jobname, Read: job.Read.stats(), Write: job.Write.stats()})
+	}
+	if len(jobs) == 0 {
+		return nil, errors.New("fio reported no jobs")
+	}
+	return jobs, nil
+}
+
+// formatSpeed renders MB/s the way the fio table of the disk test does
+func formatSpeed(mbps float64) string {
+	if mbps >= 1024 {
+		return fmt.Sprintf("%.2f GB/s", mbps/1024)
+	}
+	return fmt.Sprintf("%.2f MB/s", mbps)
+}
+
+func formatIOPS(iops float64) string {
+	if iops >= 1000 {
+		return fmt.Sprintf("%.2fk", iops/1000)
+	}
+	return fmt.Sprintf("%.0f", iops)
+}
+
+// Format renders the jobs with the same columns as the fio table of the disk test
+func (r *Result) Format(language string) string {
+	if r == nil {
+		return ""
+	}
+	pathWidth := len(r.Path)
+	if pathWidth < 10 {
+		pathWidth = 10
+	}
+	jobWidth := 7
+	for _, job := range r.Jobs {
+		if len(job.Name) > jobWidth {
+			jobWidth = len(job.Name)
+		}
+	}
+	var builder strings.Builder
+	if language == "zh" {
+		builder.WriteString(fmt.Sprintf("fio作业配置: %s\n", r.Profile))
+		builder.WriteString(fmt.Sprintf("%-*s   %-*s   %-20s %-20s %-20s\n", pathWidth, "测试路径", jobWidth, "作业", "读测试(IOPS)", "写测试(IOPS)", "总和(IOPS)"))
+	} else {
+		builder.WriteString(fmt.Sprintf("fio Profile: %s\n", r.Profile))
+		builder.WriteString(fmt.Sprintf("%-*s   %-*s   %-20s %-20s %-20s\n", pathWidth, "Test Path", jobWidth, "Job", "Read(IOPS)", "Write(IOPS)", "Total(IOPS)"))
+	}
+	for _, job := range r.Jobs {
+		builder.WriteString(fmt.Sprintf("%-*s   %-*s   %-23s %-23s %-23s\n", pathWidth, r.Path, jobWidth, job.Name,
+			formatSpeed(job.Read.BandwidthMBps)+"("+formatIOPS(job.Read.IOPS)+")",
+			formatSpeed(job.Write.BandwidthMBps)+"("+formatIOPS(job.Write.IOPS)+")",
+			formatSpeed(job.Read.BandwidthMBps+job.Write.BandwidthMBps)+"("+formatIOPS(job.Read.IOPS+job.Write.IOPS)+")"))
+	}
+	return builder.String()
+}
+
+// Test runs profile on path and formats the result or the error for the disk section
+func Test(language, profile, path string) (*Result, string) {
+	result, err := Run(profile, path)
+	if err != nil {
+		if language == "zh" {
+			return nil, fmt.Sprintf("fio作业配置 %s 运行失败: %v\n", profile, err)
+		}
+		return nil, fmt.Sprintf("fio profile %s failed: %v\n", profile, err)
+	}
+	return result, result.Format(language)
+}
@@ -0,0 +1,62 @@
+package fioprofile
+
+import (
+	"strings"
+	"testing"
+)
+
+const sampleOutput = `fio: this platform does not support process shared mutexes, forcing use of threads
+{
+  "fio version" : "fio-3.35",
+  "jobs" : [
+    {
+      "jobname" : "oltp-8k",
+      "error" : 0,
+      "read" : {"bw_bytes" : 104857600, "iops" : 12800.5, "lat_ns" : {"mean" : 2500000.0}},
+      "write" : {"bw_bytes" : 44040192, "iops" : 5376.2, "lat_ns" : {"mean" : 3100000.0}}
+    },
+    {
+      "jobname" : "wal-4k",
+      "error" : 0,
+      "read" : {"bw_bytes" : 0, "iops" : 0, "lat_ns" : {"mean" : 0}},
+      "write" : {"bw_bytes" : 2097152, "iops" : 512, "lat_ns" : {"mean" : 1950000.0}}
+    }
+  ]
+}`
+
+func TestParse(t *testing.T) {
+	jobs, err := Parse([]byte(sampleOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 || jobs[0].Name != "oltp-8k" || jobs[0].Read.BandwidthMBps != 100 || jobs[0].Write.LatencyMeanUs != 3100 {
+		t.Fatalf("Parse = %+v", jobs)
+	}
+	result := &Result{Profile: "database", Path: "/root", Jobs: jobs}
+	text := result.Format("en")
+	if !strings.Contains(text, "/root        oltp-8k   100.00 MB/s(12.80k)") || !strings.Contains(text, "Read(IOPS)") {
+		t.Fatalf("Format = %q", text)
+	}
+	if _, err := Parse([]byte(`{"jobs":[{"jobname":"x","error":5}]}`)); err == nil {
+		t.Fatal("Parse accepted a failed job")
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	for _, name := range Names() {
+		if !Exists(name) {
+			t.Errorf("built-in profile %s is missing", name)
+		}
+	}
+	if Exists("no-such-profile") {
+		t.Fatal("Exists accepted an unknown profile")
+	}
+}
+
+func TestExpand(t *testing.T) {
+	content, _ := jobFile("database")
+	job := string(expand(content))
+	if strings.Contains(job, "${") || !strings.Contains(job, "ioengine="+ioEngine()+"\n") {
+		t.Fatalf("expand left variables in the job file:\n%s", job)
+	}
+}
@@ -0,0 +1,84 @@
+package fioprofile
+
+// 内置作业文件中的 ${FIO_IOENGINE} 和 ${FIO_SIZE} 在写入作业文件前由 expand 替换
+
+// profiles are the built-in job files selectable by name with -fio-profile
+var profiles = map[string]string{
+	// 70/30 随机读写的 OLTP 负载加同步提交的日志写入
+	"database": `[global]
+ioengine=${FIO_IOENGINE}
+direct=1
+size=${FIO_SIZE}
+filename=goecs-fio.dat
+runtime=20
+time_based=1
+ramp_time=2
+group_reporting=1
+
+[oltp-8k]
+rw=randrw
+rwmixread=70
+bs=8k
+iodepth=32
+numjobs=4
+
+[wal-4k]
+stonewall
+rw=write
+bs=4k
+iodepth=1
+numjobs=1
+fdatasync=1
+`,
+	"sequential": `[global]
+ioengine=${FIO_IOENGINE}
+direct=1
+size=${FIO_SIZE}
+filename=goecs-fio.dat
+runtime=20
+time_based=1
+ramp_time=2
+group_reporting=1
+
+[seq-read-1m]
+rw=read
+bs=1m
+iodepth=8
+numjobs=1
+
+[seq-write-1m]
+stonewall
+rw=write
+bs=1m
+iodepth=8
+numjobs=1
+`,
+	"mixed-70-30": `[global]
+ioengine=${FIO_IOENGINE}
+direct=1
+size=${FIO_SIZE}
+filename=goecs-fio.dat
+runtime=20
+time_based=1
+ramp_time=2
+group_reporting=1
+rw=randrw
+rwmixread=70
+
+[randrw-4k]
+bs=4k
+iodepth=64
+numjobs=2
+
+[randrw-64k]
+stonewall
+bs=64k
+iodepth=64
+numjobs=2
+`,
+}
+
+// Names returns the built-in profile names
+func Names() []string {
+	return []string{"database", "sequential", "mixed-70-30"}
+}
@@ -0,0 +1,41 @@
+// Package flaglist parses the comma separated list values of the command line flags
+package flaglist
+
+import (
+	"net"
+	"strings"
+)
+
+// Item is one entry of a list, Raw is the entry as given for error messages
+type Item struct {
+	Raw   string
+	Value string
+	Label string
+}
+
+// Split splits value at commas into "value[=label]" items, trimming spaces and dropping empty items.
+// The label follows the last "=", which is not a separator when a "?" precedes it, so the query of a URL stays intact
+func Split(value string) []Item {
+	var items []Item
+	for _, raw := range strings.Split(value, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		item := Item{Raw: raw, Value: raw}
+		if index := strings.LastIndex(raw, "="); index > 0 && !strings.Contains(raw[:index], "?") {
+			item.Value, item.Label = strings.TrimSpace(raw[:index]), strings.TrimSpace(raw[index+1:])
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// HostPort splits "host[:port]", an IPv6 literal without a port may be bracketed or not,
+// port is defaultPort when it is absent
+func HostPort(value, defaultPort string) (host, port string) {
+	if host, port, err := net.SplitHostPort(value); err == nil {
+		return host, port
+	}
+	return strings.Trim(value, "[]"), defaultPort
+}
@@ -0,0 +1,30 @@
+package flaglist
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	items := Split(" 1.1.1.1 , [2001:db8::1]:53=v6 office ,, http://host/up.php?x=1, https://host/file=edge,=x")
+	want := []Item{
+		{Raw: "1.1.1.1", Value: "1.1.1.1"},
+		{Raw: "[2001:db8::1]:53=v6 office", Value: "[2001:db8::1]:53", Label: "v6 office"},
+		{Raw: "http://host/up.php?x=1", Value: "http://host/up.php?x=1"},
+		{Raw: "https://host/file=edge", Value: "https://host/file", Label: "edge"},
+		{Raw: "=x", Value: "=x"},
+	}
+	if fmt.Sprint(items) != fmt.Sprint(want) {
+		t.Fatalf("Split = %+v, want %+v", items, want)
+	}
+	for _, test := range []struct{ value, host, port string }{
+		{"10.0.0.2:5353", "10.0.0.2", "5353"},
+		{"2001:db8::1", "2001:db8::1", "53"},
+		{"[2001:db8::1]", "2001:db8::1", "53"},
+		{"example.com", "example.com", "53"},
+	} {
+		if host, port := HostPort(test.value, "53"); host != test.host || port != test.port {
+			t.Errorf("HostPort(%q) = %q, %q", test.value, host, port)
+		}
+	}
+}
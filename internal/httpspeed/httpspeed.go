@@ -0,0 +1,300 @@
+// Package httpspeed measures download and upload throughput against user supplied HTTP(S) endpoints
+package httpspeed
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/oneclickvirt/ecs/internal/flaglist"
+	"github.com/oneclickvirt/ecs/internal/speedtable"
+)
+
+// uploadChunk is the body size of one upload request, sent with a Content-Length so servers that refuse chunked bodies accept it
+const uploadChunk = 8 << 20
+
+// Options controls one throughput measurement
+type Options struct {
+	Streams  int           // 并行连接数
+	Warmup   time.Duration // 预热时间，期间的流量不计入结果
+	Duration time.Duration // 预热后的计量时间
+	Interval time.Duration // 采样间隔
+	Client   *http.Client
+}
+
+// DefaultOptions returns the options used by the speed test section
+func DefaultOptions(streams int) Options {
+	if streams < 1 {
+		streams = 4
+	}
+	return Options{
+		Streams:  streams,
+		Warmup:   2 * time.Second,
+		Duration: 10 * time.Second,
+		Interval: time.Second,
+		Client:   &http.Client{Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, MaxIdleConnsPerHost: 64, DisableCompression: true}},
+	}
+}
+
+// Endpoint is one URL of the -speed-url list
+type Endpoint struct {
+	URL   string
+	Label string
+}
+
+// ParseList parses a comma separated list of http(s) URLs, each optionally followed by "=label",
+// invalid URLs are returned separately
+func ParseList(value string) (endpoints []Endpoint, invalid []string) {
+	for _, item := range flaglist.Split(value) {
+		parsed, err := url.Parse(item.Value)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			invalid = append(invalid, item.Raw)
+			continue
+		}
+		label := item.Label
+		if label == "" {
+			label = parsed.Host
+		}
+		endpoints = append(endpoints, Endpoint{URL: item.Value, Label: label})
+	}
+	return endpoints, invalid
+}
+
+// Transfer is the throughput of one direction
+type Transfer struct {
+	Mbps    float64   `json:"mbps"`
+	Bytes   int64     `json:"bytes"`
+	Samples []float64 `json:"samples_mbps"` // 预热后每个采样间隔的吞吐量
+}
+
+// Result is the measurement of one endpoint, a nil direction failed
+type Result struct {
+	URL      string        `json:"url"`
+	Label    string        `json:"label"`
+	Latency  time.Duration `json:"latency"`
+	Download *Transfer     `json:"download,omitempty"`
+	Upload   *Transfer     `json:"upload,omitempty"`
+}
+
+// countingReader counts the bytes the transport pulls from an upload body
+type countingReader struct {
+	data    []byte
+	offset  int
+	counter *atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	if r.offset >= len(r.data) {
+		return 0, io.EOF
+	}
+	n := copy(p, r.data[r.offset:])
+	r.offset += n
+	r.counter.Add(int64(n))
+	return n, nil
+}
+
+// countingWriter counts the bytes of a download body
+type countingWriter struct {
+	counter *atomic.Int64
+}
+
+func (w countingWriter) Write(p []byte) (int, error) {
+	w.counter.Add(int64(len(p)))
+	return len(p), nil
+}
+
+// errStatus marks a response the server refused, the stream stops instead of retrying
+var errStatus = errors.New("unexpected HTTP status")
+
+func download(ctx context.Context, client *http.Client, target string, counter *atomic.Int64) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 400 {
+		return fmt.Errorf("%w %s", errStatus, response.Status)
+	}
+	_, err = io.Copy(countingWriter{counter}, response.Body)
+	return err
+}
+
+func upload(ctx context.Context, client *http.Client, target string, payload []byte, counter *atomic.Int64) error {
+	body := &countingReader{data: payload, counter: counter}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, target, body)
+	if err != nil {
+		return err
+	}
+	request.ContentLength = int64(len(payload))
+	request.Header.Set("Content-Type", "application/octet-stream")
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+	if response.StatusCode >= 400 {
+		return fmt.Errorf("%w %s", errStatus, response.Status)
+	}
+	return nil
+}
+
+// measure runs transfer on opts.Streams parallel streams, each repeating it until the time is up,
+// and samples the shared byte counter once per interval after the warm-up
+func measure(opts Options, transfer func(ctx context.Context, counter *atomic.Int64) error) (*Transfer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Warmup+opts.Duration)
+	defer cancel()
+	var counter atomic.Int64
+	var failure atomic.Value
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Streams; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				if err := transfer(ctx, &counter); err != nil && ctx.Err() == nil {
+					failure.CompareAndSwap(nil, err)
+					if errors.Is(err, errStatus) {
+						return
+					}
+					// 连接错误稍后重试，避免空转
+					time.Sleep(100 * time.Millisecond)
+				}
+			}
+		}()
+	}
+	time.Sleep(opts.Warmup)
+	result := &Transfer{}
+	start := time.Now()
+	base := counter.Load()
+	last := base
+	ticker := time.NewTicker(opts.Interval)
+	for sampling := true; sampling; {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			sampling = false
+		}
+		current := counter.Load()
+		if sampling {
+			result.Samples = append(result.Samples, float64(current-last)*8/opts.Interval.Seconds()/1e6)
+		}
+		last = current
+	}
+	ticker.Stop()
+	elapsed := time.Since(start)
+	wg.Wait()
+	result.Bytes = last - base
+	// 服务器拒绝请求时已发出的数据不算作吞吐量
+	if err, ok := failure.Load().(error); ok && (result.Bytes == 0 || errors.Is(err, errStatus)) {
+		return nil, err
+	}
+	if result.Bytes == 0 {
+		return nil, errors.New("no data transferred")
+	}
+	result.Mbps = float64(result.Bytes) * 8 / elapsed.Seconds() / 1e6
+	return result, nil
+}
+
+// latency is the shortest time to the response headers of three HEAD requests
+func latency(client *http.Client, target string) time.Duration {
+	var best time.Duration
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		request, err := http.NewRequestWithContext(ctx, http.MethodHead, target, nil)
+		if err != nil {
+			cancel()
+			return 0
+		}
+		start := time.Now()
+		response, err := client.Do(request)
+		elapsed := time.Since(start)
+		cancel()
+		if err != nil {
+			continue
+		}
+		response.Body.Close()
+		if best == 0 || elapsed < best {
+			best = elapsed
+		}
+	}
+	return best
+}
+
+// Test measures latency, download and upload throughput of one endpoint
+func Test(endpoint Endpoint, opts Options) Result {
+	result := Result{URL: endpoint.URL, Label: endpoint.Label}
+	result.Latency = latency(opts.Client, endpoint.URL)
+	if transfer, err := measure(opts, func(ctx context.Context, counter *atomic.Int64) error {
+		return download(ctx, opts.Client, endpoint.URL, counter)
+	}); err == nil {
+		result.Download = transfer
+	}
+	payload := make([]byte, uploadChunk)
+	rand.Read(payload)
+	if transfer, err := measure(opts, func(ctx context.Context, counter *atomic.Int64) error {
+		return upload(ctx, opts.Client, endpoint.URL, payload, counter)
+	}); err == nil {
+		result.Upload = transfer
+	}
+	return result
+}
+
+func (t *Transfer) speed() string {
+	if t == nil {
+		return "N/A"
+	}
+	return fmt.Sprintf("%.2f Mbps", t.Mbps)
+}
+
+// Format renders the result as a row of the speed test table followed by the per-interval samples
+func (r Result) Format(language string) string {
+	var builder strings.Builder
+	latencyText := "N/A"
+	if r.Latency > 0 {
+		latencyText = r.Latency.Round(10 * time.Microsecond).String()
+	}
+	builder.WriteString(speedtable.Row(r.Label, r.Upload.speed(), r.Download.speed(), latencyText, "-"))
+	for _, direction := range []struct {
+		zh, en   string
+		transfer *Transfer
+	}{{"下载", "download", r.Download}, {"上传", "upload", r.Upload}} {
+		if direction.transfer == nil || len(direction.transfer.Samples) == 0 {
+			continue
+		}
+		samples := make([]string, len(direction.transfer.Samples))
+		for i, sample := range direction.transfer.Samples {
+			samples[i] = fmt.Sprintf("%.1f", sample)
+		}
+		if language == "zh" {
+			builder.WriteString(fmt.Sprintf("  %s 每秒%s (Mbps) %s\n", r.Label, direction.zh, strings.Join(samples, " ")))
+		} else {
+			builder.WriteString(fmt.Sprintf("  %s per-second %s (Mbps) %s\n", r.Label, direction.en, strings.Join(samples, " ")))
+		}
+	}
+	return builder.String()
+}
+
+// Run tests every endpoint in order and prints the table rows
+func Run(endpoints []Endpoint, streams int, language string) []Result {
+	opts := DefaultOptions(streams)
+	var results []Result
+	for _, endpoint := range endpoints {
+		result := Test(endpoint, opts)
+		fmt.Print(result.Format(language))
+		results = append(results, result)
+	}
+	return results
+}
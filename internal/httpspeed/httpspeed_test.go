@@ -0,0 +1,70 @@
+package httpspeed
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseList(t *testing.T) {
+	endpoints, invalid := ParseList("http://127.0.0.1:8080/1G.bin=local nginx, https://s3.example.com/b/o?X-Amz-Signature=abc ,ftp://x/y,,")
+	if fmt.Sprint(endpoints) != "[{http://127.0.0.1:8080/1G.bin local nginx} {https://s3.example.com/b/o?X-Amz-Signature=abc s3.example.com}]" {
+		t.Fatalf("ParseList = %v", endpoints)
+	}
+	if fmt.Sprint(invalid) != "[ftp://x/y]" {
+		t.Fatalf("invalid = %v", invalid)
+	}
+}
+
+func testOptions(server *httptest.Server) Options {
+	return Options{Streams: 2, Warmup: 100 * time.Millisecond, Duration: 400 * time.Millisecond, Interval: 100 * time.Millisecond, Client: server.Client()}
+}
+
+func TestLocalServer(t *testing.T) {
+	block := make([]byte, 1<<20)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			io.Copy(io.Discard, r.Body)
+		default:
+			w.Write(block)
+		}
+	}))
+	defer server.Close()
+	result := Test(Endpoint{URL: server.URL, Label: "local"}, testOptions(server))
+	if result.Download == nil || result.Upload == nil || result.Latency <= 0 {
+		t.Fatalf("Test = %+v", result)
+	}
+	for _, transfer := range []*Transfer{result.Download, result.Upload} {
+		if transfer.Mbps <= 0 || len(transfer.Samples) < 3 {
+			t.Fatalf("transfer = %+v", transfer)
+		}
+	}
+	// 表格行需与 speedtest 输出一致
+	text := result.Format("en")
+	if lines := strings.Split(strings.TrimSpace(text), "\n"); len(lines) != 3 || !strings.HasPrefix(lines[1], "  local per-second download (Mbps) ") {
+		t.Fatalf("Format = %q", text)
+	}
+}
+
+func TestRejectedUpload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Write(make([]byte, 64<<10))
+	}))
+	defer server.Close()
+	result := Test(Endpoint{URL: server.URL, Label: "static"}, testOptions(server))
+	if result.Download == nil || result.Upload != nil {
+		t.Fatalf("Test = %+v", result)
+	}
+	if row := strings.Fields(strings.SplitN(result.Format("en"), "\n", 2)[0]); row[1] != "N/A" {
+		t.Fatalf("row = %v", row)
+	}
+}
@@ -0,0 +1,386 @@
+package iperf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultTCPLength is the iperf3 default block size for TCP
+	defaultTCPLength = 128 << 10
+	// defaultUDPLength keeps datagrams below common tunnel MTUs to avoid fragmentation
+	defaultUDPLength = 1400
+	// handshakeTimeout bounds each step of the control protocol outside the test itself
+	handshakeTimeout = 30 * time.Second
+)
+
+// Options describes one client test
+type Options struct {
+	Address  string // host:port，省略端口时使用 5201
+	UDP      bool
+	Reverse  bool // 由服务端发送，即测试下载方向
+	Parallel int
+	Duration time.Duration
+	Bitrate  uint64 // UDP 的目标总速率，单位 bit/s，0 为不限速
+	Length   int    // 每次写入的字节数，0 使用默认值
+}
+
+// Result is the outcome of one test as seen by the client
+type Result struct {
+	Protocol      string  `json:"protocol"`
+	Reverse       bool    `json:"reverse"`
+	Streams       int     `json:"streams"`
+	Seconds       float64 `json:"seconds"`
+	SentBytes     uint64  `json:"sent_bytes"`
+	ReceivedBytes uint64  `json:"received_bytes"`
+	Retransmits   int64   `json:"retransmits"` // -1 表示未知
+	JitterMs      float64 `json:"jitter_ms,omitempty"`
+	LostPackets   int64   `json:"lost_packets,omitempty"`
+	Packets       int64   `json:"packets,omitempty"`
+	TargetBitrate uint64  `json:"target_bitrate,omitempty"`
+	Error         string  `json:"error,omitempty"`
+}
+
+// Mbps is the throughput measured by the receiving side
+func (r *Result) Mbps() float64 {
+	if r.Seconds <= 0 {
+		return 0
+	}
+	return float64(r.ReceivedBytes) * 8 / r.Seconds / 1e6
+}
+
+// LossPercent is the share of UDP datagrams the receiver did not get
+func (r *Result) LossPercent() float64 {
+	if r.Packets <= 0 {
+		return 0
+	}
+	return float64(r.LostPackets) * 100 / float64(r.Packets)
+}
+
+// ParseBitrate parses rates such as "100M", "1.5G" or "800000" in bits per second
+func ParseBitrate(text string) (uint64, error) {
+	text = strings.TrimSpace(text)
+	multiplier := 1.0
+	if text != "" {
+		switch text[len(text)-1] {
+		case 'k', 'K':
+			multiplier = 1e3
+		case 'm', 'M':
+			multiplier = 1e6
+		case 'g', 'G':
+			multiplier = 1e9
+		}
+		if multiplier > 1 {
+			text = text[:len(text)-1]
+		}
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid bitrate %q", text)
+	}
+	return uint64(value * multiplier), nil
+}
+
+// withDefaultPort appends the iperf3 port when address has none
+func withDefaultPort(address string) string {
+	if _, _, err := net.SplitHostPort(address); err == nil {
+		return address
+	}
+	return net.JoinHostPort(strings.Trim(address, "[]"), strconv.Itoa(DefaultPort))
+}
+
+// connectUDP opens a UDP stream and waits for the server to acknowledge it
+func connectUDP(address string) (*net.UDPConn, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	udpConn := conn.(*net.UDPConn)
+	message := make([]byte, 4)
+	binary.LittleEndian.PutUint32(message, udpConnectMsg)
+	reply := make([]byte, 64<<10)
+	// UDP 握手包可能丢失，重试几次
+	for attempt := 0; attempt < 3; attempt++ {
+		if _, err = udpConn.Write(message); err != nil {
+			break
+		}
+		udpConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		var n int
+		if n, err = udpConn.Read(reply); err == nil && n == 4 {
+			udpConn.SetReadDeadline(time.Time{})
+			return udpConn, nil
+		}
+	}
+	udpConn.Close()
+	if err == nil {
+		err = errors.New("no reply to the UDP connect message")
+	}
+	return nil, err
+}
+
+// Run performs one test against an iperf3 compatible server
+func Run(opts Options) (*Result, error) {
+	if opts.Parallel < 1 {
+		opts.Parallel = 1
+	}
+	seconds := int(opts.Duration.Round(time.Second) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	if opts.Length <= 0 {
+		opts.Length = defaultTCPLength
+		if opts.UDP {
+			opts.Length = defaultUDPLength
+		}
+	}
+	control, err := net.DialTimeout("tcp", withDefaultPort(opts.Address), 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer control.Close()
+	// 数据连接使用控制连接解析出的地址，避免多地址域名连到不同主机
+	address := control.RemoteAddr().String()
+	control.SetDeadline(time.Now().Add(handshakeTimeout))
+	cookie := newCookie()
+	if _, err := control.Write([]byte(cookie)); err != nil {
+		return nil, err
+	}
+	if err := expectState(control, paramExchange); err != nil {
+		return nil, err
+	}
+	params := testParams{
+		TCP:           !opts.UDP,
+		UDP:           opts.UDP,
+		Time:          seconds,
+		Parallel:      opts.Parallel,
+		Len:           opts.Length,
+		Reverse:       opts.Reverse,
+		PacingTimer:   1000,
+		ClientVersion: "3.9",
+	}
+	if opts.UDP && opts.Bitrate > 0 {
+		// iperf3 的 bandwidth 是每个流的速率
+		params.Bandwidth = max(opts.Bitrate/uint64(opts.Parallel), 1)
+	}
+	if err := writeJSON(control, params); err != nil {
+		return nil, err
+	}
+	if err := expectState(control, createStreams); err != nil {
+		return nil, err
+	}
+	streams := make([]*stream, 0, opts.Parallel)
+	defer func() {
+		for _, s := range streams {
+			s.close()
+		}
+	}()
+	for i := 0; i < opts.Parallel; i++ {
+		s := &stream{id: streamID(i)}
+		if opts.UDP {
+			if s.udp, err = connectUDP(address); err != nil {
+				return nil, err
+			}
+		} else {
+			if s.tcp, err = net.DialTimeout("tcp", address, 10*time.Second); err != nil {
+				return nil, err
+			}
+			if _, err = s.tcp.Write([]byte(cookie)); err != nil {
+				s.tcp.Close()
+				return nil, err
+			}
+		}
+		streams = append(streams, s)
+	}
+	if err := expectState(control, testStart); err != nil {
+		return nil, err
+	}
+	if err := expectState(control, testRunning); err != nil {
+		return nil, err
+	}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	start := time.Now()
+	for _, s := range streams {
+		wg.Add(1)
+		go func(s *stream) {
+			defer wg.Done()
+			switch {
+			case opts.Reverse:
+				receive(s, opts.Length, false)
+			case opts.UDP:
+				sendUDP(s, opts.Length, params.Bandwidth, false, stop)
+			default:
+				sendTCP(s, opts.Length, stop)
+			}
+		}(s)
+	}
+	time.Sleep(time.Duration(seconds) * time.Second)
+	close(stop)
+	if !opts.Reverse {
+		for _, s := range streams {
+			s.interrupt()
+		}
+		wg.Wait()
+	}
+	elapsed := time.Since(start)
+
+	control.SetDeadline(time.Now().Add(handshakeTimeout))
+	if err := writeState(control, testEnd); err != nil {
+		return nil, err
+	}
+	if err := expectState(control, exchangeResults); err != nil {
+		return nil, err
+	}
+	local := testResults{}
+	for _, s := range streams {
+		entry := s.result(!opts.Reverse, elapsed)
+		if !opts.Reverse && !opts.UDP && entry.Retransmits >= 0 {
+			local.SenderHasRetransmits = 1
+		}
+		local.Streams = append(local.Streams, entry)
+	}
+	if err := writeJSON(control, local); err != nil {
+		return nil, err
+	}
+	var remote testResults
+	if err := readJSON(control, &remote); err != nil {
+		return nil, err
+	}
+	if err := expectState(control, displayResults); err != nil {
+		return nil, err
+	}
+	writeState(control, iperfDone)
+	for _, s := range streams {
+		s.interrupt()
+	}
+	wg.Wait()
+
+	result := &Result{Protocol: "TCP", Reverse: opts.Reverse, Streams: opts.Parallel, Seconds: elapsed.Seconds(), Retransmits: -1}
+	if opts.UDP {
+		result.Protocol = "UDP"
+		result.TargetBitrate = opts.Bitrate
+	}
+	sender, receiver := local, remote
+	if opts.Reverse {
+		sender, receiver = remote, local
+	}
+	for _, entry := range sender.Streams {
+		result.SentBytes += entry.Bytes
+		if sender.SenderHasRetransmits == 1 && entry.Retransmits >= 0 {
+			result.Retransmits = max(result.Retransmits, 0) + entry.Retransmits
+		}
+	}
+	var jitter float64
+	for _, entry := range receiver.Streams {
+		result.ReceivedBytes += entry.Bytes
+		result.LostPackets += entry.Errors
+		result.Packets += entry.Packets
+		jitter += entry.Jitter
+	}
+	if opts.UDP && len(receiver.Streams) > 0 {
+		result.JitterMs = jitter / float64(len(receiver.Streams)) * 1000
+	}
+	return result, nil
+}
+
+func formatBitrate(bits uint64) string {
+	switch {
+	case bits >= 1e9:
+		return fmt.Sprintf("%g Gbps", float64(bits)/1e9)
+	case bits >= 1e6:
+		return fmt.Sprintf("%g Mbps", float64(bits)/1e6)
+	case bits > 0:
+		return fmt.Sprintf("%g Kbps", float64(bits)/1e3)
+	}
+	return "unlimited"
+}
+
+// Format renders the throughput of the result and the jitter of UDP tests
+func (r *Result) Format(language string) string {
+	zh := language == "zh"
+	label := r.Protocol + " Upload"
+	if r.Reverse {
+		label = r.Protocol + " Download"
+	}
+	if zh {
+		label = r.Protocol + "上传"
+		if r.Reverse {
+			label = r.Protocol + "下载"
+		}
+	}
+	if r.Error != "" {
+		if zh {
+			return fmt.Sprintf("%s: 失败, %s\n", label, r.Error)
+		}
+		return fmt.Sprintf("%s: failed, %s\n", label, r.Error)
+	}
+	var details []string
+	if zh {
+		details = append(details, fmt.Sprintf("%d 个连接", r.Streams))
+	} else {
+		details = append(details, fmt.Sprintf("%d streams", r.Streams))
+	}
+	if r.Retransmits >= 0 {
+		if zh {
+			details = append(details, fmt.Sprintf("重传 %d 次", r.Retransmits))
+		} else {
+			details = append(details, fmt.Sprintf("%d retransmits", r.Retransmits))
+		}
+	}
+	if r.Protocol == "UDP" {
+		if zh {
+			details = append(details, "目标 "+formatBitrate(r.TargetBitrate))
+			details = append(details, fmt.Sprintf("丢包 %.2f%% (%d/%d)", r.LossPercent(), r.LostPackets, r.Packets))
+		} else {
+			details = append(details, "target "+formatBitrate(r.TargetBitrate))
+			details = append(details, fmt.Sprintf("loss %.2f%% (%d/%d)", r.LossPercent(), r.LostPackets, r.Packets))
+		}
+	}
+	text := fmt.Sprintf("%s: %.2f Mbps (%s)\n", label, r.Mbps(), strings.Join(details, ", "))
+	if r.Protocol == "UDP" {
+		if zh {
+			text += fmt.Sprintf("%s抖动: %.3f ms\n", label, r.JitterMs)
+		} else {
+			text += fmt.Sprintf("%s Jitter: %.3f ms\n", label, r.JitterMs)
+		}
+	}
+	return text
+}
+
+// Test runs upload and download over TCP, and over UDP when withUDP is set, and formats every result
+func Test(language string, opts Options, withUDP bool) (string, []*Result) {
+	var builder strings.Builder
+	var results []*Result
+	protocols := []bool{false}
+	if withUDP {
+		protocols = append(protocols, true)
+	}
+	for _, udp := range protocols {
+		for _, reverse := range []bool{false, true} {
+			run := opts
+			run.UDP, run.Reverse = udp, reverse
+			result, err := Run(run)
+			// 服务端在上一轮结束后需要片刻才能接受新测试
+			for attempt := 0; errors.Is(err, ErrBusy) && attempt < 3; attempt++ {
+				time.Sleep(time.Second)
+				result, err = Run(run)
+			}
+			if err != nil {
+				result = &Result{Protocol: "TCP", Reverse: reverse, Streams: run.Parallel, Error: err.Error()}
+				if udp {
+					result.Protocol = "UDP"
+				}
+			}
+			builder.WriteString(result.Format(language))
+			results = append(results, result)
+		}
+	}
+	return builder.String(), results
+}
@@ -0,0 +1,71 @@
+package iperf
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func startServer(t *testing.T) *Server {
+	t.Helper()
+	server, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(false)
+	t.Cleanup(func() { server.Close() })
+	return server
+}
+
+func TestLoopback(t *testing.T) {
+	server := startServer(t)
+	opts := Options{Address: server.Addr().String(), Parallel: 2, Duration: time.Second, Bitrate: 20e6}
+	text, results := Test("en", opts, true)
+	if len(results) != 4 {
+		t.Fatalf("Test returned %d results", len(results))
+	}
+	for _, result := range results {
+		if result.Error != "" || result.ReceivedBytes == 0 || result.SentBytes < result.ReceivedBytes/2 {
+			t.Fatalf("%+v", result)
+		}
+	}
+	// UDP 按目标速率发送，回环上应接近 20 Mbps 且几乎不丢包
+	for _, udp := range results[2:] {
+		if udp.Mbps() < 10 || udp.Mbps() > 30 || udp.Packets == 0 || udp.LossPercent() > 5 {
+			t.Fatalf("UDP %+v, %.2f Mbps", udp, udp.Mbps())
+		}
+	}
+	if !strings.Contains(text, "UDP Download Jitter: ") {
+		t.Fatalf("Test = %q", text)
+	}
+}
+
+func TestBusyServer(t *testing.T) {
+	server := startServer(t)
+	done := make(chan error, 1)
+	go func() {
+		_, err := Run(Options{Address: server.Addr().String(), Duration: 2 * time.Second})
+		done <- err
+	}()
+	time.Sleep(300 * time.Millisecond)
+	if _, err := Run(Options{Address: server.Addr().String(), Duration: time.Second}); err == nil || !strings.Contains(err.Error(), "busy") {
+		t.Fatalf("second client err = %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUDPStats(t *testing.T) {
+	var stats udpStats
+	packet := make([]byte, 12)
+	now := time.Now()
+	for _, count := range []uint32{1, 2, 4, 5, 3, 7} {
+		packet[11] = byte(count)
+		stats.record(packet, now, false)
+	}
+	// 3 乱序到达后不再计为丢失，6 仍然丢失
+	if packets, errors, _ := stats.snapshot(); packets != 7 || errors != 1 {
+		t.Fatalf("snapshot = %d packets, %d errors", packets, errors)
+	}
+}
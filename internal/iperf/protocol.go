@@ -0,0 +1,177 @@
+// Package iperf implements the iperf3 control and data protocol, so goecs can test against iperf3 servers
+// and iperf3 clients can test against goecs
+package iperf
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// DefaultPort is the iperf3 default port
+const DefaultPort = 5201
+
+// ErrBusy is returned when the server is running another test
+var ErrBusy = errors.New("the server is busy running a test")
+
+// iperf3 控制连接上的状态字节，取值与 iperf3 的 iperf.h 一致
+const (
+	testStart       int8 = 1
+	testRunning     int8 = 2
+	testEnd         int8 = 4
+	paramExchange   int8 = 9
+	createStreams   int8 = 10
+	serverTerminate int8 = 11
+	clientTerminate int8 = 12
+	exchangeResults int8 = 13
+	displayResults  int8 = 14
+	iperfDone       int8 = 16
+	accessDenied    int8 = -1
+	serverError     int8 = -2
+)
+
+const (
+	// cookieSize is the length of the test cookie, 36 characters and a terminating NUL
+	cookieSize = 37
+	// udpConnectMsg is sent by the client on a new UDP stream, udpConnectReply is the answer of the server,
+	// both are the legacy values every iperf3 version accepts
+	udpConnectMsg   = 123456789
+	udpConnectReply = 987654321
+)
+
+// testParams is the JSON the client sends in the parameter exchange, iperf3 treats the
+// presence of the boolean keys as true so they must be omitted when false
+type testParams struct {
+	TCP           bool   `json:"tcp,omitempty"`
+	UDP           bool   `json:"udp,omitempty"`
+	Omit          int    `json:"omit"`
+	Time          int    `json:"time"`
+	Num           int64  `json:"num"`
+	BlockCount    int64  `json:"blockcount"`
+	Parallel      int    `json:"parallel"`
+	Len           int    `json:"len"`
+	Bandwidth     uint64 `json:"bandwidth,omitempty"`
+	PacingTimer   int    `json:"pacing_timer,omitempty"`
+	Reverse       bool   `json:"reverse,omitempty"`
+	Bidirectional bool   `json:"bidirectional,omitempty"`
+	UDPCounters64 bool   `json:"udp_counters_64bit,omitempty"`
+	ClientVersion string `json:"client_version,omitempty"`
+}
+
+// streamResult is the per stream entry of the results exchange
+type streamResult struct {
+	ID            int     `json:"id"`
+	Bytes         uint64  `json:"bytes"`
+	Retransmits   int64   `json:"retransmits"`
+	Jitter        float64 `json:"jitter"` // 秒
+	Errors        int64   `json:"errors"`
+	OmittedErrors int64   `json:"omitted_errors"`
+	Packets       int64   `json:"packets"`
+	StartTime     float64 `json:"start_time"`
+	EndTime       float64 `json:"end_time"`
+}
+
+// testResults is the JSON both sides send in the results exchange
+type testResults struct {
+	CPUUtilTotal         float64        `json:"cpu_util_total"`
+	CPUUtilUser          float64        `json:"cpu_util_user"`
+	CPUUtilSystem        float64        `json:"cpu_util_system"`
+	SenderHasRetransmits int            `json:"sender_has_retransmits"`
+	Streams              []streamResult `json:"streams"`
+}
+
+// streamID returns the id iperf3 gives the n-th stream of a test: 1, 3, 4, 5...
+func streamID(n int) int {
+	if n == 0 {
+		return 1
+	}
+	return n + 2
+}
+
+// newCookie returns a random cookie from the same alphabet iperf3 uses
+func newCookie() string {
+	const alphabet = "abcdefghijklmnopqrstuvwxyz234567"
+	random := make([]byte, cookieSize-1)
+	rand.Read(random)
+	cookie := make([]byte, cookieSize)
+	for i, b := range random {
+		cookie[i] = alphabet[int(b)%len(alphabet)]
+	}
+	return string(cookie)
+}
+
+func readCookie(conn net.Conn) (string, error) {
+	cookie := make([]byte, cookieSize)
+	if _, err := io.ReadFull(conn, cookie); err != nil {
+		return "", err
+	}
+	return string(cookie), nil
+}
+
+func writeState(conn net.Conn, state int8) error {
+	_, err := conn.Write([]byte{byte(state)})
+	return err
+}
+
+func readState(conn net.Conn) (int8, error) {
+	var state [1]byte
+	if _, err := io.ReadFull(conn, state[:]); err != nil {
+		return 0, err
+	}
+	return int8(state[0]), nil
+}
+
+// expectState reads the next state and turns the error states of the peer into errors
+func expectState(conn net.Conn, want int8) error {
+	state, err := readState(conn)
+	if err != nil {
+		return err
+	}
+	switch {
+	case state == want:
+		return nil
+	case state == accessDenied:
+		return ErrBusy
+	case state == serverError:
+		// 服务端随后发送 i_errno 与 errno 两个 32 位整数
+		var codes [8]byte
+		io.ReadFull(conn, codes[:])
+		return fmt.Errorf("server error %d", int32(binary.BigEndian.Uint32(codes[:4])))
+	case state == serverTerminate || state == clientTerminate:
+		return errors.New("the test was terminated by the peer")
+	}
+	return fmt.Errorf("unexpected state %d, want %d", state, want)
+}
+
+// writeJSON sends a JSON document prefixed with its length as a 32-bit big endian integer
+func writeJSON(conn net.Conn, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	message := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(message, uint32(len(data)))
+	copy(message[4:], data)
+	_, err = conn.Write(message)
+	return err
+}
+
+func readJSON(conn net.Conn, value any) error {
+	var size [4]byte
+	if _, err := io.ReadFull(conn, size[:]); err != nil {
+		return err
+	}
+	length := binary.BigEndian.Uint32(size[:])
+	if length > 1<<20 {
+		return fmt.Errorf("JSON message of %d bytes is too large", length)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(conn, data); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
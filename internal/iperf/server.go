@@ -0,0 +1,356 @@
+package iperf
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// maxParallel bounds the streams one client may open
+const maxParallel = 128
+
+// Server accepts iperf3 clients on one TCP and UDP port, running one test at a time like iperf3 itself
+type Server struct {
+	tcp *net.TCPListener
+	udp *net.UDPConn
+	// Logf receives one line per finished test, nil discards them
+	Logf func(format string, args ...any)
+
+	mu     sync.Mutex
+	active *serverTest
+	closed bool
+}
+
+// serverTest is the state of the running test, the UDP fields are guarded by Server.mu
+type serverTest struct {
+	cookie    string
+	params    testParams
+	accepting bool
+	tcpConns  chan net.Conn
+	udpNew    chan *stream
+	udpPeers  map[string]*stream
+}
+
+// Listen opens the TCP control/data listener and the UDP data socket on the same address
+func Listen(address string) (*Server, error) {
+	tcpListener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	tcpAddr := tcpListener.Addr().(*net.TCPAddr)
+	udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: tcpAddr.IP, Port: tcpAddr.Port, Zone: tcpAddr.Zone})
+	if err != nil {
+		tcpListener.Close()
+		return nil, err
+	}
+	return &Server{tcp: tcpListener.(*net.TCPListener), udp: udpConn}, nil
+}
+
+// Addr returns the listening address
+func (s *Server) Addr() net.Addr {
+	return s.tcp.Addr()
+}
+
+// Close stops accepting clients
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.udp.Close()
+	return s.tcp.Close()
+}
+
+func (s *Server) logf(format string, args ...any) {
+	if s.Logf != nil {
+		s.Logf(format, args...)
+	}
+}
+
+// Serve accepts clients until the server is closed, with oneOff it returns after the first test
+func (s *Server) Serve(oneOff bool) error {
+	go s.readUDP()
+	for {
+		conn, err := s.tcp.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		go s.dispatch(conn, oneOff)
+	}
+}
+
+// dispatch reads the cookie of a new connection and starts a test, attaches a data stream or turns the client away
+func (s *Server) dispatch(conn net.Conn, oneOff bool) {
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	cookie, err := readCookie(conn)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+	s.mu.Lock()
+	test := s.active
+	switch {
+	case test == nil:
+		test = &serverTest{
+			cookie:   cookie,
+			tcpConns: make(chan net.Conn, maxParallel),
+			udpNew:   make(chan *stream, maxParallel),
+			udpPeers: make(map[string]*stream),
+		}
+		s.active = test
+		s.mu.Unlock()
+		if err := s.handle(conn, test); err != nil {
+			s.logf("test from %s failed, %v", conn.RemoteAddr(), err)
+		}
+		conn.Close()
+		s.mu.Lock()
+		s.active = nil
+		s.mu.Unlock()
+		if oneOff {
+			s.Close()
+		}
+	case test.cookie == cookie && test.accepting && !test.params.UDP:
+		s.mu.Unlock()
+		select {
+		case test.tcpConns <- conn:
+		default:
+			conn.Close()
+		}
+	default:
+		s.mu.Unlock()
+		writeState(conn, accessDenied)
+		conn.Close()
+	}
+}
+
+// readUDP receives every datagram of the shared UDP socket, registering new streams during stream creation
+// and feeding the loss and jitter statistics of the running test
+func (s *Server) readUDP() {
+	buffer := make([]byte, 64<<10)
+	reply := make([]byte, 4)
+	binary.LittleEndian.PutUint32(reply, udpConnectReply)
+	for {
+		n, addr, err := s.udp.ReadFromUDP(buffer)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		arrival := time.Now()
+		s.mu.Lock()
+		test := s.active
+		if test == nil || !test.params.UDP {
+			s.mu.Unlock()
+			continue
+		}
+		key := addr.String()
+		peer := test.udpPeers[key]
+		if test.accepting && n == 4 {
+			// 新流的握手包，重复的握手包说明应答丢失，再次应答即可
+			if peer == nil && len(test.udpPeers) < test.params.Parallel {
+				peer = &stream{id: streamID(len(test.udpPeers)), udp: s.udp, peer: addr}
+				test.udpPeers[key] = peer
+				test.udpNew <- peer
+			}
+			s.mu.Unlock()
+			if peer != nil {
+				s.udp.WriteToUDP(reply, addr)
+			}
+			continue
+		}
+		counters64 := test.params.UDPCounters64
+		receiving := !test.params.Reverse
+		s.mu.Unlock()
+		if peer != nil && receiving {
+			peer.bytes.Add(uint64(n))
+			peer.stats.record(buffer[:n], arrival, counters64)
+		}
+	}
+}
+
+// writeServerError reports an iperf3 error code, followed by a zero errno
+func writeServerError(conn net.Conn, code int32) {
+	if writeState(conn, serverError) != nil {
+		return
+	}
+	codes := make([]byte, 8)
+	binary.BigEndian.PutUint32(codes[:4], uint32(code))
+	conn.Write(codes)
+}
+
+// handle runs the control protocol of one test on the control connection
+func (s *Server) handle(control net.Conn, test *serverTest) error {
+	control.SetDeadline(time.Now().Add(handshakeTimeout))
+	if err := writeState(control, paramExchange); err != nil {
+		return err
+	}
+	var params testParams
+	if err := readJSON(control, &params); err != nil {
+		return err
+	}
+	if params.Bidirectional || params.Parallel > maxParallel {
+		// 不支持双向测试，返回 iperf3 的 IEUNIMP(未实现)错误码
+		writeServerError(control, 13)
+		return errors.New("unsupported test parameters")
+	}
+	params.Parallel = max(params.Parallel, 1)
+	if params.Len <= 0 {
+		params.Len = defaultTCPLength
+		if params.UDP {
+			params.Len = defaultUDPLength
+		}
+	}
+	s.mu.Lock()
+	test.params = params
+	test.accepting = true
+	s.mu.Unlock()
+	if err := writeState(control, createStreams); err != nil {
+		return err
+	}
+	var streams []*stream
+	defer func() {
+		for _, st := range streams {
+			st.close()
+		}
+	}()
+	timeout := time.After(10 * time.Second)
+	for len(streams) < params.Parallel {
+		select {
+		case conn := <-test.tcpConns:
+			streams = append(streams, &stream{id: streamID(len(streams)), tcp: conn})
+		case peer := <-test.udpNew:
+			streams = append(streams, peer)
+		case <-timeout:
+			return fmt.Errorf("only %d of %d streams connected", len(streams), params.Parallel)
+		}
+	}
+	s.mu.Lock()
+	test.accepting = false
+	s.mu.Unlock()
+	if err := writeState(control, testStart); err != nil {
+		return err
+	}
+	if err := writeState(control, testRunning); err != nil {
+		return err
+	}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	start := time.Now()
+	for _, st := range streams {
+		if !params.Reverse && params.UDP {
+			continue // UDP 接收由 readUDP 统计
+		}
+		wg.Add(1)
+		go func(st *stream) {
+			defer wg.Done()
+			switch {
+			case !params.Reverse:
+				receive(st, params.Len, false)
+			case params.UDP:
+				sendUDP(st, params.Len, params.Bandwidth, params.UDPCounters64, stop)
+			default:
+				sendTCP(st, params.Len, stop)
+			}
+		}(st)
+	}
+	// 测试时长由客户端控制，额外留出余量防止客户端失联时一直占用
+	control.SetDeadline(time.Now().Add(time.Duration(params.Omit+params.Time)*time.Second + handshakeTimeout))
+	state, err := readState(control)
+	close(stop)
+	for _, st := range streams {
+		st.interrupt()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	if err != nil {
+		return err
+	}
+	if state != testEnd {
+		return fmt.Errorf("the client ended the test with state %d", state)
+	}
+
+	control.SetDeadline(time.Now().Add(handshakeTimeout))
+	if err := writeState(control, exchangeResults); err != nil {
+		return err
+	}
+	var remote testResults
+	if err := readJSON(control, &remote); err != nil {
+		return err
+	}
+	local := testResults{}
+	for _, st := range streams {
+		entry := st.result(params.Reverse, elapsed)
+		if params.Reverse && !params.UDP && entry.Retransmits >= 0 {
+			local.SenderHasRetransmits = 1
+		}
+		local.Streams = append(local.Streams, entry)
+	}
+	if err := writeJSON(control, local); err != nil {
+		return err
+	}
+	if err := writeState(control, displayResults); err != nil {
+		return err
+	}
+	readState(control)
+
+	received := local
+	if params.Reverse {
+		received = remote
+	}
+	var bytes uint64
+	for _, entry := range received.Streams {
+		bytes += entry.Bytes
+	}
+	protocol, direction := "TCP", "upload"
+	if params.UDP {
+		protocol = "UDP"
+	}
+	if params.Reverse {
+		direction = "download"
+	}
+	s.logf("%s %s %s, %d streams, %.2f Mbps in %.1f s", control.RemoteAddr(), protocol, direction, params.Parallel, float64(bytes)*8/elapsed.Seconds()/1e6, elapsed.Seconds())
+	return nil
+}
+
+// Main runs the "goecs iperf-server" subcommand
+func Main(args []string, version string) error {
+	serverFlag := flag.NewFlagSet("iperf-server", flag.ContinueOnError)
+	port := serverFlag.Int("p", DefaultPort, "Listen port for TCP and UDP")
+	bind := serverFlag.String("B", "", "Bind to this local address, all addresses when empty")
+	oneOff := serverFlag.Bool("1", false, "Exit after one test")
+	if err := serverFlag.Parse(args); err != nil {
+		return err
+	}
+	server, err := Listen(net.JoinHostPort(*bind, strconv.Itoa(*port)))
+	if err != nil {
+		return err
+	}
+	server.Logf = func(format string, args ...any) {
+		fmt.Printf("%s "+format+"\n", append([]any{time.Now().Format("2006-01-02 15:04:05")}, args...)...)
+	}
+	fmt.Fprintf(os.Stderr, "goecs %s iperf3 compatible server listening on %s (TCP and UDP)\n", version, server.Addr())
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	return server.Serve(*oneOff)
+}
@@ -0,0 +1,209 @@
+package iperf
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// stream is one data connection of a test, TCP or UDP
+type stream struct {
+	id      int
+	tcp     net.Conn
+	udp     *net.UDPConn // 客户端为已连接的套接字，服务端为共用的监听套接字
+	peer    *net.UDPAddr // 服务端 UDP 流的对端地址
+	bytes   atomic.Uint64
+	packets atomic.Int64 // UDP 发送端已发送的包数
+	stats   udpStats     // UDP 接收端的统计
+}
+
+func (s *stream) write(buffer []byte) (int, error) {
+	switch {
+	case s.tcp != nil:
+		return s.tcp.Write(buffer)
+	case s.peer != nil:
+		return s.udp.WriteToUDP(buffer, s.peer)
+	}
+	return s.udp.Write(buffer)
+}
+
+func (s *stream) close() {
+	if s.tcp != nil {
+		s.tcp.Close()
+	} else if s.peer == nil && s.udp != nil {
+		s.udp.Close()
+	}
+}
+
+// interrupt unblocks a sender or receiver waiting on the connection
+func (s *stream) interrupt() {
+	if s.tcp != nil {
+		s.tcp.SetDeadline(time.Now())
+	} else if s.peer == nil && s.udp != nil {
+		s.udp.SetDeadline(time.Now())
+	}
+}
+
+// result builds the results exchange entry of the stream
+func (s *stream) result(sender bool, elapsed time.Duration) streamResult {
+	result := streamResult{ID: s.id, Bytes: s.bytes.Load(), Retransmits: -1, EndTime: elapsed.Seconds()}
+	if s.tcp != nil {
+		if sender {
+			if retransmits, ok := tcpRetransmits(s.tcp); ok {
+				result.Retransmits = retransmits
+			}
+		}
+		return result
+	}
+	if sender {
+		result.Packets = s.packets.Load()
+		return result
+	}
+	result.Packets, result.Errors, result.Jitter = s.stats.snapshot()
+	return result
+}
+
+// udpStats tracks loss and jitter of received UDP packets the way iperf3 does
+type udpStats struct {
+	mu          sync.Mutex
+	packetCount int64 // 收到的最大序号
+	errors      int64
+	outOfOrder  int64
+	jitter      float64
+	prevTransit float64
+	started     bool
+}
+
+// record processes one packet, its header carries the send time in seconds and microseconds and the sequence number
+func (u *udpStats) record(packet []byte, arrival time.Time, counters64 bool) {
+	if len(packet) < 12 || counters64 && len(packet) < 16 {
+		return
+	}
+	sec := binary.BigEndian.Uint32(packet[0:4])
+	usec := binary.BigEndian.Uint32(packet[4:8])
+	var count int64
+	if counters64 {
+		count = int64(binary.BigEndian.Uint64(packet[8:16]))
+	} else {
+		count = int64(binary.BigEndian.Uint32(packet[8:12]))
+	}
+	sent := float64(sec) + float64(usec)/1e6
+	transit := float64(arrival.UnixMicro())/1e6 - sent
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if count >= u.packetCount+1 {
+		if count > u.packetCount+1 {
+			u.errors += count - 1 - u.packetCount
+		}
+		u.packetCount = count
+	} else {
+		// 乱序到达的包此前已被计为丢失
+		u.outOfOrder++
+		if u.errors > 0 {
+			u.errors--
+		}
+	}
+	// RFC 1889 的抖动算法，两端时钟偏差在差值中抵消
+	if u.started {
+		d := transit - u.prevTransit
+		if d < 0 {
+			d = -d
+		}
+		u.jitter += (d - u.jitter) / 16
+	}
+	u.prevTransit = transit
+	u.started = true
+}
+
+func (u *udpStats) snapshot() (packets, errors int64, jitter float64) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.packetCount, u.errors, u.jitter
+}
+
+// sendTCP writes blocks until stop is closed
+func sendTCP(s *stream, length int, stop <-chan struct{}) {
+	buffer := make([]byte, length)
+	rand.Read(buffer)
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		n, err := s.write(buffer)
+		s.bytes.Add(uint64(n))
+		if err != nil {
+			return
+		}
+	}
+}
+
+// receive reads a TCP stream or a client side UDP stream until the connection is closed or interrupted
+func receive(s *stream, length int, counters64 bool) {
+	buffer := make([]byte, max(length, 64<<10))
+	for {
+		var n int
+		var err error
+		if s.tcp != nil {
+			n, err = s.tcp.Read(buffer)
+		} else {
+			n, err = s.udp.Read(buffer)
+			if n > 0 {
+				s.stats.record(buffer[:n], time.Now(), counters64)
+			}
+		}
+		s.bytes.Add(uint64(n))
+		if err != nil {
+			return
+		}
+	}
+}
+
+// sendUDP sends datagrams of length bytes paced to rate bits per second, 0 means unpaced, until stop is closed
+func sendUDP(s *stream, length int, rate uint64, counters64 bool, stop <-chan struct{}) {
+	buffer := make([]byte, max(length, 16))
+	start := time.Now()
+	var sent uint64
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		// 按目标速率计算当前允许发送的字节数，不足一个包时短暂休眠
+		if rate > 0 {
+			allowed := uint64(time.Since(start).Seconds() * float64(rate) / 8)
+			if sent+uint64(len(buffer)) > allowed {
+				time.Sleep(time.Millisecond)
+				continue
+			}
+		}
+		now := time.Now()
+		count := s.packets.Add(1)
+		binary.BigEndian.PutUint32(buffer[0:4], uint32(now.Unix()))
+		binary.BigEndian.PutUint32(buffer[4:8], uint32(now.Nanosecond()/1000))
+		if counters64 {
+			binary.BigEndian.PutUint64(buffer[8:16], uint64(count))
+		} else {
+			binary.BigEndian.PutUint32(buffer[8:12], uint32(count))
+		}
+		n, err := s.write(buffer)
+		sent += uint64(len(buffer))
+		s.bytes.Add(uint64(n))
+		if err != nil {
+			// 发送缓冲区满时丢弃该包，与 iperf3 一样计入发送数
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				return
+			}
+			select {
+			case <-stop:
+				return
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package iperf
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// tcpRetransmits returns the total retransmitted segments of a TCP connection from TCP_INFO
+func tcpRetransmits(conn net.Conn) (int64, bool) {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return 0, false
+	}
+	raw, err := tcpConn.SyscallConn()
+	if err != nil {
+		return 0, false
+	}
+	var info *unix.TCPInfo
+	var infoErr error
+	raw.Control(func(fd uintptr) {
+		info, infoErr = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+	})
+	if infoErr != nil || info == nil {
+		return 0, false
+	}
+	return int64(info.Total_retrans), true
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package iperf
+
+import "net"
+
+// tcpRetransmits is only available on Linux
+func tcpRetransmits(conn net.Conn) (int64, bool) {
+	return 0, false
+}
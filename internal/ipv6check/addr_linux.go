@@ -0,0 +1,98 @@
+package ipv6check
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// echoTarget answers ICMPv6 echo requests, its replies to an unused address of the /64 reveal how the upstream
+// delivers the prefix
+var echoTarget = &net.IPAddr{IP: net.ParseIP("2001:4860:4860::8888")}
+
+// addresses reads the global IPv6 addresses with their flags from /proc/net/if_inet6
+func addresses() []Address {
+	data, err := os.ReadFile("/proc/net/if_inet6")
+	if err != nil {
+		return interfaceAddresses()
+	}
+	var result []Address
+	for _, line := range strings.Split(string(data), "\n") {
+		if address, ok := parseInet6(line); ok {
+			result = append(result, address)
+		}
+	}
+	return result
+}
+
+// prefixRouting sends echo requests from an unused address of the /64 of address and watches the IPv6 packets
+// of the host for a neighbor solicitation of that address or the echo reply itself
+func prefixRouting(address *Address) (string, error) {
+	if os.Geteuid() != 0 {
+		return "", errors.New("requires root")
+	}
+	candidate := make(net.IP, net.IPv6len)
+	copy(candidate, net.ParseIP(address.Address))
+	// 保留末 24 位，上游的邻居请求会发往本机已加入的请求节点组播地址
+	rand.Read(candidate[8:13])
+	// SOCK_DGRAM 的 packet socket 收到的数据不含链路层头，协议号为网络字节序
+	protocol := binary.NativeEndian.Uint16(binary.BigEndian.AppendUint16(nil, unix.ETH_P_IPV6))
+	capture, err := unix.Socket(unix.AF_PACKET, unix.SOCK_DGRAM, int(protocol))
+	if err != nil {
+		return "", err
+	}
+	defer unix.Close(capture)
+	timeout := unix.NsecToTimeval((200 * time.Millisecond).Nanoseconds())
+	if err := unix.SetsockoptTimeval(capture, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &timeout); err != nil {
+		return "", err
+	}
+	// IPV6_FREEBIND 允许绑定未配置在网卡上的地址
+	config := net.ListenConfig{Control: func(network, address string, conn syscall.RawConn) error {
+		var optErr error
+		conn.Control(func(fd uintptr) {
+			optErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_FREEBIND, 1)
+		})
+		return optErr
+	}}
+	conn, err := config.ListenPacket(context.Background(), "ip6:ipv6-icmp", candidate.String())
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	random := make([]byte, 2)
+	rand.Read(random)
+	id := binary.BigEndian.Uint16(random)
+	buffer := make([]byte, 2048)
+	for seq := uint16(1); seq <= 3; seq++ {
+		// 内核为原始 ICMPv6 套接字填写校验和
+		request := make([]byte, 8)
+		request[0] = 128
+		binary.BigEndian.PutUint16(request[4:], id)
+		binary.BigEndian.PutUint16(request[6:], seq)
+		if _, err := conn.WriteTo(request, echoTarget); err != nil {
+			return "", err
+		}
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			n, from, err := unix.Recvfrom(capture, buffer, 0)
+			if err != nil {
+				continue
+			}
+			if link, ok := from.(*unix.SockaddrLinklayer); ok && link.Pkttype == unix.PACKET_OUTGOING {
+				continue
+			}
+			if routing := classify(buffer[:n], candidate, id); routing != "" {
+				return routing, nil
+			}
+		}
+	}
+	return "", errors.New("no echo reply or neighbor solicitation received")
+}
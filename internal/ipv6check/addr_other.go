@@ -0,0 +1,15 @@
+//go:build !linux
+
+package ipv6check
+
+import "errors"
+
+// addresses lists the global IPv6 addresses, their origin is only known on Linux
+func addresses() []Address {
+	return interfaceAddresses()
+}
+
+// prefixRouting is only available on Linux
+func prefixRouting(address *Address) (string, error) {
+	return "", errors.New("/64 routing detection is only supported on Linux")
+}
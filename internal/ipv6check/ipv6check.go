@@ -0,0 +1,279 @@
+// Package ipv6check reports the IPv6 addresses of the host with their prefix length and origin,
+// whether the /64 is routed to the host or on-link, and compares the reachability and latency of
+// well-known targets over IPv6 with IPv4
+package ipv6check
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/oneclickvirt/ecs/internal/pingstats"
+)
+
+// Address origins
+const (
+	OriginStatic    = "static"
+	OriginSLAAC     = "slaac"
+	OriginTemporary = "temporary" // SLAAC 隐私扩展地址
+	OriginDHCPv6    = "dhcpv6"
+)
+
+// /64 routing modes
+const (
+	PrefixRouted = "routed"  // 上游把整个 /64 路由到本机，无需 NDP
+	PrefixOnLink = "on-link" // 上游对 /64 内的每个地址发送邻居请求
+)
+
+// Address is one global IPv6 address bound on an interface
+type Address struct {
+	Interface    string `json:"interface"`
+	Address      string `json:"address"`
+	PrefixLength int    `json:"prefix_length"`
+	Origin       string `json:"origin,omitempty"`
+}
+
+// Target is a well-known service reachable over both IPv4 and IPv6
+type Target struct {
+	Name string
+	IPv4 string
+	IPv6 string
+}
+
+// Targets are compared over IPv4 and IPv6 with TCP connects to port 443
+var Targets = []Target{
+	{Name: "Google DNS", IPv4: "8.8.8.8", IPv6: "2001:4860:4860::8888"},
+	{Name: "Cloudflare DNS", IPv4: "1.1.1.1", IPv6: "2606:4700:4700::1111"},
+	{Name: "Quad9", IPv4: "9.9.9.9", IPv6: "2620:fe::fe"},
+	{Name: "AliDNS", IPv4: "223.5.5.5", IPv6: "2400:3200::1"},
+}
+
+// Comparison is the latency of one target over both stacks
+type Comparison struct {
+	Name string           `json:"name"`
+	IPv4 *pingstats.Stats `json:"ipv4"`
+	IPv6 *pingstats.Stats `json:"ipv6"`
+}
+
+// Result is the IPv6 section
+type Result struct {
+	Addresses []Address `json:"addresses,omitempty"`
+	// Prefix is the /64 tested for routing, Routing is PrefixRouted, PrefixOnLink or empty when it could not be tested
+	Prefix       string       `json:"prefix,omitempty"`
+	Routing      string       `json:"routing,omitempty"`
+	RoutingError string       `json:"routing_error,omitempty"`
+	Targets      []Comparison `json:"targets"`
+}
+
+// parseInet6 parses one line of /proc/net/if_inet6: address, index, prefix length, scope and flags in hex, name
+func parseInet6(line string) (Address, bool) {
+	fields := strings.Fields(line)
+	if len(fields) != 6 || len(fields[0]) != 32 {
+		return Address{}, false
+	}
+	raw, err := hex.DecodeString(fields[0])
+	if err != nil {
+		return Address{}, false
+	}
+	prefix, err1 := strconv.ParseUint(fields[2], 16, 8)
+	flags, err2 := strconv.ParseUint(fields[4], 16, 32)
+	ip := net.IP(raw)
+	if err1 != nil || err2 != nil || !ip.IsGlobalUnicast() {
+		return Address{}, false
+	}
+	address := Address{Interface: fields[5], Address: ip.String(), PrefixLength: int(prefix)}
+	// IFA_F_TEMPORARY 与 IFA_F_PERMANENT，内核按路由通告生成的地址两者都没有
+	switch {
+	case flags&0x01 != 0:
+		address.Origin = OriginTemporary
+	case flags&0x80 != 0:
+		address.Origin = OriginStatic
+	case prefix == 128:
+		address.Origin = OriginDHCPv6
+	default:
+		address.Origin = OriginSLAAC
+	}
+	return address, true
+}
+
+// interfaceAddresses lists the global IPv6 addresses with net.Interfaces, without their origin
+func interfaceAddresses() []Address {
+	var addresses []Address
+	interfaces, _ := net.Interfaces()
+	for _, iface := range interfaces {
+		addrs, _ := iface.Addrs()
+		for _, addr := range addrs {
+			network, ok := addr.(*net.IPNet)
+			if !ok || network.IP.To4() != nil || !network.IP.IsGlobalUnicast() {
+				continue
+			}
+			ones, _ := network.Mask.Size()
+			addresses = append(addresses, Address{Interface: iface.Name, Address: network.IP.String(), PrefixLength: ones})
+		}
+	}
+	return addresses
+}
+
+// prefix64 returns the /64 of address
+func prefix64(address string) *net.IPNet {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return nil
+	}
+	mask := net.CIDRMask(64, 128)
+	return &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+}
+
+// classify inspects one IPv6 packet seen while probing from the unused address candidate, a neighbor solicitation
+// for it means the upstream resolves every address of the /64 on the link, an echo reply delivered to it means
+// the /64 is routed to the host
+func classify(packet []byte, candidate net.IP, id uint16) string {
+	if len(packet) < 40+8 || packet[0]>>4 != 6 || packet[6] != 58 {
+		return ""
+	}
+	message := packet[40:]
+	switch {
+	case message[0] == 135 && len(message) >= 24 && net.IP(message[8:24]).Equal(candidate):
+		return PrefixOnLink
+	case message[0] == 129 && net.IP(packet[24:40]).Equal(candidate) && binary.BigEndian.Uint16(message[4:]) == id:
+		return PrefixRouted
+	}
+	return ""
+}
+
+// routingCandidate picks the public, non temporary address whose /64 is tested, ULAs are never routed from outside
+func routingCandidate(addresses []Address) *Address {
+	for i := range addresses {
+		ip := net.ParseIP(addresses[i].Address)
+		if !ip.IsPrivate() && addresses[i].Origin != OriginTemporary && addresses[i].PrefixLength <= 64 {
+			return &addresses[i]
+		}
+	}
+	for i := range addresses {
+		if !net.ParseIP(addresses[i].Address).IsPrivate() && addresses[i].Origin != OriginTemporary {
+			return &addresses[i]
+		}
+	}
+	return nil
+}
+
+// Run lists the addresses, tests the routing of the /64 and measures the targets over both stacks
+func Run(opts pingstats.Options) *Result {
+	result := &Result{Addresses: addresses()}
+	var wg sync.WaitGroup
+	if candidate := routingCandidate(result.Addresses); candidate != nil {
+		result.Prefix = prefix64(candidate.Address).String()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			routing, err := prefixRouting(candidate)
+			result.Routing = routing
+			if err != nil {
+				result.RoutingError = err.Error()
+			}
+		}()
+	}
+	var targets []pingstats.Target
+	for _, target := range Targets {
+		targets = append(targets,
+			pingstats.Target{Group: "ipv4", Name: target.Name, Host: target.IPv4, Port: 443, Method: pingstats.MethodTCP},
+			pingstats.Target{Group: "ipv6", Name: target.Name, Host: target.IPv6, Port: 443, Method: pingstats.MethodTCP})
+	}
+	stats := pingstats.Run(targets, opts, len(targets))
+	for i, target := range Targets {
+		result.Targets = append(result.Targets, Comparison{Name: target.Name, IPv4: stats[2*i], IPv6: stats[2*i+1]})
+	}
+	wg.Wait()
+	return result
+}
+
+// Reachable returns the number of targets that answered over IPv6
+func (r *Result) Reachable() int {
+	count := 0
+	for _, target := range r.Targets {
+		if target.IPv6.Received > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+func latency(stats *pingstats.Stats) string {
+	if stats == nil || stats.Received == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f", stats.Avg)
+}
+
+// Format renders the addresses, the routing of the /64 and the latency of the targets over both stacks
+func Format(result *Result, language string) string {
+	var builder strings.Builder
+	zh := language == "zh"
+	origins := map[string]string{OriginStatic: "static", OriginSLAAC: "SLAAC", OriginTemporary: "SLAAC (temporary)", OriginDHCPv6: "DHCPv6", "": "-"}
+	if zh {
+		origins = map[string]string{OriginStatic: "静态", OriginSLAAC: "SLAAC", OriginTemporary: "SLAAC (临时)", OriginDHCPv6: "DHCPv6", "": "-"}
+	}
+	if len(result.Addresses) == 0 {
+		if zh {
+			builder.WriteString("未检测到全局IPv6地址\n")
+		} else {
+			builder.WriteString("No global IPv6 address found\n")
+		}
+	}
+	for _, address := range result.Addresses {
+		builder.WriteString(fmt.Sprintf("%-10s %s/%d  %s\n", address.Interface, address.Address, address.PrefixLength, origins[address.Origin]))
+	}
+	if result.Prefix != "" {
+		var routing string
+		switch {
+		case result.Routing == PrefixRouted && zh:
+			routing = "已路由到本机，可直接使用其中任意地址"
+		case result.Routing == PrefixRouted:
+			routing = "routed to this host, any address in it can be used"
+		case result.Routing == PrefixOnLink && zh:
+			routing = "在链路上(on-link)，上游对每个地址发送邻居请求，额外地址需要NDP代理"
+		case result.Routing == PrefixOnLink:
+			routing = "on-link, the upstream solicits every address, extra addresses need an NDP proxy"
+		case zh:
+			routing = "无法检测 (" + result.RoutingError + ")"
+		default:
+			routing = "not tested (" + result.RoutingError + ")"
+		}
+		if zh {
+			builder.WriteString(fmt.Sprintf("%s 路由方式: %s\n", result.Prefix, routing))
+		} else {
+			builder.WriteString(fmt.Sprintf("%s: %s\n", result.Prefix, routing))
+		}
+	}
+	headers := []string{"Target (ms)", "IPv4", "IPv6", "Diff"}
+	if zh {
+		headers = []string{"目标 (ms)", "IPv4", "IPv6", "差值"}
+	}
+	builder.WriteString(runewidth.FillRight(headers[0], 18))
+	for _, header := range headers[1:] {
+		builder.WriteString(runewidth.FillLeft(header, 9))
+	}
+	builder.WriteString("\n")
+	for _, target := range result.Targets {
+		diff := "-"
+		if target.IPv4.Received > 0 && target.IPv6.Received > 0 {
+			diff = fmt.Sprintf("%+.1f", target.IPv6.Avg-target.IPv4.Avg)
+		}
+		builder.WriteString(runewidth.FillRight(target.Name, 18))
+		for _, value := range []string{latency(target.IPv4), latency(target.IPv6), diff} {
+			builder.WriteString(runewidth.FillLeft(value, 9))
+		}
+		builder.WriteString("\n")
+	}
+	if zh {
+		builder.WriteString(fmt.Sprintf("IPv6 可达目标: %d/%d\n", result.Reachable(), len(result.Targets)))
+	} else {
+		builder.WriteString(fmt.Sprintf("IPv6 reachable targets: %d/%d\n", result.Reachable(), len(result.Targets)))
+	}
+	return builder.String()
+}
@@ -0,0 +1,80 @@
+package ipv6check
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/oneclickvirt/ecs/internal/pingstats"
+)
+
+func TestParseInet6(t *testing.T) {
+	tests := []struct {
+		line string
+		want Address
+		ok   bool
+	}{
+		{"2001067c12340000021122fffe334455 02 40 00 00 eth0", Address{"eth0", "2001:67c:1234:0:211:22ff:fe33:4455", 64, OriginSLAAC}, true},
+		{"2001067c12340000a1b2c3d4e5f60708 02 40 00 01 eth0", Address{"eth0", "2001:67c:1234:0:a1b2:c3d4:e5f6:708", 64, OriginTemporary}, true},
+		{"2001067c123400000000000000000010 02 40 00 80 eth0", Address{"eth0", "2001:67c:1234::10", 64, OriginStatic}, true},
+		{"2001067c1234000000000000000000aa 02 80 00 00 eth0", Address{"eth0", "2001:67c:1234::aa", 128, OriginDHCPv6}, true},
+		{"fe80000000000000021122fffe334455 02 40 20 80 eth0", Address{}, false},
+		{"00000000000000000000000000000001 01 80 10 80 lo", Address{}, false},
+		{"garbage", Address{}, false},
+	}
+	for _, test := range tests {
+		got, ok := parseInet6(test.line)
+		if ok != test.ok || got != test.want {
+			t.Errorf("parseInet6(%q) = %+v, %v, want %+v, %v", test.line, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	candidate := net.ParseIP("2001:db8:1:2:aaaa:bbbb:cc33:4455")
+	packet := func(dst net.IP, message []byte) []byte {
+		header := make([]byte, 40)
+		header[0], header[6] = 0x60, 58
+		copy(header[8:], net.ParseIP("fe80::1"))
+		copy(header[24:], dst)
+		return append(header, message...)
+	}
+	solicitation := make([]byte, 24)
+	solicitation[0] = 135
+	copy(solicitation[8:], candidate)
+	reply := make([]byte, 8)
+	reply[0] = 129
+	binary.BigEndian.PutUint16(reply[4:], 7)
+	if got := classify(packet(net.ParseIP("ff02::1:ff33:4455"), solicitation), candidate, 7); got != PrefixOnLink {
+		t.Errorf("solicitation = %q", got)
+	}
+	if got := classify(packet(candidate, reply), candidate, 7); got != PrefixRouted {
+		t.Errorf("reply = %q", got)
+	}
+	if got := classify(packet(candidate, reply), candidate, 8); got != "" {
+		t.Errorf("reply with another id = %q", got)
+	}
+	copy(solicitation[8:], net.ParseIP("2001:db8:1:2::1"))
+	if got := classify(packet(net.ParseIP("ff02::1:ff00:1"), solicitation), candidate, 7); got != "" {
+		t.Errorf("solicitation of another address = %q", got)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	result := &Result{
+		Addresses: []Address{{"eth0", "2001:db8::10", 64, OriginStatic}},
+		Prefix:    "2001:db8::/64",
+		Routing:   PrefixOnLink,
+		Targets: []Comparison{
+			{Name: "Google DNS", IPv4: &pingstats.Stats{Received: 10, Avg: 10}, IPv6: &pingstats.Stats{Received: 10, Avg: 12.5}},
+			{Name: "AliDNS", IPv4: &pingstats.Stats{Received: 10, Avg: 30}, IPv6: &pingstats.Stats{}},
+		},
+	}
+	text := Format(result, "en")
+	for _, want := range []string{"2001:db8::10/64  static", "2001:db8::/64: on-link", "+2.5", "IPv6 reachable targets: 1/2"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Format missing %q:\n%s", want, text)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+// Package listenaddr checks the listen addresses of the goecs subcommands that serve HTTP
+package listenaddr
+
+import "net"
+
+// IsLoopback reports whether listen only accepts connections from the local host
+func IsLoopback(listen string) bool {
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,20 @@
+package listenaddr
+
+import "testing"
+
+func TestIsLoopback(t *testing.T) {
+	tests := map[string]bool{
+		"127.0.0.1:8080": true,
+		"[::1]:8080":     true,
+		"localhost:8080": true,
+		":8080":          false,
+		"0.0.0.0:8080":   false,
+		"10.0.0.1:8080":  false,
+		"127.0.0.1":      false,
+	}
+	for listen, want := range tests {
+		if got := IsLoopback(listen); got != want {
+			t.Errorf("IsLoopback(%q) = %v, want %v", listen, got, want)
+		}
+	}
+}
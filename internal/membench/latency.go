@@ -0,0 +1,213 @@
+package membench
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oneclickvirt/ecs/internal/cpubench"
+	"github.com/oneclickvirt/ecs/internal/report"
+)
+
+const (
+	// cacheLine is the distance between two nodes of the chain, one load per cache line
+	cacheLine = 64
+	// minWorkingSet and maxWorkingSet bound the tested working set sizes
+	minWorkingSet = 4 << 10
+	maxWorkingSet = 256 << 20
+	// loadsPerSize is the number of dependent loads timed for each working set size
+	loadsPerSize = 4 << 20
+)
+
+// Point is the average latency of one dependent load at a working set size
+type Point struct {
+	Size    int
+	Latency float64 // 纳秒
+}
+
+// Level is the latency plateau of one cache level or DRAM
+type Level struct {
+	Name    string
+	Size    int // 取值所用的工作集大小
+	Latency float64
+}
+
+// sink keeps the compiler from optimizing the chase away
+var sink uint64
+
+// chase builds a random single cycle over the cache lines of size bytes and times loads dependent loads
+func chase(size, loads int) float64 {
+	// 节点固定为 8 字节，32 位平台上 int 只有 4 字节会使工作集减半
+	stride := cacheLine / 8
+	lines := size / cacheLine
+	if lines < 2 {
+		lines = 2
+	}
+	chain := make([]uint64, lines*stride)
+	// Sattolo 算法生成单一环，随机顺序使硬件预取失效
+	order := make([]int, lines)
+	for i := range order {
+		order[i] = i
+	}
+	for i := lines - 1; i > 0; i-- {
+		j := rand.IntN(i)
+		order[i], order[j] = order[j], order[i]
+	}
+	for i := 0; i < lines; i++ {
+		chain[order[i]*stride] = uint64(order[(i+1)%lines] * stride)
+	}
+	// 先走一圈预热缓存和页表
+	index := uint64(0)
+	for i := 0; i < lines; i++ {
+		index = chain[index]
+	}
+	start := time.Now()
+	for i := 0; i < loads; i += 8 {
+		index = chain[index]
+		index = chain[index]
+		index = chain[index]
+		index = chain[index]
+		index = chain[index]
+		index = chain[index]
+		index = chain[index]
+		index = chain[index]
+	}
+	elapsed := time.Since(start)
+	sink += index
+	return float64(elapsed.Nanoseconds()) / float64(loads)
+}
+
+// Measure returns the latency curve from 4 KiB up to limit bytes, doubling the working set each step
+func Measure(limit int) []Point {
+	if limit <= 0 || limit > maxWorkingSet {
+		limit = maxWorkingSet
+	}
+	var points []Point
+	for size := minWorkingSet; size <= limit; size *= 2 {
+		points = append(points, Point{Size: size, Latency: chase(size, loadsPerSize)})
+	}
+	return points
+}
+
+// cacheSizes returns the L1d, L2 and L3 sizes from sysfs, a level the CPU does not have is 0.
+// Common values are used when sysfs lists no data caches at all
+func cacheSizes() [3]int {
+	var sizes [3]int
+	if topology := cpubench.DetectTopology(); topology != nil {
+		for _, cache := range topology.Caches {
+			if cache.Type == "Instruction" || cache.Level < 1 || cache.Level > 3 {
+				continue
+			}
+			if size := parseSize(cache.Size); size > 0 {
+				sizes[cache.Level-1] = size
+			}
+		}
+	}
+	if sizes == [3]int{} {
+		return [3]int{32 << 10, 1 << 20, 32 << 20}
+	}
+	return sizes
+}
+
+// parseSize parses sysfs cache sizes such as "48K" or "32M"
+func parseSize(text string) int {
+	multiplier := 1
+	switch {
+	case strings.HasSuffix(text, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(text, "M"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(text, "G"):
+		multiplier = 1 << 30
+	}
+	value, err := strconv.Atoi(strings.TrimRight(text, "KMG"))
+	if err != nil {
+		return 0
+	}
+	return value * multiplier
+}
+
+// Levels picks the plateau of each level: the largest point that fits in half of the cache,
+// and for DRAM the largest point beyond the last level cache. Levels with a size of 0 are skipped
+func Levels(points []Point, caches [3]int) []Level {
+	if len(points) == 0 {
+		return nil
+	}
+	names := []string{"L1", "L2", "L3"}
+	var levels []Level
+	lower := 0
+	for i, cacheSize := range caches {
+		if cacheSize == 0 {
+			continue
+		}
+		var picked *Point
+		for j := range points {
+			if points[j].Size > lower && points[j].Size <= cacheSize/2 {
+				picked = &points[j]
+			}
+		}
+		if picked != nil {
+			levels = append(levels, Level{Name: names[i], Size: picked.Size, Latency: picked.Latency})
+		}
+		lower = cacheSize
+	}
+	if last := points[len(points)-1]; last.Size > lower {
+		levels = append(levels, Level{Name: "DRAM", Size: last.Size, Latency: last.Latency})
+	}
+	return levels
+}
+
+func formatSize(size int) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%dMB", size>>20)
+	case size >= 1<<10:
+		return fmt.Sprintf("%dKB", size>>10)
+	}
+	return fmt.Sprintf("%dB", size)
+}
+
+// LatencyTest measures the latency curve up to limit bytes and returns the plateaus,
+// formatted followed by the curve
+func LatencyTest(language string, limit int) ([]Level, string) {
+	points := Measure(limit)
+	levels := Levels(points, cacheSizes())
+	var builder strings.Builder
+	for _, level := range levels {
+		name := level.Name
+		if level.Name != "DRAM" {
+			if language == "zh" {
+				name += "缓存"
+			} else {
+				name += " Cache"
+			}
+		}
+		builder.WriteString(fmt.Sprintf("%s (%s): %.2f ns\n", name, formatSize(level.Size), level.Latency))
+	}
+	if language == "zh" {
+		builder.WriteString("工作集大小与延迟:\n")
+	} else {
+		builder.WriteString("Working Set vs Latency:\n")
+	}
+	for _, point := range points {
+		builder.WriteString(fmt.Sprintf("  %-8s %8.2f ns\n", formatSize(point.Size), point.Latency))
+	}
+	return levels, builder.String()
+}
+
+// Metrics returns the latency of each level as metrics of the memory section
+func Metrics(levels []Level) []report.Metric {
+	metrics := make([]report.Metric, 0, len(levels))
+	for _, level := range levels {
+		metrics = append(metrics, report.Metric{
+			Section:       "memory",
+			Name:          level.Name + " latency",
+			Value:         level.Latency,
+			Unit:          "ns",
+			LowerIsBetter: true,
+		})
+	}
+	return metrics
+}
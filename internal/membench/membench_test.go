@@ -0,0 +1,50 @@
+package membench
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLevels(t *testing.T) {
+	var points []Point
+	for size := minWorkingSet; size <= 64<<20; size *= 2 {
+		points = append(points, Point{Size: size, Latency: float64(size>>10) / 100})
+	}
+	levels := Levels(points, [3]int{48 << 10, 2 << 20, 32 << 20})
+	var got []string
+	for _, level := range levels {
+		got = append(got, level.Name+" "+formatSize(level.Size))
+	}
+	if strings.Join(got, ",") != "L1 16KB,L2 1MB,L3 16MB,DRAM 64MB" {
+		t.Fatalf("Levels = %v", got)
+	}
+	// 没有 L3 的 CPU 上超出 L2 的工作集都属于内存
+	got = nil
+	for _, level := range Levels(points, [3]int{64 << 10, 1 << 20, 0}) {
+		got = append(got, level.Name+" "+formatSize(level.Size))
+	}
+	if strings.Join(got, ",") != "L1 32KB,L2 512KB,DRAM 64MB" {
+		t.Fatalf("Levels without L3 = %v", got)
+	}
+	if levels := Levels(nil, [3]int{48 << 10, 2 << 20, 32 << 20}); len(levels) != 0 {
+		t.Fatalf("Levels(nil) = %v", levels)
+	}
+	if parseSize("48K") != 48<<10 || parseSize("32M") != 32<<20 || parseSize("x") != 0 {
+		t.Fatal("parseSize")
+	}
+}
+
+func TestLatencyTest(t *testing.T) {
+	// 各级缓存的取值大小取决于本机的缓存，只检查名称
+	levels, text := LatencyTest("en", 64<<10)
+	if !strings.Contains(text, "L1 Cache (") {
+		t.Fatalf("LatencyTest = %q", text)
+	}
+	if len(levels) == 0 || levels[0].Name != "L1" || levels[0].Latency <= 0 {
+		t.Fatalf("levels = %+v", levels)
+	}
+	metrics := Metrics(levels)
+	if len(metrics) != len(levels) || metrics[0].Key() != "memory/L1 latency" || !metrics[0].LowerIsBetter {
+		t.Fatalf("Metrics = %+v", metrics)
+	}
+}
@@ -0,0 +1,220 @@
+// Package memguard keeps the memory tests within the memory the host or container can actually spare
+package memguard
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/oneclickvirt/ecs/internal/cgroup"
+)
+
+// procRoot and sysRoot are the procfs and sysfs mount points, replaced in tests
+var (
+	procRoot = "/proc"
+	sysRoot  = "/sys"
+)
+
+const (
+	mb = 1 << 20
+	// maxLatencyMB is the largest working set of the latency test
+	maxLatencyMB = 256
+	// minNUMAMB is the smallest useful NUMA buffer, smaller ones would be served from the last level cache
+	minNUMAMB = 64
+)
+
+// requiredMB is the peak memory each bandwidth method needs in MB:
+// stream allocates three arrays of 10M doubles, dd writes a 1GB file to /dev/shm
+var requiredMB = map[string]uint64{
+	"stream":   256,
+	"dd":       1100,
+	"sysbench": 32,
+}
+
+// candidates lists the bandwidth methods tried for each requested method, lightest last
+var candidates = map[string][]string{
+	"stream":   {"stream", "sysbench"},
+	"auto":     {"stream", "sysbench"},
+	"dd":       {"dd", "stream", "sysbench"},
+	"winsat":   {"dd", "stream", "sysbench"},
+	"sysbench": {"sysbench"},
+}
+
+// Budget is the memory that can be used by the tests, all values are in MB
+type Budget struct {
+	AvailableMB uint64 `json:"available_mb"`
+	// cgroup 限制及当前用量，0 表示未限制
+	LimitMB uint64 `json:"limit_mb,omitempty"`
+	UsageMB uint64 `json:"usage_mb,omitempty"`
+	// UsableMB keeps a quarter of the smaller of MemAvailable and the cgroup headroom in reserve
+	UsableMB uint64 `json:"usable_mb"`
+}
+
+// Decision is how the memory section runs under the budget
+type Decision struct {
+	Requested string  `json:"requested"`
+	Method    string  `json:"method,omitempty"`
+	Skipped   bool    `json:"skipped,omitempty"`
+	Reason    string  `json:"reason,omitempty"`
+	Budget    *Budget `json:"budget,omitempty"`
+	// AllowDD is false when the dd fallback of the bandwidth test would not fit
+	AllowDD bool `json:"allow_dd"`
+	// LatencyMB and NUMAMB are the working sets of the latency and NUMA tests, 0 means skipped
+	LatencyMB int `json:"latency_mb,omitempty"`
+	NUMAMB    int `json:"numa_mb,omitempty"`
+}
+
+// Detect reads MemAvailable and the cgroup v2 or v1 memory limit, it returns nil when /proc/meminfo is unavailable
+func Detect() *Budget {
+	available, ok := memAvailable()
+	if !ok {
+		return nil
+	}
+	budget := &Budget{AvailableMB: available / mb}
+	usable := available
+	if limit, usage, ok := cgroupMemory(); ok {
+		budget.LimitMB = limit / mb
+		budget.UsageMB = usage / mb
+		headroom := uint64(0)
+		if limit > usage {
+			headroom = limit - usage
+		}
+		if headroom < usable {
+			usable = headroom
+		}
+	}
+	budget.UsableMB = usable / mb * 3 / 4
+	return budget
+}
+
+// memAvailable returns MemAvailable of /proc/meminfo in bytes
+func memAvailable() (uint64, bool) {
+	file, err := os.Open(filepath.Join(procRoot, "meminfo"))
+	if err != nil {
+		return 0, false
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemAvailable:" {
+			kB, err := strconv.ParseUint(fields[1], 10, 64)
+			return kB * 1024, err == nil
+		}
+	}
+	return 0, false
+}
+
+// cgroupMemory returns the smallest limit set on the cgroup of the process or its ancestors,
+// with the usage without reclaimable page cache of the cgroup holding that limit
+func cgroupMemory() (limit, usage uint64, ok bool) {
+	files := []struct {
+		limit, usage, inactive string
+	}{
+		{"memory.max", "memory.current", "inactive_file"},
+		{"memory.limit_in_bytes", "memory.usage_in_bytes", "total_inactive_file"},
+	}
+	for _, dir := range cgroup.Dirs(sysRoot, procRoot, "memory") {
+		for _, file := range files {
+			limitText, err := readTrimmed(filepath.Join(dir, file.limit))
+			if err != nil {
+				continue
+			}
+			value, err := strconv.ParseUint(limitText, 10, 64)
+			// cgroup v2 的 "max" 和 v1 接近 int64 上限的值都表示不限制
+			if err != nil || value >= 1<<60 || (ok && value >= limit) {
+				continue
+			}
+			usageText, _ := readTrimmed(filepath.Join(dir, file.usage))
+			current, _ := strconv.ParseUint(usageText, 10, 64)
+			if inactive := statValue(filepath.Join(dir, "memory.stat"), file.inactive); inactive < current {
+				current -= inactive
+			}
+			limit, usage, ok = value, current, true
+		}
+	}
+	return limit, usage, ok
+}
+
+func statValue(path, key string) uint64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == key {
+			value, _ := strconv.ParseUint(fields[1], 10, 64)
+			return value
+		}
+	}
+	return 0
+}
+
+func readTrimmed(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	return strings.TrimSpace(string(data)), err
+}
+
+// Plan keeps the requested bandwidth method when its heaviest candidate fits the budget, otherwise it
+// picks the heaviest one that fits, and sizes the latency and NUMA tests. Without a budget the requested
+// method runs unchanged
+func Plan(method string, budget *Budget) Decision {
+	decision := Decision{Requested: method, Method: method, Budget: budget, AllowDD: true, LatencyMB: maxLatencyMB, NUMAMB: maxLatencyMB}
+	list, ok := candidates[method]
+	if budget == nil || !ok {
+		return decision
+	}
+	decision.AllowDD = budget.UsableMB >= requiredMB["dd"]
+	decision.Method = ""
+	for _, candidate := range list {
+		if budget.UsableMB >= requiredMB[candidate] {
+			decision.Method = candidate
+			break
+		}
+	}
+	lightest := list[len(list)-1]
+	if decision.Method == "" {
+		decision.Skipped = true
+		decision.LatencyMB = 0
+		decision.NUMAMB = 0
+		decision.Reason = fmt.Sprintf("usable memory %dMB is below the %dMB needed by %s", budget.UsableMB, requiredMB[lightest], lightest)
+		return decision
+	}
+	if decision.Method != list[0] {
+		decision.Reason = fmt.Sprintf("usable memory %dMB is below the %dMB needed by %s, using %s", budget.UsableMB, requiredMB[list[0]], list[0], decision.Method)
+	} else {
+		// 最重的方法可用时保留请求的方法，auto 仍按 stream、dd、sysbench 的顺序回退
+		decision.Method = method
+	}
+	// 延迟测试占用与工作集相同的内存，NUMA 测试另需八分之一用于随机排列
+	for decision.LatencyMB > 0 && uint64(decision.LatencyMB) > budget.UsableMB/2 {
+		decision.LatencyMB /= 2
+	}
+	for decision.NUMAMB >= minNUMAMB && uint64(decision.NUMAMB)*9/8 > budget.UsableMB {
+		decision.NUMAMB /= 2
+	}
+	if decision.NUMAMB < minNUMAMB {
+		decision.NUMAMB = 0
+	}
+	return decision
+}
+
+// Format describes a skipped or downgraded test, it returns an empty string when the requested method runs unchanged
+func (d Decision) Format(language string) string {
+	if d.Reason == "" {
+		return ""
+	}
+	if language == "zh" {
+		list := candidates[d.Requested]
+		if d.Skipped {
+			lightest := list[len(list)-1]
+			return fmt.Sprintf("内存保护: 可用内存 %dMB 低于 %s 所需的 %dMB，已跳过内存测试\n", d.Budget.UsableMB, lightest, requiredMB[lightest])
+		}
+		return fmt.Sprintf("内存保护: 可用内存 %dMB 低于 %s 所需的 %dMB，改用 %s\n", d.Budget.UsableMB, list[0], requiredMB[list[0]], d.Method)
+	}
+	return "Memory Guard: " + d.Reason + "\n"
+}
@@ -0,0 +1,62 @@
+package memguard
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/oneclickvirt/ecs/internal/testutil"
+)
+
+func TestDetect(t *testing.T) {
+	testutil.Root(t, &procRoot)
+	testutil.Root(t, &sysRoot)
+	// 宿主机有 8GB 可用，但容器限制为 512MB，已用 200MB 其中 100MB 为可回收缓存
+	testutil.WriteFile(t, filepath.Join(procRoot, "meminfo"), "MemTotal:       16384000 kB\nMemAvailable:    8192000 kB")
+	testutil.WriteFile(t, filepath.Join(sysRoot, "fs/cgroup/memory.max"), "536870912")
+	testutil.WriteFile(t, filepath.Join(sysRoot, "fs/cgroup/memory.current"), "209715200")
+	testutil.WriteFile(t, filepath.Join(sysRoot, "fs/cgroup/memory.stat"), "anon 104857600\ninactive_file 104857600")
+	budget := Detect()
+	if budget == nil || budget.LimitMB != 512 || budget.UsageMB != 100 || budget.UsableMB != 309 {
+		t.Fatalf("Detect = %+v", budget)
+	}
+	testutil.WriteFile(t, filepath.Join(sysRoot, "fs/cgroup/memory.max"), "max")
+	if budget := Detect(); budget.LimitMB != 0 || budget.UsableMB != 6000 {
+		t.Fatalf("Detect without limit = %+v", budget)
+	}
+	// 没有 cgroup 命名空间的 systemd 服务，限制设置在上层的 slice 上
+	testutil.WriteFile(t, filepath.Join(procRoot, "self/cgroup"), "0::/app.slice/app.service")
+	testutil.WriteFile(t, filepath.Join(sysRoot, "fs/cgroup/app.slice/memory.max"), "1073741824")
+	testutil.WriteFile(t, filepath.Join(sysRoot, "fs/cgroup/app.slice/memory.current"), "104857600")
+	testutil.WriteFile(t, filepath.Join(sysRoot, "fs/cgroup/app.slice/app.service/memory.max"), "max")
+	if budget := Detect(); budget.LimitMB != 1024 || budget.UsageMB != 100 {
+		t.Fatalf("Detect with a nested cgroup = %+v", budget)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	if decision := Plan("dd", &Budget{UsableMB: 4096}); decision.Method != "dd" || !decision.AllowDD || decision.Reason != "" || decision.NUMAMB != 256 {
+		t.Fatalf("Plan with enough memory = %+v", decision)
+	}
+	// auto 自带 stream、dd、sysbench 的回退链，内存充足时不能被替换成单一方法
+	if decision := Plan("auto", &Budget{UsableMB: 4096}); decision.Method != "auto" || !decision.AllowDD || decision.Reason != "" {
+		t.Fatalf("Plan auto with enough memory = %+v", decision)
+	}
+	if decision := Plan("auto", &Budget{UsableMB: 100}); decision.Method != "sysbench" || decision.AllowDD {
+		t.Fatalf("Plan auto downgrade = %+v", decision)
+	}
+	decision := Plan("dd", &Budget{UsableMB: 300})
+	if decision.Method != "stream" || decision.AllowDD || decision.LatencyMB != 128 || decision.NUMAMB != 256 {
+		t.Fatalf("Plan downgrade = %+v", decision)
+	}
+	if text := decision.Format("en"); text != "Memory Guard: usable memory 300MB is below the 1100MB needed by dd, using stream\n" {
+		t.Fatalf("Format = %q", text)
+	}
+	decision = Plan("stream", &Budget{UsableMB: 20})
+	if !decision.Skipped || decision.LatencyMB != 0 || decision.NUMAMB != 0 || !strings.Contains(decision.Format("zh"), "已跳过") {
+		t.Fatalf("Plan skip = %+v", decision)
+	}
+	if decision := Plan("winsat", nil); decision.Method != "winsat" || !decision.AllowDD {
+		t.Fatalf("Plan without budget = %+v", decision)
+	}
+}
@@ -150,9 +150,70 @@ func PrintMenuOptions(preCheck utils.NetCheckResult, config *params.Config) {
 	}
 }
 
-// HandleMenuMode handles menu selection
-func HandleMenuMode(preCheck utils.NetCheckResult, config *params.Config) {
+// presetChoices maps preset names to the menu choice they stand for
+var presetChoices = map[string]string{
+	"full":            "1",
+	"minimal":         "2",
+	"standard":        "3",
+	"network-focused": "4",
+	"unlock-focused":  "5",
+	"network":         "6",
+	"unlock":          "7",
+	"hardware":        "8",
+	"ip-quality":      "9",
+	"route":           "10",
+}
+
+// IsPreset reports whether name is a known preset
+func IsPreset(name string) bool {
+	_, ok := presetChoices[name]
+	return ok
+}
+
+// ApplyPreset configures tests like the matching menu choice without prompting for input
+func ApplyPreset(name string, preCheck utils.NetCheckResult, config *params.Config) error {
+	choice, ok := presetChoices[name]
+	if !ok {
+		return fmt.Errorf("unknown preset %q", name)
+	}
 	savedParams := config.SaveUserSetParams()
+	resetTestStatus(config)
+	config.Choice = choice
+	switch choice {
+	case "1":
+		SetFullTestStatus(preCheck, config)
+	case "2":
+		SetMinimalTestStatus(preCheck, config)
+	case "3":
+		SetStandardTestStatus(preCheck, config)
+	case "4":
+		SetNetworkFocusedTestStatus(preCheck, config)
+	case "5":
+		SetUnlockFocusedTestStatus(preCheck, config)
+	case "6", "7", "9", "10":
+		if !preCheck.Connected {
+			return fmt.Errorf("preset %q requires network connection", name)
+		}
+		switch choice {
+		case "6":
+			SetNetworkOnlyTestStatus(config)
+		case "7":
+			SetUnlockOnlyTestStatus(config)
+		case "9":
+			SetIPQualityTestStatus(config)
+		case "10":
+			config.Nt3Location = "ALL"
+			SetRouteTestStatus(config)
+		}
+	case "8":
+		SetHardwareOnlyTestStatus(preCheck, config)
+	}
+	config.RestoreUserSetParams(savedParams)
+	return nil
+}
+
+// resetTestStatus disables every test before a menu choice or preset enables its own set
+func resetTestStatus(config *params.Config) {
 	config.BasicStatus = false
 	config.CpuTestStatus = false
 	config.MemoryTestStatus = false
@@ -166,6 +227,12 @@ func HandleMenuMode(preCheck utils.NetCheckResult, config *params.Config) {
 	config.TgdcTestStatus = false
 	config.WebTestStatus = false
 	config.AutoChangeDiskMethod = true
+}
+
+// HandleMenuMode handles menu selection
+func HandleMenuMode(preCheck utils.NetCheckResult, config *params.Config) {
+	savedParams := config.SaveUserSetParams()
+	resetTestStatus(config)
 	PrintMenuOptions(preCheck, config)
 Loop:
 	for {
@@ -0,0 +1,373 @@
+// Package natcheck reports the RFC 5780 NAT mapping and filtering behaviour of IPv4 and IPv6, whether the
+// public address is bound on a local interface and whether the gateway offers UPnP or NAT-PMP port mapping
+package natcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-nat"
+	"github.com/mattn/go-runewidth"
+	"github.com/oneclickvirt/ecs/internal/flaglist"
+	"github.com/oneclickvirt/gostun/model"
+	"github.com/oneclickvirt/gostun/stuncheck"
+	"github.com/pion/stun/v2"
+)
+
+// Behaviours reported by gostun, the mapping of a host without NAT is corrected with the binding request of Probe
+const (
+	BehaviourNoNAT        = "endpoint independent (no NAT)"
+	BehaviourInconclusive = "inconclusive"
+)
+
+// stunTimeout is the wait in seconds for one STUN response, tests lower it
+var stunTimeout = 3
+
+// discoveryTimeout bounds the search for UPnP and NAT-PMP gateways
+const discoveryTimeout = 3 * time.Second
+
+// gostunMutex serialises the tests of gostun, which keep their state in package variables
+var gostunMutex sync.Mutex
+
+// Stack is the NAT behaviour of one address family
+type Stack struct {
+	Family string `json:"family"` // ipv4 或 ipv6
+	Server string `json:"server,omitempty"`
+	// LocalAddress and MappedAddress are the two ends of the binding request, equal ports mean the port is preserved
+	LocalAddress  string `json:"local_address,omitempty"`
+	MappedAddress string `json:"mapped_address,omitempty"`
+	// Direct means the mapped IP is bound on a local interface, there is no NAT
+	Direct    bool   `json:"direct"`
+	Mapping   string `json:"mapping,omitempty"`
+	Filtering string `json:"filtering,omitempty"`
+	Type      string `json:"type,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Gateway is a port mapping service found on the local network
+type Gateway struct {
+	Protocol   string `json:"protocol"` // UPnP 或 NAT-PMP
+	Type       string `json:"type"`
+	Device     string `json:"device,omitempty"`
+	ExternalIP string `json:"external_ip,omitempty"`
+}
+
+// Result is the NAT section
+type Result struct {
+	IPv4 *Stack `json:"ipv4,omitempty"`
+	IPv6 *Stack `json:"ipv6,omitempty"`
+	// PublicAddresses are the global unicast, non private addresses bound on the interfaces
+	PublicAddresses []string  `json:"public_addresses,omitempty"`
+	Gateways        []Gateway `json:"gateways,omitempty"`
+}
+
+// ParseServers parses the -stun-servers value, host[:port] items separated by commas, port 3478 by default,
+// invalid items are returned separately
+func ParseServers(value string) (servers []string, invalid []string) {
+	for _, item := range flaglist.Split(value) {
+		// STUN 服务器不支持名称
+		host, port := flaglist.HostPort(item.Value, "3478")
+		if item.Label != "" || host == "" || strings.ContainsAny(host, "/?#[] ") || port == "" {
+			invalid = append(invalid, item.Raw)
+			continue
+		}
+		servers = append(servers, net.JoinHostPort(host, port))
+	}
+	return servers, invalid
+}
+
+// serversFor returns the servers usable by family, IP literals of the other family are left out
+func serversFor(servers []string, family string) []string {
+	if len(servers) == 0 {
+		return model.GetDefaultServers(family)
+	}
+	var usable []string
+	for _, server := range servers {
+		host, _, _ := net.SplitHostPort(server)
+		if ip := net.ParseIP(host); ip != nil && (ip.To4() != nil) != (family == "ipv4") {
+			continue
+		}
+		usable = append(usable, server)
+	}
+	return usable
+}
+
+// network returns the UDP network of family
+func network(family string) string {
+	if family == "ipv6" {
+		return "udp6"
+	}
+	return "udp4"
+}
+
+// bind sends one binding request to server and returns the local and the mapped address
+func bind(family, server string) (*net.UDPAddr, *net.UDPAddr, error) {
+	remote, err := net.ResolveUDPAddr(network(family), server)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn, err := net.DialUDP(network(family), nil, remote)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer conn.Close()
+	request := stun.MustBuild(stun.TransactionID, stun.BindingRequest)
+	buffer := make([]byte, 1500)
+	for attempt := 0; attempt < 2; attempt++ {
+		if _, err := conn.Write(request.Raw); err != nil {
+			return nil, nil, err
+		}
+		conn.SetReadDeadline(time.Now().Add(time.Duration(stunTimeout) * time.Second))
+		n, err := conn.Read(buffer)
+		if err != nil {
+			continue
+		}
+		response := &stun.Message{Raw: append([]byte(nil), buffer[:n]...)}
+		if response.Decode() != nil || response.TransactionID != request.TransactionID {
+			continue
+		}
+		var mapped stun.XORMappedAddress
+		if err := mapped.GetFrom(response); err != nil {
+			return nil, nil, err
+		}
+		return conn.LocalAddr().(*net.UDPAddr), &net.UDPAddr{IP: mapped.IP, Port: mapped.Port}, nil
+	}
+	return nil, nil, errors.New("no STUN response")
+}
+
+// localAddresses returns the addresses bound on the interfaces
+func localAddresses() []net.IP {
+	var ips []net.IP
+	addrs, _ := net.InterfaceAddrs()
+	for _, addr := range addrs {
+		if network, ok := addr.(*net.IPNet); ok {
+			ips = append(ips, network.IP)
+		}
+	}
+	return ips
+}
+
+// behaviour runs the RFC 5780 mapping and filtering tests of gostun against server
+func behaviour(family, server string) (string, string) {
+	gostunMutex.Lock()
+	defer gostunMutex.Unlock()
+	model.EnableLoger = false
+	model.Timeout = stunTimeout
+	model.IPVersion = family
+	model.NatMappingBehavior, model.NatFilteringBehavior = "", ""
+	if err := stuncheck.MappingTests(server); err != nil || model.NatMappingBehavior == "" {
+		model.NatMappingBehavior = BehaviourInconclusive
+	}
+	if err := stuncheck.FilteringTests(server); err != nil || model.NatFilteringBehavior == "" {
+		model.NatFilteringBehavior = BehaviourInconclusive
+	}
+	return model.NatMappingBehavior, model.NatFilteringBehavior
+}
+
+// natType summarises the behaviours the way gostun and the basic information section do
+func natType(mapping, filtering string) string {
+	gostunMutex.Lock()
+	defer gostunMutex.Unlock()
+	model.NatMappingBehavior, model.NatFilteringBehavior = mapping, filtering
+	return strings.TrimSpace(stuncheck.CheckType())
+}
+
+// Probe measures the NAT behaviour of family with the first of servers that answers,
+// servers that do not support RFC 5780 are skipped while a later one might
+func Probe(family string, servers []string) *Stack {
+	stack := &Stack{Family: family}
+	candidates := serversFor(servers, family)
+	if len(candidates) == 0 {
+		stack.Error = "no STUN server for " + family
+		return stack
+	}
+	var lastErr error
+	for _, server := range candidates {
+		local, mapped, err := bind(family, server)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		mapping, filtering := behaviour(family, server)
+		if stack.Server == "" || (mapping != BehaviourInconclusive && filtering != BehaviourInconclusive) {
+			stack.Server, stack.Mapping, stack.Filtering = server, mapping, filtering
+			stack.LocalAddress, stack.MappedAddress = local.String(), mapped.String()
+			stack.Direct = false
+			for _, ip := range localAddresses() {
+				stack.Direct = stack.Direct || ip.Equal(mapped.IP)
+			}
+		}
+		if stack.Mapping != BehaviourInconclusive && stack.Filtering != BehaviourInconclusive {
+			break
+		}
+	}
+	if stack.Server == "" {
+		stack.Error = lastErr.Error()
+		return stack
+	}
+	// gostun 以未指定地址比较映射地址，无法识别无 NAT 的情况
+	switch {
+	case stack.Direct && stack.Filtering == "endpoint independent":
+		stack.Mapping, stack.Type = BehaviourNoNAT, "Open Internet"
+	case stack.Direct && stack.Filtering != BehaviourInconclusive:
+		stack.Mapping, stack.Type = BehaviourNoNAT, "Firewalled"
+	default:
+		stack.Type = natType(stack.Mapping, stack.Filtering)
+	}
+	return stack
+}
+
+// hasRoute reports whether the host has a route for family
+func hasRoute(family string) bool {
+	target := "8.8.8.8:53"
+	if family == "ipv6" {
+		target = "[2001:4860:4860::8888]:53"
+	}
+	conn, err := net.Dial(network(family), target)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// publicAddresses returns the global unicast, non private addresses of the interfaces
+func publicAddresses() []string {
+	var public []string
+	for _, ip := range localAddresses() {
+		if ip.IsGlobalUnicast() && !ip.IsPrivate() {
+			public = append(public, ip.String())
+		}
+	}
+	return public
+}
+
+// Gateways looks for UPnP and NAT-PMP gateways on the local network
+func Gateways() []Gateway {
+	ctx, cancel := context.WithTimeout(context.Background(), discoveryTimeout)
+	defer cancel()
+	var gateways []Gateway
+	for found := range nat.DiscoverNATs(ctx) {
+		gateway := Gateway{Protocol: "UPnP", Type: found.Type()}
+		if strings.HasPrefix(gateway.Type, "NAT-PMP") {
+			gateway.Protocol = "NAT-PMP"
+		}
+		if device, err := found.GetDeviceAddress(); err == nil {
+			gateway.Device = device.String()
+		}
+		if external, err := found.GetExternalAddress(); err == nil {
+			gateway.ExternalIP = external.String()
+		}
+		gateways = append(gateways, gateway)
+	}
+	return gateways
+}
+
+// Run probes both families the host has a route for and looks for port mapping gateways,
+// servers replaces the default STUN servers of gostun when not empty
+func Run(servers []string) *Result {
+	result := &Result{PublicAddresses: publicAddresses()}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		result.Gateways = Gateways()
+	}()
+	if hasRoute("ipv4") {
+		result.IPv4 = Probe("ipv4", servers)
+	}
+	if hasRoute("ipv6") {
+		result.IPv6 = Probe("ipv6", servers)
+	}
+	wg.Wait()
+	return result
+}
+
+// Format renders the section in the key: value layout of the basic information section
+func Format(result *Result, language string) string {
+	var builder strings.Builder
+	zh := language == "zh"
+	line := func(key, value string) {
+		builder.WriteString(runewidth.FillRight(key, 20) + ": " + value + "\n")
+	}
+	for _, stack := range []*Stack{result.IPv4, result.IPv6} {
+		if stack == nil {
+			continue
+		}
+		name := "IPv4"
+		if stack.Family == "ipv6" {
+			name = "IPv6"
+		}
+		if stack.Error != "" {
+			if zh {
+				line(name+" NAT类型", "检测失败 ("+stack.Error+")")
+			} else {
+				line(name+" NAT Type", "failed ("+stack.Error+")")
+			}
+			continue
+		}
+		if zh {
+			line(name+" NAT类型", stack.Type)
+			line(name+" 映射行为", stack.Mapping)
+			line(name+" 过滤行为", stack.Filtering)
+			line(name+" 映射地址", fmt.Sprintf("%s -> %s", stack.LocalAddress, stack.MappedAddress))
+		} else {
+			line(name+" NAT Type", stack.Type)
+			line(name+" Mapping", stack.Mapping)
+			line(name+" Filtering", stack.Filtering)
+			line(name+" Mapped Address", fmt.Sprintf("%s -> %s", stack.LocalAddress, stack.MappedAddress))
+		}
+		switch {
+		case stack.Direct && zh:
+			line(name+" 公网IP", "绑定在本机网卡上")
+		case stack.Direct:
+			line(name+" Public IP", "bound on a local interface")
+		case zh:
+			line(name+" 公网IP", "未绑定在本机网卡上，位于NAT之后")
+		default:
+			line(name+" Public IP", "not bound locally, behind NAT")
+		}
+	}
+	public := strings.Join(result.PublicAddresses, ", ")
+	if zh {
+		if public == "" {
+			public = "无"
+		}
+		line("网卡公网地址", public)
+	} else {
+		if public == "" {
+			public = "none"
+		}
+		line("Interface Public IP", public)
+	}
+	for _, protocol := range []string{"UPnP", "NAT-PMP"} {
+		var found []string
+		for _, gateway := range result.Gateways {
+			if gateway.Protocol != protocol {
+				continue
+			}
+			text := gateway.Type
+			if gateway.Device != "" {
+				text += " @ " + gateway.Device
+			}
+			if gateway.ExternalIP != "" {
+				text += " -> " + gateway.ExternalIP
+			}
+			found = append(found, text)
+		}
+		switch {
+		case len(found) > 0:
+			line(protocol, strings.Join(found, ", "))
+		case zh:
+			line(protocol, "不可用")
+		default:
+			line(protocol, "not available")
+		}
+	}
+	return builder.String()
+}
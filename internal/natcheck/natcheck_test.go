@@ -0,0 +1,121 @@
+package natcheck
+
+import (
+	"net"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pion/stun/v2"
+)
+
+// stubServer is an RFC 5780 STUN server on 127.0.0.1 and 127.0.0.2, each with two ports
+type stubServer struct {
+	conns map[string]*net.UDPConn // 以 "ip:port" 为键
+	other *net.UDPAddr
+}
+
+// startStub starts the server, a filtering server ignores CHANGE-REQUEST like an address and port dependent NAT
+func startStub(t *testing.T, filtering bool) string {
+	primary, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	alternate, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ports := []int{primary.LocalAddr().(*net.UDPAddr).Port, alternate.LocalAddr().(*net.UDPAddr).Port}
+	server := &stubServer{conns: map[string]*net.UDPConn{}, other: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 2), Port: ports[1]}}
+	server.conns[primary.LocalAddr().String()] = primary
+	server.conns[alternate.LocalAddr().String()] = alternate
+	for _, port := range ports {
+		conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 2), Port: port})
+		if err != nil {
+			t.Skipf("127.0.0.2 is not usable: %v", err)
+		}
+		server.conns[conn.LocalAddr().String()] = conn
+	}
+	for _, conn := range server.conns {
+		go server.serve(conn, filtering)
+	}
+	t.Cleanup(func() {
+		for _, conn := range server.conns {
+			conn.Close()
+		}
+	})
+	return primary.LocalAddr().String()
+}
+
+func (s *stubServer) serve(conn *net.UDPConn, filtering bool) {
+	buffer := make([]byte, 1500)
+	for {
+		n, client, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			return
+		}
+		request := &stun.Message{Raw: append([]byte(nil), buffer[:n]...)}
+		if request.Decode() != nil {
+			continue
+		}
+		local := conn.LocalAddr().(*net.UDPAddr)
+		ip, port := local.IP, local.Port
+		if change, err := request.Get(stun.AttrChangeRequest); err == nil && len(change) == 4 {
+			if filtering {
+				continue
+			}
+			if change[3]&0x04 != 0 {
+				ip = s.other.IP
+				if ip.Equal(local.IP) {
+					ip = net.IPv4(127, 0, 0, 1)
+				}
+			}
+			if change[3]&0x02 != 0 {
+				for address := range s.conns {
+					_, other, _ := net.SplitHostPort(address)
+					if other != strconv.Itoa(local.Port) {
+						port, _ = strconv.Atoi(other)
+					}
+				}
+			}
+		}
+		response := stun.MustBuild(stun.NewTransactionIDSetter(request.TransactionID), stun.BindingSuccess,
+			&stun.XORMappedAddress{IP: client.IP, Port: client.Port},
+			&stun.OtherAddress{IP: s.other.IP, Port: s.other.Port})
+		s.conns[net.JoinHostPort(ip.String(), strconv.Itoa(port))].WriteToUDP(response.Raw, client)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the stub server needs 127.0.0.2")
+	}
+	stunTimeout = 1
+	open := Probe("ipv4", []string{"127.0.0.1:1", startStub(t, false)})
+	if open.Error != "" || !open.Direct || open.Mapping != BehaviourNoNAT || open.Filtering != "endpoint independent" || open.Type != "Open Internet" {
+		t.Fatalf("open = %+v", open)
+	}
+	if !strings.HasPrefix(open.MappedAddress, "127.0.0.1:") {
+		t.Fatalf("mapped address = %s", open.MappedAddress)
+	}
+	firewalled := Probe("ipv4", []string{startStub(t, true)})
+	if firewalled.Filtering != "address and port dependent" || firewalled.Type != "Firewalled" {
+		t.Fatalf("firewalled = %+v", firewalled)
+	}
+	text := Format(&Result{IPv4: open, Gateways: []Gateway{{Protocol: "NAT-PMP", Type: "NAT-PMP", Device: "192.168.1.1"}}}, "en")
+	if !strings.Contains(text, "IPv4 NAT Type       : Open Internet") || !strings.Contains(text, "UPnP                : not available") ||
+		!strings.Contains(text, "NAT-PMP             : NAT-PMP @ 192.168.1.1") {
+		t.Fatalf("Format = %s", text)
+	}
+}
+
+func TestParseServers(t *testing.T) {
+	servers, invalid := ParseServers("stun.example.com, 10.0.0.1:3479, [2001:db8::1], a/b")
+	if len(invalid) != 1 || strings.Join(servers, ",") != "stun.example.com:3478,10.0.0.1:3479,[2001:db8::1]:3478" {
+		t.Fatalf("servers = %q, invalid = %q", servers, invalid)
+	}
+	if got := serversFor(servers, "ipv6"); strings.Join(got, ",") != "stun.example.com:3478,[2001:db8::1]:3478" {
+		t.Fatalf("ipv6 servers = %q", got)
+	}
+}
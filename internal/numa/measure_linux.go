@@ -0,0 +1,112 @@
+package numa
+
+import (
+	"math/rand/v2"
+	"runtime"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// bandwidthPasses is the number of full reads timed for the bandwidth
+	bandwidthPasses = 4
+	// latencyLoads is the number of dependent loads timed for the latency
+	latencyLoads = 2 << 20
+	cacheLine    = 64
+	// mpolBind is MPOL_BIND of the mbind system call
+	mpolBind = 2
+)
+
+// sink keeps the compiler from optimizing the reads away
+var sink uint64
+
+// measure places a buffer of size bytes on memoryNode and reads it from the CPUs of cpuNode
+func measure(cpuNode, memoryNode Node, size int) (bandwidth, latency float64, err error) {
+	// 亲和性针对线程生效，测试期间固定在当前系统线程上
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	var original unix.CPUSet
+	if err := unix.SchedGetaffinity(0, &original); err != nil {
+		return 0, 0, err
+	}
+	defer unix.SchedSetaffinity(0, &original)
+	// 在内存节点的 CPU 上首次写入，mbind 失败时依靠 first-touch 策略完成放置
+	if err := pin(memoryNode); err != nil {
+		return 0, 0, err
+	}
+	memory, err := unix.Mmap(-1, 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_PRIVATE|unix.MAP_ANONYMOUS)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer unix.Munmap(memory)
+	bind(memory, memoryNode.ID)
+	words := unsafe.Slice((*uint64)(unsafe.Pointer(&memory[0])), len(memory)/8)
+	buildChain(words)
+	if err := pin(cpuNode); err != nil {
+		return 0, 0, err
+	}
+	return readBandwidth(words), chaseLatency(words), nil
+}
+
+func pin(node Node) error {
+	var set unix.CPUSet
+	for _, cpu := range node.CPUs {
+		set.Set(cpu)
+	}
+	return unix.SchedSetaffinity(0, &set)
+}
+
+// bind asks the kernel to place the pages of memory on node
+func bind(memory []byte, node int) {
+	if node >= 64 {
+		return
+	}
+	mask := uint64(1) << node
+	unix.Syscall6(unix.SYS_MBIND, uintptr(unsafe.Pointer(&memory[0])), uintptr(len(memory)),
+		mpolBind, uintptr(unsafe.Pointer(&mask)), 65, 0)
+}
+
+// buildChain writes a random single cycle over the cache lines of words, which also faults in every page
+func buildChain(words []uint64) {
+	stride := cacheLine / 8
+	lines := len(words) / stride
+	order := make([]int, lines)
+	for i := range order {
+		order[i] = i
+	}
+	for i := lines - 1; i > 0; i-- {
+		j := rand.IntN(i)
+		order[i], order[j] = order[j], order[i]
+	}
+	for i := 0; i < lines; i++ {
+		words[order[i]*stride] = uint64(order[(i+1)%lines] * stride)
+	}
+}
+
+// readBandwidth returns the sequential read bandwidth of one thread in MB/s
+func readBandwidth(words []uint64) float64 {
+	var sum uint64
+	start := time.Now()
+	for pass := 0; pass < bandwidthPasses; pass++ {
+		for i := 0; i < len(words); i += 4 {
+			sum += words[i] + words[i+1] + words[i+2] + words[i+3]
+		}
+	}
+	elapsed := time.Since(start)
+	sink += sum
+	return float64(len(words)*8*bandwidthPasses) / elapsed.Seconds() / (1 << 20)
+}
+
+// chaseLatency returns the average latency of one dependent load in nanoseconds
+func chaseLatency(words []uint64) float64 {
+	var index uint64
+	start := time.Now()
+	for i := 0; i < latencyLoads; i++ {
+		index = words[index]
+	}
+	elapsed := time.Since(start)
+	sink += index
+	return float64(elapsed.Nanoseconds()) / latencyLoads
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package numa
+
+import "errors"
+
+func measure(cpuNode, memoryNode Node, size int) (bandwidth, latency float64, err error) {
+	return 0, 0, errors.New("NUMA measurement is only supported on Linux")
+}
@@ -0,0 +1,181 @@
+// Package numa detects NUMA nodes and measures memory bandwidth and latency between them
+package numa
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sysRoot is the sysfs mount point, replaced in tests
+var sysRoot = "/sys"
+
+// Node is a NUMA node and the CPUs attached to it
+type Node struct {
+	ID   int   `json:"id"`
+	CPUs []int `json:"cpus"`
+}
+
+// Cell is the result of CPUs of one node accessing memory of another
+type Cell struct {
+	CPUNode       int     `json:"cpu_node"`
+	MemoryNode    int     `json:"memory_node"`
+	BandwidthMBps float64 `json:"bandwidth_mbps"`
+	LatencyNs     float64 `json:"latency_ns"`
+}
+
+// Matrix holds one cell per node pair, ordered by CPU node then memory node
+type Matrix struct {
+	Nodes []Node `json:"nodes"`
+	Cells []Cell `json:"cells"`
+}
+
+// Detect returns the NUMA nodes that have CPUs, it returns nil when sysfs is unavailable
+func Detect() []Node {
+	dirs, err := filepath.Glob(filepath.Join(sysRoot, "devices/system/node/node[0-9]*"))
+	if err != nil {
+		return nil
+	}
+	var nodes []Node
+	for _, dir := range dirs {
+		id, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "node"))
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "cpulist"))
+		if err != nil {
+			continue
+		}
+		// 仅有内存没有 CPU 的节点(如 CXL 扩展内存)无法作为访问发起方
+		cpus := parseCPUList(strings.TrimSpace(string(data)))
+		if len(cpus) == 0 {
+			continue
+		}
+		nodes = append(nodes, Node{ID: id, CPUs: cpus})
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes
+}
+
+// parseCPUList parses lists such as "0-3,8-11,16"
+func parseCPUList(text string) []int {
+	var cpus []int
+	for _, part := range strings.Split(text, ",") {
+		if part == "" {
+			continue
+		}
+		bounds := strings.SplitN(part, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			continue
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(bounds[1]); err != nil {
+				continue
+			}
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus
+}
+
+// Test measures every node pair with a buffer of sizeMB when more than one node exists,
+// it returns nil and an empty string otherwise
+func Test(language string, sizeMB int) (*Matrix, string) {
+	nodes := Detect()
+	if len(nodes) < 2 {
+		return nil, ""
+	}
+	matrix := &Matrix{Nodes: nodes}
+	for _, cpuNode := range nodes {
+		for _, memoryNode := range nodes {
+			bandwidth, latency, err := measure(cpuNode, memoryNode, sizeMB<<20)
+			if err != nil {
+				if language == "zh" {
+					return nil, fmt.Sprintf("NUMA测试失败: %v\n", err)
+				}
+				return nil, fmt.Sprintf("NUMA test failed: %v\n", err)
+			}
+			matrix.Cells = append(matrix.Cells, Cell{CPUNode: cpuNode.ID, MemoryNode: memoryNode.ID, BandwidthMBps: bandwidth, LatencyNs: latency})
+		}
+	}
+	return matrix, matrix.Format(language)
+}
+
+// averages returns the mean bandwidth and latency of local and remote cells
+func (m *Matrix) averages() (localBandwidth, remoteBandwidth, localLatency, remoteLatency float64) {
+	var local, remote int
+	for _, cell := range m.Cells {
+		if cell.CPUNode == cell.MemoryNode {
+			localBandwidth += cell.BandwidthMBps
+			localLatency += cell.LatencyNs
+			local++
+		} else {
+			remoteBandwidth += cell.BandwidthMBps
+			remoteLatency += cell.LatencyNs
+			remote++
+		}
+	}
+	if local > 0 {
+		localBandwidth /= float64(local)
+		localLatency /= float64(local)
+	}
+	if remote > 0 {
+		remoteBandwidth /= float64(remote)
+		remoteLatency /= float64(remote)
+	}
+	return
+}
+
+// Format renders the bandwidth and latency matrices, rows are CPU nodes and columns memory nodes
+func (m *Matrix) Format(language string) string {
+	if m == nil {
+		return ""
+	}
+	var builder strings.Builder
+	if language == "zh" {
+		builder.WriteString(fmt.Sprintf("NUMA节点: %d 个，行为CPU所在节点，列为内存所在节点\n", len(m.Nodes)))
+	} else {
+		builder.WriteString(fmt.Sprintf("NUMA Nodes: %d, rows are CPU nodes and columns are memory nodes\n", len(m.Nodes)))
+	}
+	header := fmt.Sprintf("%-8s", "")
+	for _, node := range m.Nodes {
+		header += fmt.Sprintf(" %10s", fmt.Sprintf("node%d", node.ID))
+	}
+	for _, table := range []struct {
+		zh, en string
+		value  func(Cell) float64
+	}{
+		{"单线程读取带宽 (MB/s)", "Single Thread Read Bandwidth (MB/s)", func(c Cell) float64 { return c.BandwidthMBps }},
+		{"访问延迟 (ns)", "Access Latency (ns)", func(c Cell) float64 { return c.LatencyNs }},
+	} {
+		if language == "zh" {
+			builder.WriteString(table.zh + "\n")
+		} else {
+			builder.WriteString(table.en + "\n")
+		}
+		builder.WriteString(header + "\n")
+		for i, node := range m.Nodes {
+			row := fmt.Sprintf("%-8s", fmt.Sprintf("node%d", node.ID))
+			for _, cell := range m.Cells[i*len(m.Nodes) : (i+1)*len(m.Nodes)] {
+				row += fmt.Sprintf(" %10.2f", table.value(cell))
+			}
+			builder.WriteString(row + "\n")
+		}
+	}
+	localBandwidth, remoteBandwidth, localLatency, remoteLatency := m.averages()
+	if language == "zh" {
+		builder.WriteString(fmt.Sprintf("NUMA本地带宽: %.2f MB/s\nNUMA远端带宽: %.2f MB/s\nNUMA本地延迟: %.2f ns\nNUMA远端延迟: %.2f ns\n",
+			localBandwidth, remoteBandwidth, localLatency, remoteLatency))
+	} else {
+		builder.WriteString(fmt.Sprintf("NUMA Local Bandwidth: %.2f MB/s\nNUMA Remote Bandwidth: %.2f MB/s\nNUMA Local Latency: %.2f ns\nNUMA Remote Latency: %.2f ns\n",
+			localBandwidth, remoteBandwidth, localLatency, remoteLatency))
+	}
+	return builder.String()
+}
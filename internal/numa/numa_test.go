@@ -0,0 +1,38 @@
+package numa
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/oneclickvirt/ecs/internal/testutil"
+)
+
+func TestDetect(t *testing.T) {
+	root := testutil.Root(t, &sysRoot)
+	for node, cpus := range []string{"0-3,8-11", "4-7,12-15", ""} {
+		testutil.WriteFile(t, filepath.Join(root, "devices/system/node", fmt.Sprintf("node%d", node), "cpulist"), cpus)
+	}
+	// 没有 CPU 的节点不参与测试
+	nodes := Detect()
+	if len(nodes) != 2 || fmt.Sprint(nodes[1].CPUs) != "[4 5 6 7 12 13 14 15]" {
+		t.Fatalf("Detect = %+v", nodes)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	matrix := &Matrix{
+		Nodes: []Node{{ID: 0}, {ID: 1}},
+		Cells: []Cell{
+			{CPUNode: 0, MemoryNode: 0, BandwidthMBps: 10000, LatencyNs: 90},
+			{CPUNode: 0, MemoryNode: 1, BandwidthMBps: 6000, LatencyNs: 140},
+			{CPUNode: 1, MemoryNode: 0, BandwidthMBps: 6200, LatencyNs: 142},
+			{CPUNode: 1, MemoryNode: 1, BandwidthMBps: 10200, LatencyNs: 92},
+		},
+	}
+	text := matrix.Format("en")
+	if !strings.Contains(text, "node1       6200.00   10200.00\n") || !strings.Contains(text, "NUMA Remote Latency: 141.00 ns") {
+		t.Fatalf("Format = %q", text)
+	}
+}
@@ -3,6 +3,16 @@ package params
 import (
 	"flag"
 	"fmt"
+	"os"
+	"time"
+
+	"github.com/oneclickvirt/ecs/internal/dnsbench"
+	"github.com/oneclickvirt/ecs/internal/fioprofile"
+	"github.com/oneclickvirt/ecs/internal/httpspeed"
+	"github.com/oneclickvirt/ecs/internal/iperf"
+	"github.com/oneclickvirt/ecs/internal/natcheck"
+	"github.com/oneclickvirt/ecs/internal/pingstats"
+	"github.com/oneclickvirt/ecs/internal/pmtu"
 )
 
 // Config holds all configuration parameters
@@ -17,13 +27,26 @@ type Config struct {
 	Language             string
 	CpuTestMethod        string
 	CpuTestThreadMode    string
+	CpuDuration          time.Duration
 	MemoryTestMethod     string
 	DiskTestMethod       string
 	DiskTestPath         string
 	DiskMultiCheck       bool
+	FioProfile           string
+	DiskLatency          bool
 	Nt3CheckType         string
 	Nt3Location          string
 	SpNum                int
+	SpeedServers         string
+	SpeedServer          string
+	SpeedURL             string
+	SpeedStreams         int
+	IperfTarget          string
+	IperfTime            time.Duration
+	IperfParallel        int
+	IperfUDP             bool
+	IperfBitrate         string
+	Repeat               int
 	Width                int
 	BasicStatus          bool
 	CpuTestStatus        bool
@@ -36,11 +59,23 @@ type Config struct {
 	Nt3Status            bool
 	SpeedTestStatus      bool
 	PingTestStatus       bool
+	PingCount            int
+	PingInterval         time.Duration
+	PingTargets          string
 	TgdcTestStatus       bool
 	WebTestStatus        bool
+	DNSTestStatus        bool
+	DNSServers           string
+	MTUTestStatus        bool
+	MTUTargets           string
+	NATTestStatus        bool
+	STUNServers          string
+	IPv6TestStatus       bool
 	AutoChangeDiskMethod bool
 	FilePath             string
 	EnableUpload         bool
+	ReportTo             string
+	ReportToken          string
 	OnlyIpInfoCheck      bool
 	Help                 bool
 	Finish               bool
@@ -59,6 +94,11 @@ func NewConfig(version string) *Config {
 		MemoryTestMethod:     "stream",
 		DiskTestMethod:       "fio",
 		SpNum:                2,
+		SpeedStreams:         4,
+		IperfTime:            10 * time.Second,
+		IperfParallel:        4,
+		IperfBitrate:         "100M",
+		Repeat:               1,
 		Width:                82,
 		BasicStatus:          true,
 		CpuTestStatus:        true,
@@ -70,6 +110,8 @@ func NewConfig(version string) *Config {
 		BacktraceStatus:      true,
 		Nt3Status:            true,
 		SpeedTestStatus:      true,
+		PingCount:            10,
+		PingInterval:         200 * time.Millisecond,
 		Nt3Location:          "GZ",
 		Nt3CheckType:         "ipv4",
 		AutoChangeDiskMethod: true,
@@ -81,7 +123,7 @@ func NewConfig(version string) *Config {
 }
 
 // ParseFlags parses command line flags
-func (c *Config) ParseFlags(args []string) {
+func (c *Config) ParseFlags(args []string) error {
 	c.GoecsFlag.BoolVar(&c.Help, "h", false, "Show help information")
 	c.GoecsFlag.BoolVar(&c.Help, "help", false, "Show help information")
 	c.GoecsFlag.BoolVar(&c.ShowVersion, "v", false, "Display version information")
@@ -101,22 +143,48 @@ func (c *Config) ParseFlags(args []string) {
 	c.GoecsFlag.BoolVar(&c.PingTestStatus, "ping", false, "Enable/Disable ping test")
 	c.GoecsFlag.BoolVar(&c.TgdcTestStatus, "tgdc", false, "Enable/Disable Telegram DC test")
 	c.GoecsFlag.BoolVar(&c.WebTestStatus, "web", false, "Enable/Disable popular websites test")
-	c.GoecsFlag.StringVar(&c.CpuTestMethod, "cpum", "sysbench", "Set CPU test method (supported: sysbench, geekbench, winsat)")
-	c.GoecsFlag.StringVar(&c.CpuTestThreadMode, "cput", "multi", "Set CPU test thread mode (supported: single, multi)")
-	c.GoecsFlag.StringVar(&c.MemoryTestMethod, "memorym", "stream", "Set memory test method (supported: stream, sysbench, dd, winsat, auto)")
+	c.GoecsFlag.BoolVar(&c.DNSTestStatus, "dns", false, "Enable/Disable DNS resolver benchmark and hijacking/pollution check")
+	c.GoecsFlag.BoolVar(&c.MTUTestStatus, "mtu", false, "Enable/Disable path MTU discovery in the network test, warning when it is below the interface MTU")
+	c.GoecsFlag.StringVar(&c.MTUTargets, "mtu-targets", "", "Discover the path MTU to these hosts instead of the built-in IPv4/IPv6 targets, e.g., -mtu-targets 1.1.1.1,example.com:8443=edge")
+	c.GoecsFlag.BoolVar(&c.NATTestStatus, "nat", false, "Enable/Disable NAT mapping/filtering, public IP and UPnP/NAT-PMP detection for IPv4 and IPv6")
+	c.GoecsFlag.BoolVar(&c.IPv6TestStatus, "ipv6", false, "Enable/Disable IPv6 address, /64 routing and IPv4 vs IPv6 latency checks")
+	c.GoecsFlag.StringVar(&c.STUNServers, "stun-servers", "", "Use these STUN servers for the -nat test instead of the built-in list, e.g., -stun-servers stun.example.com,10.0.0.2:3478")
+	c.GoecsFlag.StringVar(&c.DNSServers, "dns-servers", "", "Benchmark these resolvers instead of the built-in public list, e.g., -dns-servers 1.1.1.1,10.0.0.53:5353=office")
+	c.GoecsFlag.IntVar(&c.PingCount, "ping-count", 10, "Set the number of probes sent to each target of the ping tests")
+	c.GoecsFlag.DurationVar(&c.PingInterval, "ping-interval", 200*time.Millisecond, "Set the interval between probes of the ping tests")
+	c.GoecsFlag.StringVar(&c.PingTargets, "ping-targets", "", "Add the targets listed in a file to the ping test, one host (ICMP), host:port (TCP connect) or http(s) URL (time to first byte) per line followed by an optional label")
+	c.GoecsFlag.StringVar(&c.CpuTestMethod, "cpum", "sysbench", "Set CPU test method (supported: sysbench, geekbench, winsat, builtin)")
+	c.GoecsFlag.StringVar(&c.CpuTestThreadMode, "cput", "multi", "Set CPU test thread mode (supported: single, multi, scaling)")
+	c.GoecsFlag.DurationVar(&c.CpuDuration, "cpu-duration", 0, "Run an extra sustained CPU test for this long to detect throttling, e.g., -cpu-duration 5m")
+	c.GoecsFlag.StringVar(&c.MemoryTestMethod, "memorym", "stream", "Set memory test method (supported: stream, sysbench, dd, winsat, auto, latency)")
 	c.GoecsFlag.StringVar(&c.DiskTestMethod, "diskm", "fio", "Set disk test method (supported: fio, dd, winsat)")
-	c.GoecsFlag.StringVar(&c.DiskTestPath, "diskp", "", "Set disk test path, e.g., -diskp /root")
+	c.GoecsFlag.StringVar(&c.DiskTestPath, "diskp", "", "Set disk test path, separate several paths with commas to compare them, e.g., -diskp /root,/data")
 	c.GoecsFlag.BoolVar(&c.DiskMultiCheck, "diskmc", false, "Enable/Disable multiple disk checks, e.g., -diskmc=false")
+	c.GoecsFlag.BoolVar(&c.DiskLatency, "disk-latency", false, "Add a 4k random read/write latency percentile and fdatasync test to the disk test")
+	c.GoecsFlag.StringVar(&c.FioProfile, "fio-profile", "", "Run a fio job file or built-in profile (database, sequential, mixed-70-30) as the disk test, e.g., -fio-profile database")
 	c.GoecsFlag.StringVar(&c.Nt3Location, "nt3loc", "GZ", "Specify NT3 test location (supported: GZ, SH, BJ, CD, ALL for Guangzhou, Shanghai, Beijing, Chengdu and all)")
 	c.GoecsFlag.StringVar(&c.Nt3CheckType, "nt3t", "ipv4", "Set NT3 test type (supported: both, ipv4, ipv6)")
 	c.GoecsFlag.IntVar(&c.SpNum, "spnum", 2, "Set the number of servers per operator for speed test")
+	c.GoecsFlag.StringVar(&c.SpeedServers, "speed-servers", "", "Run the speed test against the servers listed in a file, one speedtest.net server ID or URL per line followed by an optional label")
+	c.GoecsFlag.StringVar(&c.SpeedServer, "speed-server", "", "Run the speed test against these speedtest.net server IDs or URLs, e.g., -speed-server 3633,5145=Beijing")
+	c.GoecsFlag.StringVar(&c.SpeedURL, "speed-url", "", "Run an HTTP throughput test against these URLs, GET for download and POST for upload, e.g., -speed-url http://10.0.0.2/1G.bin=nginx")
+	c.GoecsFlag.IntVar(&c.SpeedStreams, "speed-streams", 4, "Set the number of parallel streams of the -speed-url test")
+	c.GoecsFlag.StringVar(&c.IperfTarget, "iperf", "", "Run a TCP throughput test against an iperf3 or 'goecs iperf-server' server, e.g., -iperf 10.0.0.2:5201")
+	c.GoecsFlag.DurationVar(&c.IperfTime, "iperf-time", 10*time.Second, "Set the duration of each -iperf direction")
+	c.GoecsFlag.IntVar(&c.IperfParallel, "iperf-parallel", 4, "Set the number of parallel streams of the -iperf test")
+	c.GoecsFlag.BoolVar(&c.IperfUDP, "iperf-udp", false, "Add a UDP test with jitter and packet loss to the -iperf test")
+	c.GoecsFlag.StringVar(&c.IperfBitrate, "iperf-bitrate", "100M", "Set the target bitrate of the -iperf-udp test, e.g., -iperf-bitrate 500M")
+	c.GoecsFlag.IntVar(&c.Repeat, "repeat", 1, "Run CPU, memory and disk tests N times and report min/median/mean/max/stddev/CV, e.g., -repeat 5")
 	c.GoecsFlag.BoolVar(&c.EnableLogger, "log", false, "Enable/Disable logging in the current path")
 	c.GoecsFlag.BoolVar(&c.EnableUpload, "upload", true, "Enable/Disable upload the result")
-	c.GoecsFlag.Parse(args)
+	c.GoecsFlag.StringVar(&c.ReportTo, "report-to", "", "Send the structured result to a goecs collector instead of the public paste service, e.g., -report-to http://10.0.0.1:8090")
+	c.GoecsFlag.StringVar(&c.ReportToken, "report-token", "", "Set the shared token of the collector used by -report-to")
+	err := c.GoecsFlag.Parse(args)
 
 	c.GoecsFlag.Visit(func(f *flag.Flag) {
 		c.UserSetFlags[f.Name] = true
 	})
+	return err
 }
 
 // HandleHelpAndVersion handles help and version flags
@@ -133,6 +201,17 @@ func (c *Config) HandleHelpAndVersion(programName string) bool {
 	return false
 }
 
+// HandleLanguageSpecificSettings disables tests that are unavailable for the language or upload settings
+func (c *Config) HandleLanguageSpecificSettings() {
+	if c.Language == "en" {
+		c.BacktraceStatus = false
+		c.Nt3Status = false
+	}
+	if !c.EnableUpload {
+		c.SecurityTestStatus = false
+	}
+}
+
 // SaveUserSetParams saves user-set parameters
 func (c *Config) SaveUserSetParams() map[string]interface{} {
 	saved := make(map[string]interface{})
@@ -327,7 +406,7 @@ func (c *Config) RestoreUserSetParams(saved map[string]interface{}) {
 
 // ValidateParams validates parameter values
 func (c *Config) ValidateParams() {
-	validCpuMethods := map[string]bool{"sysbench": true, "geekbench": true, "winsat": true}
+	validCpuMethods := map[string]bool{"sysbench": true, "geekbench": true, "winsat": true, "builtin": true}
 	if !validCpuMethods[c.CpuTestMethod] {
 		if c.Language == "zh" {
 			fmt.Printf("警告: CPU测试方法 '%s' 无效，使用默认值 'sysbench'\n", c.CpuTestMethod)
@@ -337,7 +416,7 @@ func (c *Config) ValidateParams() {
 		c.CpuTestMethod = "sysbench"
 	}
 
-	validThreadModes := map[string]bool{"single": true, "multi": true}
+	validThreadModes := map[string]bool{"single": true, "multi": true, "scaling": true}
 	if !validThreadModes[c.CpuTestThreadMode] {
 		if c.Language == "zh" {
 			fmt.Printf("警告: CPU线程模式 '%s' 无效，使用默认值 'multi'\n", c.CpuTestThreadMode)
@@ -347,7 +426,7 @@ func (c *Config) ValidateParams() {
 		c.CpuTestThreadMode = "multi"
 	}
 
-	validMemoryMethods := map[string]bool{"stream": true, "sysbench": true, "dd": true, "winsat": true, "auto": true}
+	validMemoryMethods := map[string]bool{"stream": true, "sysbench": true, "dd": true, "winsat": true, "auto": true, "latency": true}
 	if !validMemoryMethods[c.MemoryTestMethod] {
 		if c.Language == "zh" {
 			fmt.Printf("警告: 内存测试方法 '%s' 无效，使用默认值 'stream'\n", c.MemoryTestMethod)
@@ -367,6 +446,152 @@ func (c *Config) ValidateParams() {
 		c.DiskTestMethod = "fio"
 	}
 
+	if c.FioProfile != "" && !fioprofile.Exists(c.FioProfile) {
+		if c.Language == "zh" {
+			fmt.Printf("警告: fio作业配置 '%s' 不存在，使用默认磁盘测试\n", c.FioProfile)
+		} else {
+			fmt.Printf("Warning: fio profile '%s' not found, using the default disk test\n", c.FioProfile)
+		}
+		c.FioProfile = ""
+	}
+
+	if c.SpeedServers != "" {
+		if _, err := os.Stat(c.SpeedServers); err != nil {
+			if c.Language == "zh" {
+				fmt.Printf("警告: 测速节点列表文件 '%s' 无法读取，使用默认测速节点\n", c.SpeedServers)
+			} else {
+				fmt.Printf("Warning: speed test server list '%s' cannot be read, using the default servers\n", c.SpeedServers)
+			}
+			c.SpeedServers = ""
+		}
+	}
+
+	if c.SpeedURL != "" {
+		_, invalid := httpspeed.ParseList(c.SpeedURL)
+		for _, item := range invalid {
+			if c.Language == "zh" {
+				fmt.Printf("警告: 测速地址 '%s' 无效，仅支持 http 与 https\n", item)
+			} else {
+				fmt.Printf("Warning: Invalid speed test URL '%s', only http and https are supported\n", item)
+			}
+		}
+	}
+
+	if c.SpeedStreams < 1 {
+		if c.Language == "zh" {
+			fmt.Printf("警告: 测速并行连接数 '%d' 无效，使用默认值 4\n", c.SpeedStreams)
+		} else {
+			fmt.Printf("Warning: Invalid speed test stream count '%d', using default 4\n", c.SpeedStreams)
+		}
+		c.SpeedStreams = 4
+	}
+
+	if c.PingCount < 1 {
+		if c.Language == "zh" {
+			fmt.Printf("警告: PING探测次数 '%d' 无效，使用默认值 10\n", c.PingCount)
+		} else {
+			fmt.Printf("Warning: Invalid ping count '%d', using default 10\n", c.PingCount)
+		}
+		c.PingCount = 10
+	}
+
+	if c.PingInterval < 10*time.Millisecond {
+		if c.Language == "zh" {
+			fmt.Printf("警告: PING探测间隔 '%s' 过短，使用默认值 200ms\n", c.PingInterval)
+		} else {
+			fmt.Printf("Warning: Ping interval '%s' is too short, using default 200ms\n", c.PingInterval)
+		}
+		c.PingInterval = 200 * time.Millisecond
+	}
+
+	if c.PingTargets != "" {
+		_, invalid, err := pingstats.LoadTargets(c.PingTargets)
+		if err != nil {
+			if c.Language == "zh" {
+				fmt.Printf("警告: PING目标列表文件 '%s' 无法读取，已忽略\n", c.PingTargets)
+			} else {
+				fmt.Printf("Warning: ping target list '%s' cannot be read, ignored\n", c.PingTargets)
+			}
+			c.PingTargets = ""
+		}
+		for _, line := range invalid {
+			if c.Language == "zh" {
+				fmt.Printf("警告: PING目标 '%s' 无效，已跳过\n", line)
+			} else {
+				fmt.Printf("Warning: Invalid ping target '%s', skipped\n", line)
+			}
+		}
+	}
+
+	if c.DNSServers != "" {
+		resolvers, invalid := dnsbench.ParseList(c.DNSServers)
+		for _, item := range invalid {
+			if c.Language == "zh" {
+				fmt.Printf("警告: DNS服务器 '%s' 无效，已跳过\n", item)
+			} else {
+				fmt.Printf("Warning: Invalid DNS server '%s', skipped\n", item)
+			}
+		}
+		if len(resolvers) == 0 {
+			c.DNSServers = ""
+		}
+	}
+
+	if c.MTUTargets != "" {
+		targets, invalid := pmtu.ParseList(c.MTUTargets)
+		for _, item := range invalid {
+			if c.Language == "zh" {
+				fmt.Printf("警告: MTU检测目标 '%s' 无效，已跳过\n", item)
+			} else {
+				fmt.Printf("Warning: Invalid MTU target '%s', skipped\n", item)
+			}
+		}
+		if len(targets) == 0 {
+			c.MTUTargets = ""
+		}
+	}
+
+	if c.STUNServers != "" {
+		servers, invalid := natcheck.ParseServers(c.STUNServers)
+		for _, item := range invalid {
+			if c.Language == "zh" {
+				fmt.Printf("警告: STUN服务器 '%s' 无效，已跳过\n", item)
+			} else {
+				fmt.Printf("Warning: Invalid STUN server '%s', skipped\n", item)
+			}
+		}
+		if len(servers) == 0 {
+			c.STUNServers = ""
+		}
+	}
+
+	if c.IperfParallel < 1 {
+		if c.Language == "zh" {
+			fmt.Printf("警告: iperf并行连接数 '%d' 无效，使用默认值 4\n", c.IperfParallel)
+		} else {
+			fmt.Printf("Warning: Invalid iperf stream count '%d', using default 4\n", c.IperfParallel)
+		}
+		c.IperfParallel = 4
+	}
+
+	if c.IperfTime < time.Second {
+		if c.Language == "zh" {
+			fmt.Printf("警告: iperf测试时长 '%s' 无效，使用默认值 10s\n", c.IperfTime)
+		} else {
+			fmt.Printf("Warning: Invalid iperf duration '%s', using default 10s\n", c.IperfTime)
+		}
+		c.IperfTime = 10 * time.Second
+	}
+
+	if _, err := iperf.ParseBitrate(c.IperfBitrate); err != nil {
+		if c.Language == "zh" {
+			fmt.Printf("警告: iperf目标速率 '%s' 无效，使用默认值 100M\n", c.IperfBitrate)
+		} else {
+			fmt.Printf("Warning: Invalid iperf bitrate '%s', using default 100M\n", c.IperfBitrate)
+		}
+		c.IperfBitrate = "100M"
+	}
+
 	validNt3Locations := map[string]bool{"GZ": true, "SH": true, "BJ": true, "CD": true, "ALL": true}
 	if !validNt3Locations[c.Nt3Location] {
 		if c.Language == "zh" {
@@ -396,6 +621,15 @@ func (c *Config) ValidateParams() {
 		c.SpNum = 2
 	}
 
+	if c.Repeat < 1 {
+		if c.Language == "zh" {
+			fmt.Printf("警告: 重复次数 '%d' 无效，使用默认值 1\n", c.Repeat)
+		} else {
+			fmt.Printf("Warning: Invalid repeat count '%d', using default 1\n", c.Repeat)
+		}
+		c.Repeat = 1
+	}
+
 	validLanguages := map[string]bool{"zh": true, "en": true}
 	if !validLanguages[c.Language] {
 		fmt.Printf("Warning: Invalid language '%s', using default 'zh'\n", c.Language)
@@ -0,0 +1,87 @@
+package pingstats
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ParseTarget parses one entry of a -ping-targets file:
+// http(s)://... for time to first byte, tcp://host:port or host:port for TCP connect,
+// icmp://host or a bare host for ICMP with TCP port 443 as the fallback
+func ParseTarget(text string) (Target, error) {
+	target := Target{Group: "custom", Name: text}
+	scheme, rest, hasScheme := strings.Cut(text, "://")
+	if !hasScheme {
+		scheme, rest = "", text
+	}
+	switch strings.ToLower(scheme) {
+	case "http", "https":
+		parsed, err := url.Parse(text)
+		if err != nil || parsed.Hostname() == "" {
+			return target, fmt.Errorf("invalid URL %q", text)
+		}
+		target.Method, target.URL, target.Host = MethodHTTP, text, parsed.Hostname()
+		return target, nil
+	case "icmp":
+		target.Method, target.Host = MethodAuto, strings.Trim(rest, "[]")
+	case "tcp", "":
+		host, port, err := net.SplitHostPort(rest)
+		if err != nil {
+			if scheme == "tcp" {
+				return target, fmt.Errorf("missing port in %q", text)
+			}
+			// 不带端口的主机名或 IPv6 地址
+			target.Method, target.Host = MethodAuto, strings.Trim(rest, "[]")
+			break
+		}
+		number, err := strconv.Atoi(port)
+		if err != nil || number < 1 || number > 65535 {
+			return target, fmt.Errorf("invalid port in %q", text)
+		}
+		target.Method, target.Host, target.Port = MethodTCP, host, number
+	default:
+		return target, fmt.Errorf("unsupported scheme in %q", text)
+	}
+	if target.Host == "" || strings.ContainsAny(target.Host, "/?#") {
+		return target, fmt.Errorf("invalid host in %q", text)
+	}
+	return target, nil
+}
+
+// ParseTargets parses a target list, one target per line followed by an optional label,
+// blank lines and lines starting with # are ignored and invalid lines are returned separately
+func ParseTargets(text string) (targets []Target, invalid []string) {
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		target, err := ParseTarget(fields[0])
+		if err != nil {
+			invalid = append(invalid, line)
+			continue
+		}
+		if len(fields) > 1 {
+			target.Name = strings.Join(fields[1:], " ")
+		}
+		targets = append(targets, target)
+	}
+	return targets, invalid
+}
+
+// LoadTargets reads the -ping-targets file
+func LoadTargets(file string) ([]Target, []string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	targets, invalid := ParseTargets(string(data))
+	return targets, invalid, nil
+}
@@ -0,0 +1,282 @@
+// Package pingstats measures the latency distribution, jitter and loss of ping targets,
+// using ICMP where the host allows it and TCP connect otherwise
+package pingstats
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+	probing "github.com/prometheus-community/pro-bing"
+)
+
+// Probe methods of a target
+const (
+	MethodAuto = "" // ICMP，无法发送 ICMP 时改用 TCP 连接
+	MethodICMP = "icmp"
+	MethodTCP  = "tcp"
+	MethodHTTP = "http" // 收到响应首字节的时间，每次使用新连接
+)
+
+// Options controls the probes sent to every target
+type Options struct {
+	Count    int           // 每个目标的探测次数
+	Interval time.Duration // 两次探测的间隔
+	Timeout  time.Duration // 单次探测的超时
+}
+
+// DefaultOptions returns the options of the ping section
+func DefaultOptions() Options {
+	return Options{Count: 10, Interval: 200 * time.Millisecond, Timeout: 2 * time.Second}
+}
+
+// Target is one host to probe
+type Target struct {
+	Group  string // 分组名称，如运营商，输出时同组相邻
+	Name   string
+	Host   string // IP 或域名
+	Port   int    // TCP 连接的端口，0 使用 443
+	URL    string // HTTP 探测的地址
+	Method string
+}
+
+// Stats is the latency distribution of one target in milliseconds
+type Stats struct {
+	Group    string  `json:"group,omitempty"`
+	Name     string  `json:"name"`
+	Host     string  `json:"host"`
+	Method   string  `json:"method"`
+	Sent     int     `json:"sent"`
+	Received int     `json:"received"`
+	Loss     float64 `json:"loss_percent"`
+	Min      float64 `json:"min_ms"`
+	Avg      float64 `json:"avg_ms"`
+	P50      float64 `json:"p50_ms"`
+	P95      float64 `json:"p95_ms"`
+	Max      float64 `json:"max_ms"`
+	// Jitter is the mean absolute deviation of the samples from the average
+	Jitter float64 `json:"jitter_ms"`
+}
+
+// Summarize computes the statistics of the round-trip times of sent probes
+func Summarize(target Target, method string, sent int, rtts []time.Duration) *Stats {
+	stats := &Stats{Group: target.Group, Name: target.Name, Host: target.Host, Method: method, Sent: sent, Received: len(rtts)}
+	if sent > 0 {
+		stats.Loss = float64(sent-len(rtts)) * 100 / float64(sent)
+	}
+	if len(rtts) == 0 {
+		return stats
+	}
+	samples := make([]float64, len(rtts))
+	var sum float64
+	for i, rtt := range rtts {
+		samples[i] = float64(rtt.Microseconds()) / 1000
+		sum += samples[i]
+	}
+	sort.Float64s(samples)
+	pick := func(p float64) float64 {
+		index := int(math.Ceil(p*float64(len(samples))/100-1e-9)) - 1
+		return samples[max(index, 0)]
+	}
+	stats.Min, stats.Max = samples[0], samples[len(samples)-1]
+	stats.Avg = sum / float64(len(samples))
+	stats.P50, stats.P95 = pick(50), pick(95)
+	var deviation float64
+	for _, sample := range samples {
+		deviation += math.Abs(sample - stats.Avg)
+	}
+	stats.Jitter = deviation / float64(len(samples))
+	return stats
+}
+
+// rawICMP reports whether raw ICMP sockets can be opened, the same check the route tracing test does
+var rawICMP = sync.OnceValue(func() bool {
+	conn, err := net.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+})
+
+// probeICMP pings host, an error means no ICMP socket could be used at all
+func probeICMP(host string, opts Options) (int, []time.Duration, error) {
+	pinger, err := probing.NewPinger(host)
+	if err != nil {
+		return 0, nil, err
+	}
+	pinger.SetLogger(probing.NoopLogger{})
+	// 没有原始套接字权限时使用非特权的 UDP ICMP 套接字
+	pinger.SetPrivileged(rawICMP())
+	pinger.Count = opts.Count
+	pinger.Interval = opts.Interval
+	pinger.Timeout = time.Duration(opts.Count)*opts.Interval + opts.Timeout
+	if err := pinger.Run(); err != nil {
+		return 0, nil, err
+	}
+	stats := pinger.Statistics()
+	return stats.PacketsSent, stats.Rtts, nil
+}
+
+// probeTCP times opts.Count TCP handshakes to host:port
+func probeTCP(host string, port int, opts Options) (int, []time.Duration) {
+	if port == 0 {
+		port = 443
+	}
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	var rtts []time.Duration
+	for i := 0; i < opts.Count; i++ {
+		if i > 0 {
+			time.Sleep(opts.Interval)
+		}
+		start := time.Now()
+		conn, err := net.DialTimeout("tcp", address, opts.Timeout)
+		if err != nil {
+			continue
+		}
+		rtts = append(rtts, time.Since(start))
+		conn.Close()
+	}
+	return opts.Count, rtts
+}
+
+// probeHTTP times opts.Count GET requests of target up to the first response byte, redirects are not followed
+// and 5xx answers count as lost
+func probeHTTP(target string, opts Options) (int, []time.Duration) {
+	client := &http.Client{
+		Timeout:   opts.Timeout,
+		Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, DisableKeepAlives: true},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	var rtts []time.Duration
+	for i := 0; i < opts.Count; i++ {
+		if i > 0 {
+			time.Sleep(opts.Interval)
+		}
+		var firstByte time.Duration
+		start := time.Now()
+		trace := &httptrace.ClientTrace{GotFirstResponseByte: func() { firstByte = time.Since(start) }}
+		request, err := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), http.MethodGet, target, nil)
+		if err != nil {
+			break
+		}
+		response, err := client.Do(request)
+		if err != nil {
+			continue
+		}
+		response.Body.Close()
+		if response.StatusCode < 500 && firstByte > 0 {
+			rtts = append(rtts, firstByte)
+		}
+	}
+	return opts.Count, rtts
+}
+
+// Measure probes one target with its method
+func Measure(target Target, opts Options) *Stats {
+	if target.Method == MethodHTTP {
+		sent, rtts := probeHTTP(target.URL, opts)
+		return Summarize(target, MethodHTTP, sent, rtts)
+	}
+	if target.Method != MethodTCP {
+		sent, rtts, err := probeICMP(target.Host, opts)
+		if err == nil || target.Method == MethodICMP {
+			return Summarize(target, MethodICMP, max(sent, opts.Count), rtts)
+		}
+	}
+	sent, rtts := probeTCP(target.Host, target.Port, opts)
+	return Summarize(target, MethodTCP, sent, rtts)
+}
+
+// Run measures targets with at most concurrency probes in flight, keeping their order
+func Run(targets []Target, opts Options, concurrency int) []*Stats {
+	results := make([]*Stats, len(targets))
+	sem := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, target Target) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			results[i] = Measure(target, opts)
+		}(i, target)
+	}
+	wg.Wait()
+	return results
+}
+
+// Sort orders the targets of each group by average latency with unreachable targets last,
+// groups keep the order in which they first appear
+func Sort(stats []*Stats) {
+	groups := make(map[string]int)
+	for _, s := range stats {
+		if _, ok := groups[s.Group]; !ok {
+			groups[s.Group] = len(groups)
+		}
+	}
+	sort.SliceStable(stats, func(i, j int) bool {
+		a, b := stats[i], stats[j]
+		if groups[a.Group] != groups[b.Group] {
+			return groups[a.Group] < groups[b.Group]
+		}
+		if (a.Received == 0) != (b.Received == 0) {
+			return b.Received == 0
+		}
+		return a.Avg < b.Avg
+	})
+}
+
+func cell(text string, width int) string {
+	return runewidth.FillLeft(text, width)
+}
+
+// Format renders one row per target, a blank line separates groups
+func Format(stats []*Stats, language string) string {
+	var builder strings.Builder
+	columns := []string{"Tx/Rx", "Loss", "Min", "Avg", "P50", "P95", "Max", "Jitter", "Via"}
+	name := "Target (ms)"
+	if language == "zh" {
+		columns = []string{"发/收", "丢包", "最小", "平均", "P50", "P95", "最大", "抖动", "方式"}
+		name = "目标 (ms)"
+	}
+	widths := []int{8, 7, 7, 7, 7, 7, 7, 7, 5}
+	builder.WriteString(runewidth.FillRight(name, 18))
+	for i, column := range columns {
+		builder.WriteString(cell(column, widths[i]))
+	}
+	builder.WriteString("\n")
+	for i, s := range stats {
+		if i > 0 && s.Group != stats[i-1].Group {
+			builder.WriteString("\n")
+		}
+		values := []string{fmt.Sprintf("%d/%d", s.Sent, s.Received), fmt.Sprintf("%.0f%%", s.Loss)}
+		for _, value := range []float64{s.Min, s.Avg, s.P50, s.P95, s.Max, s.Jitter} {
+			if s.Received == 0 {
+				values = append(values, "-")
+			} else {
+				values = append(values, fmt.Sprintf("%.1f", value))
+			}
+		}
+		values = append(values, s.Method)
+		builder.WriteString(runewidth.FillRight(runewidth.Truncate(s.Name, 17, ""), 18))
+		for j, value := range values {
+			builder.WriteString(cell(value, widths[j]))
+		}
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
@@ -0,0 +1,137 @@
+package pingstats
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oneclickvirt/pingtest/model"
+)
+
+func TestSummarize(t *testing.T) {
+	var rtts []time.Duration
+	for _, ms := range []int{10, 20, 30, 40} {
+		rtts = append(rtts, time.Duration(ms)*time.Millisecond)
+	}
+	stats := Summarize(Target{Name: "a"}, MethodICMP, 5, rtts)
+	if stats.Received != 4 || stats.Loss != 20 {
+		t.Fatalf("received %d, loss %v", stats.Received, stats.Loss)
+	}
+	if stats.Min != 10 || stats.Max != 40 || stats.Avg != 25 || stats.P50 != 20 || stats.P95 != 40 || stats.Jitter != 10 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	if lost := Summarize(Target{}, MethodTCP, 3, nil); lost.Loss != 100 || lost.Avg != 0 {
+		t.Fatalf("lost = %+v", lost)
+	}
+}
+
+func TestMeasureTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	port := listener.Addr().(*net.TCPAddr).Port
+	opts := Options{Count: 3, Interval: 10 * time.Millisecond, Timeout: time.Second}
+	stats := Run([]Target{
+		{Group: "local", Name: "open", Host: "127.0.0.1", Port: port, Method: MethodTCP},
+		{Group: "local", Name: "closed", Host: "127.0.0.1", Port: 1, Method: MethodTCP},
+	}, opts, 2)
+	if stats[0].Received != 3 || stats[0].Method != MethodTCP || stats[1].Loss != 100 {
+		t.Fatalf("stats = %+v, %+v", stats[0], stats[1])
+	}
+	Sort(stats)
+	text := Format(stats, "en")
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "open ") || !strings.Contains(lines[2], "3/0") {
+		t.Fatalf("Format = %q", text)
+	}
+}
+
+func TestSort(t *testing.T) {
+	stats := []*Stats{
+		{Group: "b", Name: "b1", Received: 1, Avg: 5},
+		{Group: "a", Name: "a1"},
+		{Group: "a", Name: "a2", Received: 1, Avg: 9},
+		{Group: "b", Name: "b2", Received: 1, Avg: 1},
+		{Group: "a", Name: "a3", Received: 1, Avg: 3},
+	}
+	Sort(stats)
+	var names []string
+	for _, s := range stats {
+		names = append(names, s.Name)
+	}
+	if got := strings.Join(names, ","); got != "b2,b1,a3,a2,a1" {
+		t.Fatalf("Sort = %s", got)
+	}
+}
+
+func TestChinaTargets(t *testing.T) {
+	targets := chinaTargets([]model.IcmpTarget{
+		{Province: "北京市", IspCode: "cu", IPVersion: "v4", IPs: "1.1.1.1,1.1.1.2"},
+		{Province: "北京", IspCode: "cu", IPVersion: "v4", IPs: "1.1.1.3"},
+		{Province: "广西壮族自治区", IspCode: "ct", IPVersion: "v4", IPs: "2.2.2.2"},
+		{Province: "上海市", IspCode: "cm", IPVersion: "v6", IPs: "2001:db8::1"},
+	})
+	if len(targets) != 2 || targets[0].Name != "电信广西" || targets[1].Name != "联通北京" || targets[1].Host != "1.1.1.1" {
+		t.Fatalf("targets = %+v", targets)
+	}
+}
+
+func TestParseTargets(t *testing.T) {
+	targets, invalid := ParseTargets(`# customer regions
+1.1.1.1 Cloudflare DNS
+tcp://example.com:8443
+[2001:db8::1]:443 v6 edge
+https://cdn.example.com/ping?x=1 CDN PoP
+icmp://2001:db8::2
+tcp://example.com
+ftp://example.com
+host:99999
+`)
+	if len(invalid) != 3 {
+		t.Fatalf("invalid = %q", invalid)
+	}
+	want := []Target{
+		{Group: "custom", Name: "Cloudflare DNS", Host: "1.1.1.1", Method: MethodAuto},
+		{Group: "custom", Name: "tcp://example.com:8443", Host: "example.com", Port: 8443, Method: MethodTCP},
+		{Group: "custom", Name: "v6 edge", Host: "2001:db8::1", Port: 443, Method: MethodTCP},
+		{Group: "custom", Name: "CDN PoP", Host: "cdn.example.com", URL: "https://cdn.example.com/ping?x=1", Method: MethodHTTP},
+		{Group: "custom", Name: "icmp://2001:db8::2", Host: "2001:db8::2", Method: MethodAuto},
+	}
+	if len(targets) != len(want) {
+		t.Fatalf("targets = %+v", targets)
+	}
+	for i := range want {
+		if targets[i] != want[i] {
+			t.Errorf("target %d = %+v, want %+v", i, targets[i], want[i])
+		}
+	}
+}
+
+func TestMeasureHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+	opts := Options{Count: 3, Interval: 10 * time.Millisecond, Timeout: time.Second}
+	ok := Measure(Target{URL: server.URL, Method: MethodHTTP}, opts)
+	failed := Measure(Target{URL: server.URL + "/fail", Method: MethodHTTP}, opts)
+	if ok.Received != 3 || ok.Method != MethodHTTP || failed.Received != 0 || failed.Loss != 100 {
+		t.Fatalf("ok = %+v, failed = %+v", ok, failed)
+	}
+}
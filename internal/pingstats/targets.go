@@ -0,0 +1,103 @@
+package pingstats
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/oneclickvirt/pingtest/model"
+)
+
+// TelegramTargets returns the Telegram data centers, TCP falls back to port 443 which every DC accepts
+func TelegramTargets() []Target {
+	targets := make([]Target, 0, len(model.TelegramDataCenters))
+	for _, dc := range model.TelegramDataCenters {
+		targets = append(targets, Target{Group: "telegram", Name: dc.Name + " " + dc.Location, Host: dc.IP, Port: 443})
+	}
+	return targets
+}
+
+// WebsiteTargets returns the popular websites, probed with TCP connect because CDN edges often drop ICMP
+func WebsiteTargets() []Target {
+	targets := make([]Target, 0, len(model.PopularWebsites))
+	for _, site := range model.PopularWebsites {
+		parsed, err := url.Parse(site.URL)
+		if err != nil || parsed.Hostname() == "" {
+			continue
+		}
+		port := 443
+		if parsed.Scheme == "http" {
+			port = 80
+		}
+		targets = append(targets, Target{Group: "website", Name: site.Name, Host: parsed.Hostname(), Port: port, Method: MethodTCP})
+	}
+	return targets
+}
+
+// chinaISPs maps the isp_code of the ICMP target list to the operator name, in output order
+var chinaISPs = []struct{ code, name string }{{"ct", "电信"}, {"cu", "联通"}, {"cm", "移动"}}
+
+// fetchIcmpTargets downloads the provincial ICMP target list through the first CDN that serves it
+func fetchIcmpTargets() []model.IcmpTarget {
+	client := &http.Client{Timeout: 10 * time.Second}
+	for _, cdn := range model.CdnList {
+		response, err := client.Get(cdn + model.IcmpTargets)
+		if err != nil {
+			continue
+		}
+		data, err := io.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil || response.StatusCode != http.StatusOK {
+			continue
+		}
+		var targets []model.IcmpTarget
+		if json.Unmarshal(data, &targets) == nil && len(targets) > 0 {
+			return targets
+		}
+	}
+	return nil
+}
+
+// cleanProvince drops the administrative suffix so names read like "电信北京"
+func cleanProvince(province string) string {
+	for _, suffix := range []string{"维吾尔自治区", "回族自治区", "壮族自治区", "自治区", "省", "市"} {
+		if strings.HasSuffix(province, suffix) {
+			return strings.TrimSuffix(province, suffix)
+		}
+	}
+	return province
+}
+
+// ChinaTargets returns one IPv4 target per province for each of the three China operators,
+// empty when the target list cannot be downloaded
+func ChinaTargets() []Target {
+	return chinaTargets(fetchIcmpTargets())
+}
+
+func chinaTargets(list []model.IcmpTarget) []Target {
+	var targets []Target
+	for _, isp := range chinaISPs {
+		seen := make(map[string]bool)
+		var group []Target
+		for _, entry := range list {
+			if entry.IPVersion != "v4" || entry.IspCode != isp.code {
+				continue
+			}
+			province := cleanProvince(entry.Province)
+			ip := strings.TrimSpace(strings.Split(entry.IPs, ",")[0])
+			if seen[province] || net.ParseIP(ip) == nil {
+				continue
+			}
+			seen[province] = true
+			group = append(group, Target{Group: isp.name, Name: isp.name + province, Host: ip, Port: 80})
+		}
+		sort.Slice(group, func(i, j int) bool { return group[i].Name < group[j].Name })
+		targets = append(targets, group...)
+	}
+	return targets
+}
@@ -0,0 +1,255 @@
+// Package pmtu discovers the path MTU to a set of targets over IPv4 and IPv6 and compares it with the MTU
+// of the outgoing interface, a smaller path MTU is typical of tunnels and stalls large packets
+package pmtu
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/oneclickvirt/ecs/internal/flaglist"
+)
+
+// Discovery methods
+const (
+	MethodICMP = "icmp"    // 设置 DF 位的 ICMP Echo 二分探测
+	MethodTCP  = "tcp-mss" // 由 TCP 连接协商的 MSS 推算
+)
+
+// probeTimeout bounds the wait for the reply of one probe and one TCP connect
+const probeTimeout = 800 * time.Millisecond
+
+// errNoReply means the target does not answer ICMP echo requests
+var errNoReply = errors.New("no ICMP echo reply")
+
+// DefaultTargets are probed when -mtu-targets is not given
+var DefaultTargets = []Target{
+	{Name: "Cloudflare", Host: "1.1.1.1", Port: 443},
+	{Name: "Google", Host: "8.8.8.8", Port: 443},
+	{Name: "Cloudflare", Host: "2606:4700:4700::1111", Port: 443},
+	{Name: "Google", Host: "2001:4860:4860::8888", Port: 443},
+}
+
+// Target is one host to probe, Port is used by the TCP fallback
+type Target struct {
+	Name string
+	Host string
+	Port int
+}
+
+// Result is the path MTU to one address of a target
+type Result struct {
+	Name         string `json:"name"`
+	Address      string `json:"address"`
+	Family       string `json:"family"` // ipv4 或 ipv6
+	Interface    string `json:"interface,omitempty"`
+	InterfaceMTU int    `json:"interface_mtu,omitempty"`
+	PathMTU      int    `json:"path_mtu,omitempty"`
+	Method       string `json:"method,omitempty"`
+	Error        string `json:"error,omitempty"`
+
+	noRoute bool // 本机没有该协议栈的路由
+}
+
+// Reduced reports whether the path MTU is below the interface MTU, capped at the largest IP packet for loopback.
+// The TCP fallback only sees the MSS the peer advertised, which says nothing about the path, so it never counts
+func (r *Result) Reduced() bool {
+	if r.Method == MethodTCP {
+		return false
+	}
+	return r.PathMTU > 0 && r.InterfaceMTU > 0 && r.PathMTU < min(r.InterfaceMTU, 65535)
+}
+
+// ParseList parses the -mtu-targets value, hosts with an optional TCP port separated by commas,
+// each optionally followed by "=label", invalid items are returned separately
+func ParseList(value string) (targets []Target, invalid []string) {
+	for _, item := range flaglist.Split(value) {
+		host, port := flaglist.HostPort(item.Value, "443")
+		number, err := strconv.Atoi(port)
+		if err != nil || number < 1 || number > 65535 || host == "" || strings.ContainsAny(host, "/?#[] ") {
+			invalid = append(invalid, item.Raw)
+			continue
+		}
+		target := Target{Name: item.Label, Host: host, Port: number}
+		if target.Name == "" {
+			target.Name = host
+		}
+		targets = append(targets, target)
+	}
+	return targets, invalid
+}
+
+// resolve returns the first IPv4 and the first IPv6 address of host
+func resolve(host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil, err
+	}
+	var v4, v6 net.IP
+	for _, ip := range ips {
+		if ip.To4() != nil && v4 == nil {
+			v4 = ip
+		} else if ip.To4() == nil && v6 == nil {
+			v6 = ip
+		}
+	}
+	var result []net.IP
+	for _, ip := range []net.IP{v4, v6} {
+		if ip != nil {
+			result = append(result, ip)
+		}
+	}
+	return result, nil
+}
+
+// outgoingInterface returns the interface the route to ip leaves through, connecting a UDP socket sends nothing
+func outgoingInterface(ip net.IP) (*net.Interface, error) {
+	conn, err := net.Dial("udp", net.JoinHostPort(ip.String(), "53"))
+	if err != nil {
+		return nil, err
+	}
+	local := conn.LocalAddr().(*net.UDPAddr).IP
+	conn.Close()
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	for i := range interfaces {
+		addrs, _ := interfaces[i].Addrs()
+		for _, addr := range addrs {
+			if network, ok := addr.(*net.IPNet); ok && network.IP.Equal(local) {
+				return &interfaces[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no interface has address %s", local)
+}
+
+// Discover measures the path MTU to ip, with ICMP probing where raw sockets are allowed and the target answers,
+// and from the TCP MSS of a connection to port otherwise
+func Discover(name string, ip net.IP, port int) *Result {
+	result := &Result{Name: name, Address: ip.String(), Family: "ipv4"}
+	if ip.To4() == nil {
+		result.Family = "ipv6"
+	}
+	iface, err := outgoingInterface(ip)
+	if err != nil {
+		result.Error, result.noRoute = err.Error(), true
+		return result
+	}
+	result.Interface, result.InterfaceMTU = iface.Name, iface.MTU
+	mtu, err := probeICMP(ip, iface.MTU)
+	result.Method = MethodICMP
+	if err != nil {
+		var tcpErr error
+		mtu, tcpErr = probeTCP(ip, port, iface.MTU)
+		result.Method = MethodTCP
+		if tcpErr != nil {
+			result.Method, result.Error = "", fmt.Sprintf("%v; %v", err, tcpErr)
+			return result
+		}
+	}
+	result.PathMTU = mtu
+	return result
+}
+
+// Run discovers the path MTU to every address of targets concurrently, keeping their order,
+// addresses of a family the host has no route for are left out
+func Run(targets []Target) []*Result {
+	type job struct {
+		target Target
+		ip     net.IP
+	}
+	var jobs []job
+	var results []*Result
+	for _, target := range targets {
+		ips, err := resolve(target.Host)
+		if err != nil || len(ips) == 0 {
+			results = append(results, &Result{Name: target.Name, Address: target.Host, Error: "resolve failed"})
+			continue
+		}
+		for _, ip := range ips {
+			jobs = append(jobs, job{target, ip})
+		}
+	}
+	measured := make([]*Result, len(jobs))
+	var wg sync.WaitGroup
+	for i, j := range jobs {
+		wg.Add(1)
+		go func(i int, j job) {
+			defer wg.Done()
+			measured[i] = Discover(j.target.Name, j.ip, j.target.Port)
+		}(i, j)
+	}
+	wg.Wait()
+	var routed []*Result
+	for _, result := range measured {
+		if !result.noRoute {
+			routed = append(routed, result)
+		}
+	}
+	return append(routed, results...)
+}
+
+// Format renders one row per address followed by a warning for every path MTU below the interface MTU
+func Format(results []*Result, language string) string {
+	var builder strings.Builder
+	zh := language == "zh"
+	headers := []string{"Target", "Address", "Interface", "If MTU", "Path MTU", "Via"}
+	if zh {
+		headers = []string{"目标", "地址", "网卡", "网卡MTU", "路径MTU", "方式"}
+	}
+	widths := []int{14, 26, 10, 9, 9, 9}
+	for i, header := range headers {
+		builder.WriteString(pad(header, widths[i], i >= 3))
+	}
+	builder.WriteString("\n")
+	var warnings []string
+	for _, r := range results {
+		ifMTU, pathMTU, method := "-", "-", r.Method
+		if r.InterfaceMTU > 0 {
+			ifMTU = strconv.Itoa(r.InterfaceMTU)
+		}
+		switch {
+		case r.PathMTU > 0:
+			pathMTU = strconv.Itoa(r.PathMTU)
+		case zh:
+			pathMTU, method = "失败", "-"
+		default:
+			pathMTU, method = "failed", "-"
+		}
+		values := []string{runewidth.Truncate(r.Name, widths[0]-1, ""), r.Address, r.Interface, ifMTU, pathMTU, method}
+		for i, value := range values {
+			builder.WriteString(pad(value, widths[i], i >= 3))
+		}
+		builder.WriteString("\n")
+		if r.Reduced() {
+			if zh {
+				warnings = append(warnings, fmt.Sprintf("警告: 到 %s 的路径MTU %d 小于网卡 %s 的MTU %d，大包可能被丢弃，可调小网卡MTU或启用MSS钳制",
+					r.Address, r.PathMTU, r.Interface, r.InterfaceMTU))
+			} else {
+				warnings = append(warnings, fmt.Sprintf("Warning: path MTU %d to %s is below the MTU %d of %s, large packets may be dropped, lower the interface MTU or clamp the MSS",
+					r.PathMTU, r.Address, r.InterfaceMTU, r.Interface))
+			}
+		}
+	}
+	for _, warning := range warnings {
+		builder.WriteString(warning + "\n")
+	}
+	return builder.String()
+}
+
+func pad(text string, width int, right bool) string {
+	if right {
+		return runewidth.FillLeft(text, width)
+	}
+	return runewidth.FillRight(text, width)
+}
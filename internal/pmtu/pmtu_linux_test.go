@@ -0,0 +1,37 @@
+package pmtu
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestParseReply(t *testing.T) {
+	request := echoRequest(true, 7, 3, 100)
+	if checksum(request) != 0 {
+		t.Fatalf("checksum of the request is not valid")
+	}
+	// 路由器返回的需要分片消息引用原始 IP 头与请求的前 8 字节
+	fragNeeded := make([]byte, 8+20+8)
+	fragNeeded[0], fragNeeded[1] = unreachableV4, fragNeededCode
+	binary.BigEndian.PutUint16(fragNeeded[6:], 1420)
+	fragNeeded[8] = 0x45
+	copy(fragNeeded[28:], request[:8])
+	if ok, mtu := parseReply(true, fragNeeded, 7, 3); ok || mtu != 1420 {
+		t.Fatalf("frag needed = %v, %d", ok, mtu)
+	}
+	if ok, mtu := parseReply(true, fragNeeded, 7, 4); ok || mtu != 0 {
+		t.Fatalf("frag needed of another request = %v, %d", ok, mtu)
+	}
+	tooBig := make([]byte, 8+40+8)
+	tooBig[0] = packetTooBigV6
+	binary.BigEndian.PutUint32(tooBig[4:], 1280)
+	copy(tooBig[48:], echoRequest(false, 9, 1, 0))
+	if ok, mtu := parseReply(false, tooBig, 9, 1); ok || mtu != 1280 {
+		t.Fatalf("packet too big = %v, %d", ok, mtu)
+	}
+	reply := echoRequest(false, 9, 1, 0)
+	reply[0] = echoReplyV6
+	if ok, _ := parseReply(false, reply, 9, 1); !ok {
+		t.Fatalf("echo reply not matched")
+	}
+}
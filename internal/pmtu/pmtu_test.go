@@ -0,0 +1,66 @@
+package pmtu
+
+import (
+	"net"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseList(t *testing.T) {
+	targets, invalid := ParseList("1.1.1.1, example.com:8443=edge, [2001:db8::1]:80=v6, 2001:db8::2, host:0, a/b")
+	if len(invalid) != 2 {
+		t.Fatalf("invalid = %q", invalid)
+	}
+	want := []Target{
+		{Name: "1.1.1.1", Host: "1.1.1.1", Port: 443},
+		{Name: "edge", Host: "example.com", Port: 8443},
+		{Name: "v6", Host: "2001:db8::1", Port: 80},
+		{Name: "2001:db8::2", Host: "2001:db8::2", Port: 443},
+	}
+	if len(targets) != len(want) {
+		t.Fatalf("targets = %+v", targets)
+	}
+	for i := range want {
+		if targets[i] != want[i] {
+			t.Errorf("target %d = %+v, want %+v", i, targets[i], want[i])
+		}
+	}
+}
+
+func TestDiscoverLoopback(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("path MTU discovery is only implemented on Linux")
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	port := listener.Addr().(*net.TCPAddr).Port
+	result := Discover("local", net.ParseIP("127.0.0.1"), port)
+	if result.Error != "" || result.PathMTU == 0 || result.InterfaceMTU == 0 || result.Reduced() {
+		t.Fatalf("result = %+v", result)
+	}
+	mtu, err := probeTCP(net.ParseIP("127.0.0.1"), port, result.InterfaceMTU)
+	// 回环接口上 MSS 受窗口大小限制，只检查范围
+	if err != nil || mtu < 576 || mtu > result.InterfaceMTU {
+		t.Fatalf("probeTCP = %d, %v, interface MTU %d", mtu, err, result.InterfaceMTU)
+	}
+	reduced := &Result{Name: "vpn", Address: "10.0.0.1", Interface: "eth0", InterfaceMTU: 1500, PathMTU: 1420, Method: MethodICMP}
+	// 没有 CAP_NET_RAW 时退回 TCP，MSS 不代表路径 MTU，不应告警
+	mss := &Result{Name: "web", Address: "10.0.0.2", Interface: "eth0", InterfaceMTU: 1500, PathMTU: 1400, Method: MethodTCP}
+	text := Format([]*Result{result, reduced, mss}, "en")
+	if !strings.Contains(text, "path MTU 1420 to 10.0.0.1 is below the MTU 1500 of eth0") || strings.Count(text, "Warning") != 1 {
+		t.Fatalf("Format = %s", text)
+	}
+}
@@ -0,0 +1,225 @@
+package pmtu
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// ICMP message types
+const (
+	echoRequestV4  = 8
+	echoReplyV4    = 0
+	unreachableV4  = 3
+	fragNeededCode = 4
+	echoRequestV6  = 128
+	echoReplyV6    = 129
+	packetTooBigV6 = 2
+)
+
+// tcpiOptTimestamps is the TCPI_OPT_TIMESTAMPS bit of tcpi_options
+const tcpiOptTimestamps = 1
+
+// checksum is the Internet checksum of RFC 1071
+func checksum(data []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(data[i:]))
+	}
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	return ^uint16(sum)
+}
+
+// echoRequest builds an echo request with payload bytes of data, the kernel fills the ICMPv6 checksum
+func echoRequest(v4 bool, id, seq uint16, payload int) []byte {
+	packet := make([]byte, 8+payload)
+	packet[0] = echoRequestV6
+	if v4 {
+		packet[0] = echoRequestV4
+	}
+	binary.BigEndian.PutUint16(packet[4:], id)
+	binary.BigEndian.PutUint16(packet[6:], seq)
+	if v4 {
+		binary.BigEndian.PutUint16(packet[2:], checksum(packet))
+	}
+	return packet
+}
+
+// parseReply matches an ICMP message against the echo request id/seq, it returns whether it is the echo reply
+// and the MTU of a "fragmentation needed" or "packet too big" error quoting the request
+func parseReply(v4 bool, message []byte, id, seq uint16) (bool, int) {
+	if len(message) < 8 {
+		return false, 0
+	}
+	matches := func(echo []byte, request byte) bool {
+		return len(echo) >= 8 && echo[0] == request &&
+			binary.BigEndian.Uint16(echo[4:]) == id && binary.BigEndian.Uint16(echo[6:]) == seq
+	}
+	if v4 {
+		switch {
+		case message[0] == echoReplyV4:
+			return matches(message, echoReplyV4), 0
+		case message[0] == unreachableV4 && message[1] == fragNeededCode && len(message) >= 28:
+			// 引用的原始 IP 头长度由 IHL 给出
+			inner := message[8:]
+			length := int(inner[0]&0x0f) * 4
+			if len(inner) >= length+8 && matches(inner[length:], echoRequestV4) {
+				return false, int(binary.BigEndian.Uint16(message[6:]))
+			}
+		}
+		return false, 0
+	}
+	switch {
+	case message[0] == echoReplyV6:
+		return matches(message, echoReplyV6), 0
+	case message[0] == packetTooBigV6 && len(message) >= 8+40+8 && matches(message[48:], echoRequestV6):
+		return false, int(binary.BigEndian.Uint32(message[4:]))
+	}
+	return false, 0
+}
+
+// prober sends DF echo requests of a given size to one address over a raw socket
+type prober struct {
+	conn   *net.IPConn
+	target *net.IPAddr
+	v4     bool
+	id     uint16
+	seq    uint16
+	buffer []byte
+}
+
+// send reports whether a packet of size bytes, IP header included, comes back, and the MTU reported by a router
+// that could not forward it
+func (p *prober) send(size int) (bool, int, error) {
+	header := 20 + 8
+	if !p.v4 {
+		header = 40 + 8
+	}
+	for attempt := 0; attempt < 2; attempt++ {
+		p.seq++
+		if _, err := p.conn.WriteTo(echoRequest(p.v4, p.id, p.seq, size-header), p.target); err != nil {
+			if errors.Is(err, syscall.EMSGSIZE) {
+				return false, 0, nil
+			}
+			return false, 0, err
+		}
+		deadline := time.Now().Add(probeTimeout)
+		p.conn.SetReadDeadline(deadline)
+		for time.Now().Before(deadline) {
+			n, _, err := p.conn.ReadFrom(p.buffer)
+			if err != nil {
+				break
+			}
+			ok, mtu := parseReply(p.v4, p.buffer[:n], p.id, p.seq)
+			if ok || mtu > 0 {
+				return ok, mtu, nil
+			}
+		}
+	}
+	return false, 0, nil
+}
+
+// probeICMP binary searches the largest echo request that is answered, from the minimum MTU of the family up
+// to the interface MTU, with DF set and the cached path MTU of the kernel ignored
+func probeICMP(ip net.IP, ifMTU int) (int, error) {
+	v4 := ip.To4() != nil
+	network, listen, low := "ip6:ipv6-icmp", "::", 1280
+	level, option, value := unix.IPPROTO_IPV6, unix.IPV6_MTU_DISCOVER, unix.IPV6_PMTUDISC_PROBE
+	if v4 {
+		network, listen, low = "ip4:icmp", "0.0.0.0", 576
+		level, option, value = unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, unix.IP_PMTUDISC_PROBE
+	}
+	packetConn, err := net.ListenPacket(network, listen)
+	if err != nil {
+		return 0, err
+	}
+	defer packetConn.Close()
+	conn := packetConn.(*net.IPConn)
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var optErr error
+	raw.Control(func(fd uintptr) {
+		optErr = unix.SetsockoptInt(int(fd), level, option, value)
+	})
+	if optErr != nil {
+		return 0, optErr
+	}
+	random := make([]byte, 2)
+	rand.Read(random)
+	p := &prober{conn: conn, target: &net.IPAddr{IP: ip}, v4: v4, id: binary.BigEndian.Uint16(random), buffer: make([]byte, 65536)}
+	high := min(ifMTU, 65535)
+	if ok, _, err := p.send(min(low, high)); err != nil || !ok {
+		if err == nil {
+			err = errNoReply
+		}
+		return 0, err
+	}
+	// good 可以通过，bad 无法通过
+	good, bad := min(low, high), high+1
+	next := high
+	for bad-good > 1 {
+		ok, mtu, err := p.send(next)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			good = next
+		} else {
+			bad = next
+		}
+		// 路由器返回的 MTU 优先尝试，否则取中间值
+		if mtu > good && mtu < bad && mtu != next {
+			next = mtu
+		} else {
+			next = (good + bad) / 2
+		}
+	}
+	return good, nil
+}
+
+// probeTCP estimates the path MTU from the MSS the kernel uses on a connection to ip:port,
+// which reflects the MSS advertised by the peer, clamping by middleboxes and the cached path MTU
+func probeTCP(ip net.IP, port int, ifMTU int) (int, error) {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip.String(), strconv.Itoa(port)), probeTimeout*2)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	raw, err := conn.(*net.TCPConn).SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var info *unix.TCPInfo
+	var infoErr error
+	raw.Control(func(fd uintptr) {
+		info, infoErr = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+	})
+	if infoErr != nil {
+		return 0, infoErr
+	}
+	// MSS 不含 IP 头、TCP 头与时间戳选项
+	mtu := int(info.Snd_mss) + 20 + 20
+	if ip.To4() == nil {
+		mtu += 20
+	}
+	if info.Options&tcpiOptTimestamps != 0 {
+		mtu += 12
+	}
+	if info.Pmtu > 0 {
+		mtu = min(mtu, int(info.Pmtu))
+	}
+	return min(mtu, ifMTU), nil
+}
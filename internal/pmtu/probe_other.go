@@ -0,0 +1,21 @@
+//go:build !linux
+
+package pmtu
+
+import (
+	"errors"
+	"net"
+)
+
+// errUnsupported means path MTU probing is only implemented on Linux
+var errUnsupported = errors.New("path MTU discovery is only supported on Linux")
+
+// probeICMP is only available on Linux
+func probeICMP(ip net.IP, ifMTU int) (int, error) {
+	return 0, errUnsupported
+}
+
+// probeTCP is only available on Linux
+func probeTCP(ip net.IP, port int, ifMTU int) (int, error) {
+	return 0, errUnsupported
+}
@@ -0,0 +1,214 @@
+package report
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oneclickvirt/ecs/internal/disklatency"
+)
+
+// Metric is one numeric result of a section
+type Metric struct {
+	Section       string  `json:"section"`
+	Name          string  `json:"name"`
+	Value         float64 `json:"value"`
+	Unit          string  `json:"unit,omitempty"`
+	LowerIsBetter bool    `json:"lower_is_better,omitempty"`
+}
+
+// Key identifies the metric across runs
+func (m Metric) Key() string {
+	return m.Section + "/" + m.Name
+}
+
+var (
+	// 形如 "1 Thread(s) Test: 1234" 或 "单线程顺序写速度: 12345.67 MB/s(...)"
+	labelValueRegex = regexp.MustCompile(`^\s*([^:：]*[^\s:：])\s*[:：]\s*([-+]?\d+(?:\.\d+)?)\s*([A-Za-z/%]*)`)
+	// 形如 "12.34 MB/s(3.09k)"，磁盘表格的一列
+	diskSpeedRegex = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*(KB/s|MB/s|GB/s)`)
+	// 形如 "联通上海  123.45 Mbps  456.78 Mbps  12.3ms"，测速表格的一行
+	speedRowRegex = regexp.MustCompile(`^\s*(\S.*?)\s+(\d+(?:\.\d+)?)\s*Mbps\s+(\d+(?:\.\d+)?)\s*Mbps`)
+)
+
+// Metrics returns the metrics of every section of the report
+func (r *Report) Metrics() []Metric {
+	r.mu.Lock()
+	sections := make([]Section, len(r.Sections))
+	copy(sections, r.Sections)
+	r.mu.Unlock()
+	var metrics []Metric
+	for _, section := range sections {
+		metrics = append(metrics, section.Metrics()...)
+	}
+	return metrics
+}
+
+// metricSet collects the metrics of one section, numbering repeated names
+type metricSet struct {
+	section string
+	metrics []Metric
+	seen    map[string]int
+}
+
+func newMetricSet(section string) *metricSet {
+	return &metricSet{section: section, seen: make(map[string]int)}
+}
+
+func (m *metricSet) add(name string, value float64, unit string) {
+	name = strings.Join(strings.Fields(name), " ")
+	// 同名指标（如多次出现的表头）加序号区分
+	m.seen[name]++
+	if m.seen[name] > 1 {
+		name += " #" + strconv.Itoa(m.seen[name])
+	}
+	m.metrics = append(m.metrics, Metric{
+		Section:       m.section,
+		Name:          name,
+		Value:         value,
+		Unit:          unit,
+		LowerIsBetter: lowerIsBetter(unit),
+	})
+}
+
+// Metrics returns the numeric results of the section, the parsed library output of Results
+// followed by the values of the structured fields
+func (s Section) Metrics() []Metric {
+	set := newMetricSet(s.Name)
+	set.metrics = append(set.metrics, s.Results...)
+	for _, result := range s.Fio {
+		if result == nil {
+			continue
+		}
+		for _, job := range result.Jobs {
+			prefix := result.Path + " " + job.Name
+			set.add(prefix+" read", job.Read.BandwidthMBps, "MB/s")
+			set.add(prefix+" write", job.Write.BandwidthMBps, "MB/s")
+			set.add(prefix+" total", job.Read.BandwidthMBps+job.Write.BandwidthMBps, "MB/s")
+		}
+	}
+	for _, result := range s.DiskLatency {
+		if result == nil {
+			continue
+		}
+		for _, row := range []struct {
+			name  string
+			value disklatency.Percentiles
+		}{{"4k random read", result.RandomRead}, {"4k random write", result.RandomWrite}, {"fdatasync", result.SyncLatency}} {
+			prefix := result.Path + " " + row.name
+			set.add(prefix+" p50", row.value.P50, "us")
+			set.add(prefix+" p95", row.value.P95, "us")
+			set.add(prefix+" p99", row.value.P99, "us")
+			set.add(prefix+" p99.9", row.value.P999, "us")
+		}
+		set.add(result.Path+" fdatasync rate", result.SyncPerSecond, "ops/s")
+	}
+	for _, result := range s.SpeedServers {
+		if result.Error != "" {
+			continue
+		}
+		set.add(result.Label+" upload", result.Upload, "Mbps")
+		set.add(result.Label+" download", result.Download, "Mbps")
+		set.add(result.Label+" latency", result.Latency, "ms")
+	}
+	for _, result := range s.HTTPSpeed {
+		if result.Download != nil {
+			set.add(result.Label+" download", result.Download.Mbps, "Mbps")
+		}
+		if result.Upload != nil {
+			set.add(result.Label+" upload", result.Upload.Mbps, "Mbps")
+		}
+		if result.Latency > 0 {
+			set.add(result.Label+" latency", float64(result.Latency)/float64(time.Millisecond), "ms")
+		}
+	}
+	for _, result := range s.Iperf {
+		if result == nil || result.Error != "" {
+			continue
+		}
+		name := result.Protocol + " upload"
+		if result.Reverse {
+			name = result.Protocol + " download"
+		}
+		set.add(name, result.Mbps(), "Mbps")
+		if result.Protocol == "UDP" {
+			set.add(name+" jitter", result.JitterMs, "ms")
+		}
+	}
+	return set.metrics
+}
+
+// ParseOutput extracts the numeric results from the text of the CPU, memory, disk and speed test
+// libraries, which report them only as text. It must be given the library output alone, the rest of
+// a section is covered by the structured fields
+func ParseOutput(section, text string) []Metric {
+	set := newMetricSet(section)
+	var diskLabels []string
+	for _, line := range strings.Split(text, "\n") {
+		switch section {
+		case "disk":
+			// 根据表头判断后续各列的含义
+			switch {
+			case strings.Contains(line, "Read(IOPS)") || strings.Contains(line, "读测试(IOPS)"):
+				diskLabels = []string{"read", "write", "total"}
+				continue
+			case strings.Contains(line, "Direct Write") || strings.Contains(line, "直接写入"):
+				diskLabels = []string{"write", "read"}
+				continue
+			}
+			fields := strings.Fields(line)
+			speeds := diskSpeedRegex.FindAllStringSubmatch(line, -1)
+			if diskLabels != nil && len(fields) >= 2 && len(speeds) > 0 {
+				for i, speed := range speeds {
+					if i >= len(diskLabels) {
+						break
+					}
+					value, unit := normalizeSpeed(speed[1], speed[2])
+					set.add(fields[0]+" "+fields[1]+" "+diskLabels[i], value, unit)
+				}
+				continue
+			}
+		case "speed":
+			if match := speedRowRegex.FindStringSubmatch(line); match != nil {
+				upload, _ := strconv.ParseFloat(match[2], 64)
+				download, _ := strconv.ParseFloat(match[3], 64)
+				set.add(match[1]+" upload", upload, "Mbps")
+				set.add(match[1]+" download", download, "Mbps")
+			}
+			continue
+		}
+		if match := labelValueRegex.FindStringSubmatch(line); match != nil {
+			value, err := strconv.ParseFloat(match[2], 64)
+			if err != nil {
+				continue
+			}
+			unit := match[3]
+			if speed := diskSpeedRegex.FindStringSubmatch(match[2] + " " + unit); speed != nil {
+				value, unit = normalizeSpeed(speed[1], speed[2])
+			}
+			set.add(match[1], value, unit)
+		}
+	}
+	return set.metrics
+}
+
+// normalizeSpeed converts KB/s and GB/s to MB/s so runs stay comparable
+func normalizeSpeed(number, unit string) (float64, string) {
+	value, _ := strconv.ParseFloat(number, 64)
+	switch unit {
+	case "KB/s":
+		value /= 1024
+	case "GB/s":
+		value *= 1024
+	}
+	return value, "MB/s"
+}
+
+func lowerIsBetter(unit string) bool {
+	switch strings.ToLower(unit) {
+	case "ns", "us", "µs", "ms", "s", "sec":
+		return true
+	}
+	return false
+}
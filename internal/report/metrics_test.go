@@ -0,0 +1,129 @@
+package report
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/oneclickvirt/ecs/internal/disklatency"
+	"github.com/oneclickvirt/ecs/internal/fioprofile"
+	"github.com/oneclickvirt/ecs/internal/httpspeed"
+	"github.com/oneclickvirt/ecs/internal/iperf"
+	"github.com/oneclickvirt/ecs/internal/speedlist"
+)
+
+func TestParseOutput(t *testing.T) {
+	outputs := []struct{ section, text string }{
+		{"cpu", "1 Thread(s) Test: 1000\n4 Thread(s) Test: 3900\n"},
+		{"memory", "单线程顺序写速度: 12.50 GB/s(1.5K IOPS, 5s)\n"},
+		{"disk", "Test Path         Block     Read(IOPS)           Write(IOPS)          Total(IOPS)\n" +
+			"/root             4k        20.00 MB/s(5.0k)        10.00 MB/s(2.5k)        30.00 MB/s(7.5k)\n"},
+		{"speed", "Location        Upload          Download        Latency         PacketLoss\n" +
+			"Speedtest.net   95.10 Mbps      930.20 Mbps     1.2ms           0%\n"},
+	}
+	want := map[string]float64{
+		"cpu/1 Thread(s) Test":         1000,
+		"cpu/4 Thread(s) Test":         3900,
+		"memory/单线程顺序写速度":              12800,
+		"disk//root 4k read":           20,
+		"disk//root 4k write":          10,
+		"disk//root 4k total":          30,
+		"speed/Speedtest.net upload":   95.1,
+		"speed/Speedtest.net download": 930.2,
+	}
+	got := make(map[string]float64)
+	for _, output := range outputs {
+		for _, metric := range ParseOutput(output.section, output.text) {
+			got[metric.Key()] = metric.Value
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d metrics %v, want %d", len(got), got, len(want))
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%s = %v, want %v", key, got[key], value)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	stats := Summarize([]float64{4, 2, 8, 6})
+	if stats.Min != 2 || stats.Max != 8 || stats.Median != 5 || stats.Mean != 5 {
+		t.Fatalf("Summarize = %+v", stats)
+	}
+	if stats.StdDev < 2.58 || stats.StdDev > 2.59 || stats.CV < 51.6 || stats.CV > 51.7 {
+		t.Fatalf("StdDev = %v, CV = %v", stats.StdDev, stats.CV)
+	}
+}
+
+func TestSectionMetrics(t *testing.T) {
+	// 输出文本中的其余数字不是指标，只取 Results 与结构化字段
+	section := NewSection("iperf", "", "Steal: 12.5 %\nTCP Upload: 1.00 Mbps\n")
+	section.Iperf = []*iperf.Result{
+		{Protocol: "TCP", Streams: 4, Seconds: 10, ReceivedBytes: 125e6, Retransmits: 3},
+		{Protocol: "TCP", Reverse: true, Streams: 4, Seconds: 10, ReceivedBytes: 250e6, Retransmits: -1},
+		{Protocol: "UDP", Streams: 4, Seconds: 10, ReceivedBytes: 125e6, Packets: 1000, LostPackets: 2, JitterMs: 0.25, TargetBitrate: 100e6},
+		{Protocol: "UDP", Reverse: true, Error: "connection refused"},
+	}
+	got := make(map[string]float64)
+	for _, metric := range section.Metrics() {
+		got[metric.Key()] = metric.Value
+	}
+	want := map[string]float64{
+		"iperf/TCP upload":        100,
+		"iperf/TCP download":      200,
+		"iperf/UDP upload":        100,
+		"iperf/UDP upload jitter": 0.25,
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("iperf metrics = %v, want %v", got, want)
+	}
+
+	disk := Section{
+		Name:    "disk",
+		Results: ParseOutput("disk", "Test Path  Block  Read(IOPS)  Write(IOPS)  Total(IOPS)\n/data  4k  20.00 MB/s(5.0k)  10.00 MB/s(2.5k)  30.00 MB/s(7.5k)\n"),
+		Fio: []*fioprofile.Result{{Profile: "oltp", Path: "/data", Jobs: []fioprofile.Job{
+			{Name: "randrw", Read: fioprofile.Stats{BandwidthMBps: 40}, Write: fioprofile.Stats{BandwidthMBps: 20}},
+		}}},
+		DiskLatency: []*disklatency.Result{{Path: "/data", RandomRead: disklatency.Percentiles{P99: 180}, SyncPerSecond: 950}},
+	}
+	metrics := disk.Metrics()
+	got = make(map[string]float64)
+	lower := make(map[string]bool)
+	for _, metric := range metrics {
+		got[metric.Name] = metric.Value
+		lower[metric.Name] = metric.LowerIsBetter
+	}
+	if len(metrics) != 3+3+13 || got["/data 4k read"] != 20 || got["/data randrw total"] != 60 ||
+		got["/data 4k random read p99"] != 180 || !lower["/data 4k random read p99"] ||
+		got["/data fdatasync rate"] != 950 || lower["/data fdatasync rate"] {
+		t.Fatalf("disk metrics = %+v", metrics)
+	}
+
+	speed := Section{Name: "speed", SpeedServers: []speedlist.Result{
+		{Label: "上海电信", Target: "3633", Upload: 95.1, Download: 930.2, Latency: 12.5},
+		{Label: "Example", Target: "5145", Error: "context deadline exceeded"},
+	}}
+	got = make(map[string]float64)
+	for _, metric := range speed.Metrics() {
+		got[metric.Key()] = metric.Value
+	}
+	want = map[string]float64{
+		"speed/上海电信 upload":   95.1,
+		"speed/上海电信 download": 930.2,
+		"speed/上海电信 latency":  12.5,
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("speed metrics = %v, want %v", got, want)
+	}
+
+	speed = Section{Name: "speed", HTTPSpeed: []httpspeed.Result{
+		{Label: "Our DC", Latency: 8 * time.Millisecond, Download: &httpspeed.Transfer{Mbps: 850}},
+	}}
+	metrics = speed.Metrics()
+	if len(metrics) != 2 || metrics[0].Name != "Our DC download" || metrics[0].Value != 850 ||
+		metrics[1].Name != "Our DC latency" || metrics[1].Value != 8 || !metrics[1].LowerIsBetter {
+		t.Fatalf("HTTP speed metrics = %+v", metrics)
+	}
+}
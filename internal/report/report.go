@@ -0,0 +1,143 @@
+package report
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oneclickvirt/ecs/internal/blockdev"
+	"github.com/oneclickvirt/ecs/internal/contention"
+	"github.com/oneclickvirt/ecs/internal/disklatency"
+	"github.com/oneclickvirt/ecs/internal/diskpath"
+	"github.com/oneclickvirt/ecs/internal/dnsbench"
+	"github.com/oneclickvirt/ecs/internal/fioprofile"
+	"github.com/oneclickvirt/ecs/internal/httpspeed"
+	"github.com/oneclickvirt/ecs/internal/iperf"
+	"github.com/oneclickvirt/ecs/internal/ipv6check"
+	"github.com/oneclickvirt/ecs/internal/memguard"
+	"github.com/oneclickvirt/ecs/internal/natcheck"
+	"github.com/oneclickvirt/ecs/internal/numa"
+	"github.com/oneclickvirt/ecs/internal/pingstats"
+	"github.com/oneclickvirt/ecs/internal/pmtu"
+	"github.com/oneclickvirt/ecs/internal/speedlist"
+)
+
+// Section holds the structured result of one test section
+type Section struct {
+	Name   string `json:"name"`
+	Title  string `json:"title,omitempty"`
+	Method string `json:"method,omitempty"`
+	Output string `json:"output"`
+	// Results are the numbers of the CPU, memory, disk and speed test libraries, parsed from their output
+	Results []Metric `json:"results,omitempty"`
+	// Contention is the CPU steal and pressure observed while a hardware test ran
+	Contention *contention.Result `json:"contention,omitempty"`
+	// NUMA is the per node pair memory matrix of the memory section on multi-node hosts
+	NUMA *numa.Matrix `json:"numa,omitempty"`
+	// MemoryGuard is how the memory section was sized or skipped for the available memory
+	MemoryGuard *memguard.Decision `json:"memory_guard,omitempty"`
+	// Fio is the parsed result of the fio profile on each path of the disk section
+	Fio []*fioprofile.Result `json:"fio,omitempty"`
+	// DiskLatency is the latency percentiles and fdatasync rate on each path of the disk section
+	DiskLatency []*disklatency.Result `json:"disk_latency,omitempty"`
+	// Mounts describes the filesystem behind each explicitly given disk test path
+	Mounts []diskpath.Info `json:"mounts,omitempty"`
+	// Devices is the storage stack and disks behind each tested path of the disk section
+	Devices []*blockdev.Info `json:"devices,omitempty"`
+	// Ping is the latency distribution of every target of the network section
+	Ping []*pingstats.Stats `json:"ping,omitempty"`
+	// DNS is the resolver benchmark of the DNS section
+	DNS *dnsbench.Result `json:"dns,omitempty"`
+	// MTU is the path MTU to every target of the network section
+	MTU []*pmtu.Result `json:"mtu,omitempty"`
+	// NAT is the NAT behaviour and port mapping support of the NAT section
+	NAT *natcheck.Result `json:"nat,omitempty"`
+	// IPv6 is the addresses, /64 routing and IPv4 vs IPv6 latency of the IPv6 section
+	IPv6 *ipv6check.Result `json:"ipv6,omitempty"`
+	// SpeedServers is the result of every -speed-servers entry of the speed section
+	SpeedServers []speedlist.Result `json:"speed_servers,omitempty"`
+	// HTTPSpeed is the result of every -speed-url endpoint of the speed section
+	HTTPSpeed []httpspeed.Result `json:"http_speed,omitempty"`
+	// Iperf is the result of every direction and protocol of the iperf section
+	Iperf []*iperf.Result `json:"iperf,omitempty"`
+}
+
+// Report holds the structured result of a whole test run
+type Report struct {
+	Version   string    `json:"version"`
+	Language  string    `json:"language"`
+	Hostname  string    `json:"hostname,omitempty"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time,omitempty"`
+	Sections  []Section `json:"sections"`
+	mu        sync.Mutex
+}
+
+// New creates an empty report for a run started at startTime
+func New(version, language string, startTime time.Time) *Report {
+	hostname, _ := os.Hostname()
+	return &Report{
+		Version:   version,
+		Language:  language,
+		Hostname:  hostname,
+		StartTime: startTime,
+		Sections:  []Section{},
+	}
+}
+
+// NewSection builds a section from the captured text, taking the title from its centered header line
+func NewSection(name, method, text string) Section {
+	section := Section{
+		Name:   name,
+		Method: method,
+		Output: text,
+	}
+	firstLine := strings.SplitN(text, "\n", 2)[0]
+	if strings.HasPrefix(firstLine, "-") {
+		section.Title = strings.Trim(firstLine, "-")
+	}
+	return section
+}
+
+// Add appends a section to the report
+func (r *Report) Add(section Section) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Sections = append(r.Sections, section)
+}
+
+// Finish records the end time of the run
+func (r *Report) Finish() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.EndTime = time.Now()
+}
+
+// Section returns the first section with the given name
+func (r *Report) Section(name string) (Section, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, section := range r.Sections {
+		if section.Name == name {
+			return section, true
+		}
+	}
+	return Section{}, false
+}
+
+// Clone returns a copy that is safe to read while the run is still adding sections
+func (r *Report) Clone() *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sections := make([]Section, len(r.Sections))
+	copy(sections, r.Sections)
+	return &Report{
+		Version:   r.Version,
+		Language:  r.Language,
+		Hostname:  r.Hostname,
+		StartTime: r.StartTime,
+		EndTime:   r.EndTime,
+		Sections:  sections,
+	}
+}
@@ -0,0 +1,54 @@
+package report
+
+import (
+	"math"
+	"sort"
+)
+
+// Stats summarizes repeated measurements of one metric
+type Stats struct {
+	Count  int     `json:"count"`
+	Min    float64 `json:"min"`
+	Median float64 `json:"median"`
+	Mean   float64 `json:"mean"`
+	Max    float64 `json:"max"`
+	StdDev float64 `json:"stddev"`
+	// CV is the coefficient of variation in percent
+	CV float64 `json:"cv"`
+}
+
+// Summarize computes the statistics of values, using the sample standard deviation
+func Summarize(values []float64) Stats {
+	if len(values) == 0 {
+		return Stats{}
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	stats := Stats{
+		Count: len(sorted),
+		Min:   sorted[0],
+		Max:   sorted[len(sorted)-1],
+	}
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		stats.Median = (sorted[middle-1] + sorted[middle]) / 2
+	} else {
+		stats.Median = sorted[middle]
+	}
+	var sum float64
+	for _, value := range sorted {
+		sum += value
+	}
+	stats.Mean = sum / float64(len(sorted))
+	if len(sorted) > 1 {
+		var squares float64
+		for _, value := range sorted {
+			squares += (value - stats.Mean) * (value - stats.Mean)
+		}
+		stats.StdDev = math.Sqrt(squares / float64(len(sorted)-1))
+	}
+	if stats.Mean != 0 {
+		stats.CV = stats.StdDev / math.Abs(stats.Mean) * 100
+	}
+	return stats
+}
@@ -0,0 +1,199 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/oneclickvirt/ecs/internal/blockdev"
+	"github.com/oneclickvirt/ecs/internal/cleanup"
+	"github.com/oneclickvirt/ecs/internal/disklatency"
+	"github.com/oneclickvirt/ecs/internal/diskpath"
+	"github.com/oneclickvirt/ecs/internal/fioprofile"
+	"github.com/oneclickvirt/ecs/internal/params"
+	"github.com/oneclickvirt/ecs/internal/report"
+	"github.com/oneclickvirt/ecs/internal/tests"
+)
+
+// diskPart is the output of one disk test method, shown under its own title
+type diskPart struct {
+	method string
+	text   string
+}
+
+// diskRun holds the disk test results of one path
+type diskRun struct {
+	path        string
+	mount       diskpath.Info
+	device      *blockdev.Info
+	parts       []diskPart
+	results     []report.Metric // 测试库输出中的指标
+	fio         *fioprofile.Result
+	latency     *disklatency.Result
+	latencyText string
+}
+
+// method returns the methods of the run joined like "dd+fio"
+func (r diskRun) method() string {
+	methods := make([]string, 0, len(r.parts))
+	for _, part := range r.parts {
+		methods = append(methods, part.method)
+	}
+	return strings.Join(methods, "+")
+}
+
+// metrics returns the results of the path: the library output, the fio profile and the latency test
+func (r diskRun) metrics() []report.Metric {
+	section := report.Section{Name: "disk", Results: r.results}
+	if r.fio != nil {
+		section.Fio = []*fioprofile.Result{r.fio}
+	}
+	if r.latency != nil {
+		section.DiskLatency = []*disklatency.Result{r.latency}
+	}
+	return section.Metrics()
+}
+
+// header returns the lines shown above the output of the path: its mount and, with device set, its storage stack
+func (r diskRun) header(language string, device bool) string {
+	var builder strings.Builder
+	if r.path != "" {
+		builder.WriteString(r.mount.Format(language))
+	}
+	if device {
+		builder.WriteString(r.device.Format(language))
+	}
+	return builder.String()
+}
+
+// diskTempPatterns are the files the fio and dd libraries leave behind when interrupted
+var diskTempPatterns = []string{"test.fio", "*MB.test", "*GB.test", "zero_temp"}
+
+// diskRequiredBytes is the size of the test files written to one path before the libraries shrink them
+func diskRequiredBytes(config *params.Config) uint64 {
+	required := uint64(2 << 30)
+	if runtime.GOARCH == "arm64" || runtime.GOARCH == "arm" {
+		required = 512 << 20
+	}
+	switch {
+	case config.FioProfile != "":
+		required = 1 << 30
+	case config.AutoChangeDiskMethod && config.DiskTestMethod == "dd":
+		required = 1<<30 + 100<<20
+	}
+	if config.DiskLatency {
+		required += 128 << 20
+	}
+	return required
+}
+
+// testDiskPath runs the configured disk tests on one path, an empty path keeps the library defaults
+func testDiskPath(config *params.Config, path string, mount diskpath.Info) diskRun {
+	run := diskRun{path: path, mount: mount, device: blockdev.Identify(mount)}
+	// 登记测试库可能遗留的文件，正常结束、panic 与中断信号时都会删除
+	dirs := []string{path}
+	if path == "" {
+		dirs = []string{diskpath.Default(), os.TempDir()}
+	}
+	for _, dir := range dirs {
+		defer cleanup.Track(dir, diskTempPatterns...)()
+	}
+	switch {
+	case config.FioProfile != "":
+		// 自定义 fio 作业取代默认的 fio/dd 测试
+		first := true
+		_, res, _ := repeatTest(config, "disk", func() (string, string, []report.Metric) {
+			result, text := fioprofile.Test(config.Language, config.FioProfile, path)
+			// repeatTest 打印第一次的结果，结构化数据也取第一次
+			if first {
+				run.fio, first = result, false
+			}
+			return "fio-profile", text, report.Section{Name: "disk", Fio: []*fioprofile.Result{result}}.Metrics()
+		})
+		run.parts = append(run.parts, diskPart{method: "fio-profile", text: res})
+	case config.AutoChangeDiskMethod:
+		method, res, metrics := repeatTest(config, "disk", func() (string, string, []report.Metric) {
+			method, text := tests.DiskTest(config.Language, config.DiskTestMethod, path, config.DiskMultiCheck, config.AutoChangeDiskMethod)
+			return method, text, libraryMetrics("disk", text)
+		})
+		run.parts = append(run.parts, diskPart{method: method, text: res})
+		run.results = append(run.results, metrics...)
+	default:
+		for _, method := range []string{"dd", "fio"} {
+			_, res, metrics := repeatTest(config, "disk", func() (string, string, []report.Metric) {
+				realMethod, text := tests.DiskTest(config.Language, method, path, config.DiskMultiCheck, config.AutoChangeDiskMethod)
+				return realMethod, text, libraryMetrics("disk", text)
+			})
+			run.parts = append(run.parts, diskPart{method: method, text: res})
+			run.results = append(run.results, metrics...)
+		}
+	}
+	// 延迟测试在争抢监控期间运行，结果打印在吞吐测试之后
+	if config.DiskLatency {
+		run.latency, run.latencyText = disklatency.Test(config.Language, path)
+	}
+	return run
+}
+
+// compareDiskRuns renders the metrics of every path side by side
+func compareDiskRuns(config *params.Config, runs []diskRun) string {
+	var keys []string
+	units := make(map[string]string)
+	values := make([]map[string]float64, len(runs))
+	for i, run := range runs {
+		values[i] = make(map[string]float64)
+		for _, metric := range run.metrics() {
+			// 指标名以测试路径开头，去掉后才能跨路径对齐
+			key := strings.TrimPrefix(metric.Name, run.path+" ")
+			if _, ok := units[key]; !ok {
+				keys = append(keys, key)
+				units[key] = metric.Unit
+			}
+			values[i][key] = metric.Value
+		}
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	labels := make([]string, len(keys))
+	labelWidth := 10
+	for i, key := range keys {
+		labels[i] = key
+		if units[key] != "" {
+			labels[i] += " (" + units[key] + ")"
+		}
+		if width := runewidth.StringWidth(labels[i]); width > labelWidth {
+			labelWidth = width
+		}
+	}
+	columnWidth := 12
+	for _, run := range runs {
+		if width := runewidth.StringWidth(run.path); width > columnWidth {
+			columnWidth = width
+		}
+	}
+	var builder strings.Builder
+	if config.Language == "zh" {
+		builder.WriteString(runewidth.FillRight("指标", labelWidth))
+	} else {
+		builder.WriteString(runewidth.FillRight("Metric", labelWidth))
+	}
+	for _, run := range runs {
+		builder.WriteString(" " + runewidth.FillLeft(run.path, columnWidth))
+	}
+	builder.WriteString("\n")
+	for i, key := range keys {
+		builder.WriteString(runewidth.FillRight(labels[i], labelWidth))
+		for j := range runs {
+			cell := "-"
+			if value, ok := values[j][key]; ok {
+				cell = fmt.Sprintf("%.2f", value)
+			}
+			builder.WriteString(" " + runewidth.FillLeft(cell, columnWidth))
+		}
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
@@ -0,0 +1,101 @@
+package runner
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/oneclickvirt/ecs/internal/blockdev"
+	"github.com/oneclickvirt/ecs/internal/diskpath"
+	"github.com/oneclickvirt/ecs/internal/fioprofile"
+	"github.com/oneclickvirt/ecs/internal/params"
+	"github.com/oneclickvirt/ecs/internal/report"
+)
+
+func TestDiskRequiredBytes(t *testing.T) {
+	base := uint64(2 << 30)
+	if runtime.GOARCH == "arm64" || runtime.GOARCH == "arm" {
+		base = 512 << 20
+	}
+	for _, test := range []struct {
+		name   string
+		config params.Config
+		want   uint64
+	}{
+		{"default", params.Config{}, base},
+		{"latency", params.Config{DiskLatency: true}, base + 128<<20},
+		{"fio profile", params.Config{FioProfile: "database", AutoChangeDiskMethod: true, DiskTestMethod: "dd"}, 1 << 30},
+		{"auto dd", params.Config{AutoChangeDiskMethod: true, DiskTestMethod: "dd"}, 1<<30 + 100<<20},
+		{"auto fio", params.Config{AutoChangeDiskMethod: true, DiskTestMethod: "fio"}, base},
+		{"fio profile with latency", params.Config{FioProfile: "database", DiskLatency: true}, 1<<30 + 128<<20},
+	} {
+		if got := diskRequiredBytes(&test.config); got != test.want {
+			t.Errorf("%s: diskRequiredBytes = %d, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDiskRunHeader(t *testing.T) {
+	mount := diskpath.Info{Path: "/data", MountPoint: "/data", Device: "/dev/vdb", FSType: "xfs"}
+	device := &blockdev.Info{Path: "/data", Disks: []blockdev.Disk{{Name: "vdb", Kind: "HDD", Bus: "virtio"}}}
+	for _, test := range []struct {
+		name   string
+		run    diskRun
+		device bool
+		want   string
+	}{
+		{"default path", diskRun{}, true, ""},
+		{"mount only", diskRun{path: "/data", mount: mount, device: device}, false, mount.Format("en")},
+		{"mount and device", diskRun{path: "/data", mount: mount, device: device}, true, mount.Format("en") + device.Format("en")},
+		{"default path device", diskRun{device: device}, true, device.Format("en")},
+	} {
+		if got := test.run.header("en", test.device); got != test.want {
+			t.Errorf("%s: header = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCompareDiskRuns(t *testing.T) {
+	runs := []diskRun{
+		{
+			path:    "/root",
+			parts:   []diskPart{{method: "dd"}, {method: "fio"}},
+			results: []report.Metric{{Name: "/root 4k read", Value: 20, Unit: "MB/s"}, {Name: "/root 4k write", Value: 10, Unit: "MB/s"}},
+		},
+		{
+			path:    "/mnt/volume-data",
+			parts:   []diskPart{{method: "fio"}},
+			results: []report.Metric{{Name: "/mnt/volume-data 4k read", Value: 350.5, Unit: "MB/s"}},
+			fio: &fioprofile.Result{Path: "/mnt/volume-data", Jobs: []fioprofile.Job{
+				{Name: "randrw", Read: fioprofile.Stats{BandwidthMBps: 40}, Write: fioprofile.Stats{BandwidthMBps: 20}},
+			}},
+		},
+	}
+	if runs[0].method() != "dd+fio" || runs[1].method() != "fio" {
+		t.Fatalf("methods = %q, %q", runs[0].method(), runs[1].method())
+	}
+	for _, test := range []struct {
+		name string
+		runs []diskRun
+		lang string
+		want string
+	}{
+		{"no metrics", []diskRun{{path: "/root"}, {path: "/data"}}, "en", ""},
+		// 指标缺失的路径显示 -，路径名较长时列宽随之增加
+		{"two paths", runs, "en", "" +
+			"Metric                         /root /mnt/volume-data\n" +
+			"4k read (MB/s)                 20.00           350.50\n" +
+			"4k write (MB/s)                10.00                -\n" +
+			"randrw read (MB/s)                 -            40.00\n" +
+			"randrw write (MB/s)                -            20.00\n" +
+			"randrw total (MB/s)                -            60.00\n"},
+		{"zh header", runs[:1], "zh", "" +
+			"指标                   /root\n" +
+			"4k read (MB/s)         20.00\n" +
+			"4k write (MB/s)        10.00\n"},
+	} {
+		got := compareDiskRuns(&params.Config{Language: test.lang}, test.runs)
+		if got != test.want {
+			t.Errorf("%s: compareDiskRuns =\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/oneclickvirt/ecs/internal/params"
+	"github.com/oneclickvirt/ecs/internal/report"
+	"github.com/oneclickvirt/ecs/utils"
+)
+
+// noisyCV is the coefficient of variation (percent) above which a section is flagged as unstable
+var noisyCV = map[string]float64{
+	"cpu":    5,
+	"memory": 5,
+	"disk":   15,
+}
+
+// repeatTest runs test config.Repeat times and returns the method, output and metrics of the first run,
+// the output is followed by the statistics of every metric when the test ran more than once
+func repeatTest(config *params.Config, section string, test func() (string, string, []report.Metric)) (string, string, []report.Metric) {
+	realTestMethod, res, metrics := test()
+	if config.Repeat <= 1 {
+		return realTestMethod, res, metrics
+	}
+	stats := newRepeatStats()
+	stats.add(metrics)
+	for i := 2; i <= config.Repeat; i++ {
+		_, _, repeated := test()
+		stats.add(repeated)
+	}
+	if len(stats.keys) == 0 {
+		return realTestMethod, res, metrics
+	}
+	return realTestMethod, res + stats.format(config.Language, section, config.Repeat), metrics
+}
+
+// repeatStats collects the values of every metric across the runs of a section,
+// a metric missing from some runs is summarized over the runs that reported it
+type repeatStats struct {
+	keys   []string // 首次出现的顺序
+	values map[string][]float64
+	units  map[string]string
+}
+
+func newRepeatStats() *repeatStats {
+	return &repeatStats{values: make(map[string][]float64), units: make(map[string]string)}
+}
+
+func (s *repeatStats) add(metrics []report.Metric) {
+	for _, metric := range metrics {
+		if _, ok := s.values[metric.Name]; !ok {
+			s.keys = append(s.keys, metric.Name)
+		}
+		s.values[metric.Name] = append(s.values[metric.Name], metric.Value)
+		s.units[metric.Name] = metric.Unit
+	}
+}
+
+// libraryMetrics parses the output of a test library that reports its results only as text
+func libraryMetrics(section, text string) []report.Metric {
+	return report.ParseOutput(section, utils.StripANSI(text))
+}
+
+// format renders one row of statistics per metric and flags high variance
+func (s *repeatStats) format(language, section string, runs int) string {
+	labels := make([]string, len(s.keys))
+	labelWidth := 10
+	for i, key := range s.keys {
+		labels[i] = key
+		if s.units[key] != "" {
+			labels[i] += " (" + s.units[key] + ")"
+		}
+		if width := runewidth.StringWidth(labels[i]); width > labelWidth {
+			labelWidth = width
+		}
+	}
+	var (
+		builder strings.Builder
+		noisy   []string
+	)
+	if language == "zh" {
+		builder.WriteString(fmt.Sprintf("重复测试 %d 次统计:\n", runs))
+		writeStatsHeader(&builder, labelWidth, "指标", "最小", "中位数", "平均", "最大", "标准差", "变异系数")
+	} else {
+		builder.WriteString(fmt.Sprintf("Statistics of %d runs:\n", runs))
+		writeStatsHeader(&builder, labelWidth, "Metric", "Min", "Median", "Mean", "Max", "StdDev", "CV")
+	}
+	for i, key := range s.keys {
+		stats := report.Summarize(s.values[key])
+		builder.WriteString(runewidth.FillRight(labels[i], labelWidth))
+		builder.WriteString(fmt.Sprintf(" %10.2f %10.2f %10.2f %10.2f %10.2f %7.1f%%\n",
+			stats.Min, stats.Median, stats.Mean, stats.Max, stats.StdDev, stats.CV))
+		if stats.CV > noisyCV[section] {
+			noisy = append(noisy, key)
+		}
+	}
+	if len(noisy) > 0 {
+		var hint string
+		switch {
+		case section == "disk" && language == "zh":
+			hint = "结果波动较大，可能存在存储争抢"
+		case section == "disk":
+			hint = "results vary a lot, storage contention is likely"
+		case section == "memory" && language == "zh":
+			hint = "结果波动较大，可能存在内存带宽争抢或内存超售"
+		case section == "memory":
+			hint = "results vary a lot, memory bandwidth contention or overcommitted memory is likely"
+		case language == "zh":
+			hint = "结果波动较大，可能存在CPU争抢(steal)"
+		default:
+			hint = "results vary a lot, CPU steal is likely"
+		}
+		if language == "zh" {
+			builder.WriteString(fmt.Sprintf("注意: 变异系数超过 %.0f%% 的指标 %s，%s\n", noisyCV[section], strings.Join(noisy, ", "), hint))
+		} else {
+			builder.WriteString(fmt.Sprintf("Warning: CV above %.0f%% for %s, %s\n", noisyCV[section], strings.Join(noisy, ", "), hint))
+		}
+	}
+	return builder.String()
+}
+
+func writeStatsHeader(builder *strings.Builder, labelWidth int, label string, columns ...string) {
+	builder.WriteString(runewidth.FillRight(label, labelWidth))
+	for i, column := range columns {
+		width := 10
+		if i == len(columns)-1 {
+			width = 8
+		}
+		builder.WriteString(" " + runewidth.FillLeft(column, width))
+	}
+	builder.WriteString("\n")
+}
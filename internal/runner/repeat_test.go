@@ -0,0 +1,86 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/oneclickvirt/ecs/internal/params"
+	"github.com/oneclickvirt/ecs/internal/report"
+)
+
+// fakeRuns returns a test closure that reports the metrics of each run in turn and counts the calls
+func fakeRuns(runs [][]report.Metric, calls *int) func() (string, string, []report.Metric) {
+	return func() (string, string, []report.Metric) {
+		metrics := runs[*calls]
+		*calls++
+		return "fake", "run output\n", metrics
+	}
+}
+
+func metric(name string, value float64) report.Metric {
+	return report.Metric{Section: "memory", Name: name, Value: value, Unit: "MB/s"}
+}
+
+func TestRepeatTestSingleRun(t *testing.T) {
+	for _, repeat := range []int{0, 1} {
+		calls := 0
+		runs := [][]report.Metric{{metric("read", 100)}}
+		method, text, metrics := repeatTest(&params.Config{Repeat: repeat, Language: "en"}, "memory", fakeRuns(runs, &calls))
+		if calls != 1 || method != "fake" || text != "run output\n" || len(metrics) != 1 {
+			t.Fatalf("Repeat %d: calls %d, %q, %q, %v", repeat, calls, method, text, metrics)
+		}
+	}
+}
+
+func TestRepeatTest(t *testing.T) {
+	calls := 0
+	// write 只在第 1、3 次出现，按出现的两次统计
+	runs := [][]report.Metric{
+		{metric("read", 100), metric("write", 10)},
+		{metric("read", 101)},
+		{metric("read", 99), metric("write", 20)},
+	}
+	method, text, metrics := repeatTest(&params.Config{Repeat: 3, Language: "en"}, "memory", fakeRuns(runs, &calls))
+	if calls != 3 || method != "fake" || len(metrics) != 2 || metrics[1].Value != 10 {
+		t.Fatalf("calls %d, %q, %v", calls, method, metrics)
+	}
+	lines := strings.Split(text, "\n")
+	if lines[0] != "run output" || lines[1] != "Statistics of 3 runs:" {
+		t.Fatalf("output = %q", text)
+	}
+	if fields := strings.Fields(lines[3]); fields[0] != "read" || fields[2] != "99.00" || fields[5] != "101.00" {
+		t.Fatalf("read row = %q", lines[3])
+	}
+	if fields := strings.Fields(lines[4]); fields[0] != "write" || fields[2] != "10.00" || fields[3] != "15.00" || fields[5] != "20.00" {
+		t.Fatalf("write row = %q", lines[4])
+	}
+	// read 的变异系数约 1%，低于阈值；write 约 47%
+	if !strings.Contains(text, "Warning: CV above 5% for write, results vary a lot, memory bandwidth contention") {
+		t.Fatalf("output = %q", text)
+	}
+
+	calls = 0
+	runs = [][]report.Metric{nil, nil}
+	_, text, _ = repeatTest(&params.Config{Repeat: 2, Language: "en"}, "cpu", fakeRuns(runs, &calls))
+	if calls != 2 || text != "run output\n" {
+		t.Fatalf("without metrics: calls %d, output %q", calls, text)
+	}
+}
+
+func TestRepeatStatsThresholds(t *testing.T) {
+	// 约 10% 的变异系数：超过 CPU 的阈值，低于磁盘的阈值
+	stats := newRepeatStats()
+	for _, value := range []float64{90, 100, 110} {
+		stats.add([]report.Metric{{Name: "score", Value: value}})
+	}
+	if text := stats.format("zh", "cpu", 3); !strings.Contains(text, "注意: 变异系数超过 5% 的指标 score，结果波动较大，可能存在CPU争抢(steal)") {
+		t.Fatalf("cpu = %q", text)
+	}
+	if text := stats.format("en", "disk", 3); strings.Contains(text, "Warning") {
+		t.Fatalf("disk = %q", text)
+	}
+	stats.add([]report.Metric{{Name: "score", Value: 200}})
+	if text := stats.format("en", "disk", 4); !strings.Contains(text, "storage contention") {
+		t.Fatalf("disk = %q", text)
+	}
+}
@@ -8,21 +8,97 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/oneclickvirt/ecs/internal/cleanup"
+	"github.com/oneclickvirt/ecs/internal/collector"
+	"github.com/oneclickvirt/ecs/internal/contention"
+	"github.com/oneclickvirt/ecs/internal/cpubench"
+	"github.com/oneclickvirt/ecs/internal/diskpath"
+	"github.com/oneclickvirt/ecs/internal/dnsbench"
+	"github.com/oneclickvirt/ecs/internal/httpspeed"
+	"github.com/oneclickvirt/ecs/internal/iperf"
+	"github.com/oneclickvirt/ecs/internal/ipv6check"
+	"github.com/oneclickvirt/ecs/internal/membench"
+	"github.com/oneclickvirt/ecs/internal/memguard"
+	"github.com/oneclickvirt/ecs/internal/natcheck"
+	"github.com/oneclickvirt/ecs/internal/numa"
 	"github.com/oneclickvirt/ecs/internal/params"
+	"github.com/oneclickvirt/ecs/internal/pingstats"
+	"github.com/oneclickvirt/ecs/internal/pmtu"
+	"github.com/oneclickvirt/ecs/internal/report"
+	"github.com/oneclickvirt/ecs/internal/speedlist"
 	"github.com/oneclickvirt/ecs/internal/tests"
 	"github.com/oneclickvirt/ecs/utils"
 	"github.com/oneclickvirt/pingtest/pt"
 	"github.com/oneclickvirt/portchecker/email"
 )
 
-// RunChineseTests runs all tests in Chinese mode
-func RunChineseTests(preCheck utils.NetCheckResult, config *params.Config, wg1, wg2, wg3 *sync.WaitGroup, basicInfo, securityInfo, emailInfo, mediaInfo, ptInfo *string, output *string, tempOutput string, startTime time.Time, outputMutex *sync.Mutex, infoMutex *sync.Mutex) {
+// activeReport collects the structured result of the running session
+var activeReport atomic.Pointer[report.Report]
+
+// RunTests runs all enabled tests in the configured language, recording each section into rep.
+// ctx is checked between sections, a running section always completes; it reports whether ctx stopped
+// the run before the last section
+func RunTests(ctx context.Context, preCheck utils.NetCheckResult, config *params.Config, rep *report.Report, output *string, tempOutput string, startTime time.Time, outputMutex *sync.Mutex) (stopped bool) {
+	var (
+		wg1, wg2, wg3                                         sync.WaitGroup
+		basicInfo, securityInfo, emailInfo, mediaInfo, ptInfo string
+		infoMutex                                             sync.Mutex // 保护并发字符串写入
+		ptStats                                               []*pingstats.Stats
+	)
+	activeReport.Store(rep)
+	defer activeReport.Store(nil)
+	switch config.Language {
+	case "zh":
+		return RunChineseTests(ctx, preCheck, config, &wg1, &wg2, &wg3, &basicInfo, &securityInfo, &emailInfo, &mediaInfo, &ptInfo, &ptStats, output, tempOutput, startTime, outputMutex, &infoMutex)
+	case "en":
+		return RunEnglishTests(ctx, preCheck, config, &wg1, &wg2, &wg3, &basicInfo, &securityInfo, &emailInfo, &mediaInfo, &ptInfo, output, tempOutput, startTime, outputMutex, &infoMutex)
+	default:
+		fmt.Println("Unsupported language")
+	}
+	return false
+}
+
+// recordSection adds the text a test section appended to output to the active report,
+// section carries the structured details of the test, such as the contention observed while it ran
+func recordSection(name, method, before, after string, section report.Section) {
+	text := strings.TrimSpace(utils.StripANSI(strings.TrimPrefix(after, before)))
+	rep := activeReport.Load()
+	if rep == nil || text == "" {
+		return
+	}
+	base := report.NewSection(name, method, text+"\n")
+	section.Name, section.Method, section.Title, section.Output = base.Name, base.Method, base.Title, base.Output
+	rep.Add(section)
+}
+
+// RunChineseTests runs all tests in Chinese mode and reports whether ctx stopped it early
+func RunChineseTests(ctx context.Context, preCheck utils.NetCheckResult, config *params.Config, wg1, wg2, wg3 *sync.WaitGroup, basicInfo, securityInfo, emailInfo, mediaInfo, ptInfo *string, ptStats *[]*pingstats.Stats, output *string, tempOutput string, startTime time.Time, outputMutex *sync.Mutex, infoMutex *sync.Mutex) bool {
+	stop := func() bool {
+		if ctx.Err() == nil {
+			return false
+		}
+		*output = AppendTimeInfo(config, *output, tempOutput, startTime, outputMutex)
+		return true
+	}
 	*output = RunBasicTests(preCheck, config, basicInfo, securityInfo, *output, tempOutput, outputMutex)
+	if stop() {
+		return true
+	}
 	*output = RunCPUTest(config, *output, tempOutput, outputMutex)
+	if stop() {
+		return true
+	}
 	*output = RunMemoryTest(config, *output, tempOutput, outputMutex)
+	if stop() {
+		return true
+	}
 	*output = RunDiskTest(config, *output, tempOutput, outputMutex)
+	if stop() {
+		return true
+	}
 	if config.OnlyIpInfoCheck && !config.BasicStatus && preCheck.Connected && preCheck.StackType != "" && preCheck.StackType != "None" {
 		*output = RunIpInfoCheck(config, *output, tempOutput, outputMutex)
 	}
@@ -30,7 +106,7 @@ func RunChineseTests(preCheck utils.NetCheckResult, config *params.Config, wg1,
 		wg1.Add(1)
 		go func() {
 			defer wg1.Done()
-			result := tests.MediaTest(config.Language)
+			result := tests.MediaTest(config.Language, preCheck.StackType)
 			infoMutex.Lock()
 			*mediaInfo = result
 			infoMutex.Unlock()
@@ -50,9 +126,10 @@ func RunChineseTests(preCheck utils.NetCheckResult, config *params.Config, wg1,
 		wg3.Add(1)
 		go func() {
 			defer wg3.Done()
-			result := pt.PingTest()
+			result, stats := runChinaPing(config)
 			infoMutex.Lock()
 			*ptInfo = result
+			*ptStats = stats
 			infoMutex.Unlock()
 		}()
 	}
@@ -61,21 +138,71 @@ func RunChineseTests(preCheck utils.NetCheckResult, config *params.Config, wg1,
 		*output = RunSecurityTests(config, *securityInfo, *output, tempOutput, outputMutex)
 		*output = RunEmailTests(config, wg2, emailInfo, *output, tempOutput, outputMutex, infoMutex)
 	}
+	if stop() {
+		return true
+	}
 	if runtime.GOOS != "windows" && preCheck.Connected && preCheck.StackType != "" && preCheck.StackType != "None" {
-		*output = RunNetworkTests(config, wg3, ptInfo, *output, tempOutput, outputMutex, infoMutex)
+		*output = RunNetworkTests(config, wg3, ptInfo, ptStats, *output, tempOutput, outputMutex, infoMutex)
+	}
+	if stop() {
+		return true
+	}
+	if config.DNSTestStatus && preCheck.Connected && preCheck.StackType != "" && preCheck.StackType != "None" {
+		*output = RunDNSTests(config, *output, tempOutput, outputMutex)
+	}
+	if stop() {
+		return true
+	}
+	if config.NATTestStatus && preCheck.Connected && preCheck.StackType != "" && preCheck.StackType != "None" {
+		*output = RunNATTests(config, *output, tempOutput, outputMutex)
+	}
+	if stop() {
+		return true
+	}
+	if config.IPv6TestStatus && preCheck.Connected && preCheck.StackType != "" && preCheck.StackType != "None" {
+		*output = RunIPv6Tests(config, *output, tempOutput, outputMutex)
+	}
+	if stop() {
+		return true
 	}
 	if preCheck.Connected && preCheck.StackType != "" && preCheck.StackType != "None" {
 		*output = RunSpeedTests(config, *output, tempOutput, outputMutex)
 	}
+	if stop() {
+		return true
+	}
+	if config.IperfTarget != "" {
+		*output = RunIperfTests(config, *output, tempOutput, outputMutex)
+	}
 	*output = AppendTimeInfo(config, *output, tempOutput, startTime, outputMutex)
+	return false
 }
 
-// RunEnglishTests runs all tests in English mode
-func RunEnglishTests(preCheck utils.NetCheckResult, config *params.Config, wg1, wg2, wg3 *sync.WaitGroup, basicInfo, securityInfo, emailInfo, mediaInfo, ptInfo *string, output *string, tempOutput string, startTime time.Time, outputMutex *sync.Mutex, infoMutex *sync.Mutex) {
+// RunEnglishTests runs all tests in English mode and reports whether ctx stopped it early
+func RunEnglishTests(ctx context.Context, preCheck utils.NetCheckResult, config *params.Config, wg1, wg2, wg3 *sync.WaitGroup, basicInfo, securityInfo, emailInfo, mediaInfo, ptInfo *string, output *string, tempOutput string, startTime time.Time, outputMutex *sync.Mutex, infoMutex *sync.Mutex) bool {
+	stop := func() bool {
+		if ctx.Err() == nil {
+			return false
+		}
+		*output = AppendTimeInfo(config, *output, tempOutput, startTime, outputMutex)
+		return true
+	}
 	*output = RunBasicTests(preCheck, config, basicInfo, securityInfo, *output, tempOutput, outputMutex)
+	if stop() {
+		return true
+	}
 	*output = RunCPUTest(config, *output, tempOutput, outputMutex)
+	if stop() {
+		return true
+	}
 	*output = RunMemoryTest(config, *output, tempOutput, outputMutex)
+	if stop() {
+		return true
+	}
 	*output = RunDiskTest(config, *output, tempOutput, outputMutex)
+	if stop() {
+		return true
+	}
 	if config.OnlyIpInfoCheck && !config.BasicStatus && preCheck.Connected && preCheck.StackType != "" && preCheck.StackType != "None" {
 		*output = RunIpInfoCheck(config, *output, tempOutput, outputMutex)
 	}
@@ -84,7 +211,7 @@ func RunEnglishTests(preCheck utils.NetCheckResult, config *params.Config, wg1,
 			wg1.Add(1)
 			go func() {
 				defer wg1.Done()
-				result := tests.MediaTest(config.Language)
+				result := tests.MediaTest(config.Language, preCheck.StackType)
 				infoMutex.Lock()
 				*mediaInfo = result
 				infoMutex.Unlock()
@@ -103,17 +230,48 @@ func RunEnglishTests(preCheck utils.NetCheckResult, config *params.Config, wg1,
 		*output = RunStreamingTests(config, wg1, mediaInfo, *output, tempOutput, outputMutex, infoMutex)
 		*output = RunSecurityTests(config, *securityInfo, *output, tempOutput, outputMutex)
 		*output = RunEmailTests(config, wg2, emailInfo, *output, tempOutput, outputMutex, infoMutex)
+		if stop() {
+			return true
+		}
 		*output = RunEnglishNetworkTests(config, wg3, ptInfo, *output, tempOutput, outputMutex)
+		if stop() {
+			return true
+		}
+		if config.DNSTestStatus {
+			*output = RunDNSTests(config, *output, tempOutput, outputMutex)
+			if stop() {
+				return true
+			}
+		}
+		if config.NATTestStatus {
+			*output = RunNATTests(config, *output, tempOutput, outputMutex)
+			if stop() {
+				return true
+			}
+		}
+		if config.IPv6TestStatus {
+			*output = RunIPv6Tests(config, *output, tempOutput, outputMutex)
+			if stop() {
+				return true
+			}
+		}
 		*output = RunEnglishSpeedTests(config, *output, tempOutput, outputMutex)
 	}
+	if stop() {
+		return true
+	}
+	if config.IperfTarget != "" {
+		*output = RunIperfTests(config, *output, tempOutput, outputMutex)
+	}
 	*output = AppendTimeInfo(config, *output, tempOutput, startTime, outputMutex)
+	return false
 }
 
 // RunIpInfoCheck performs IP info check
 func RunIpInfoCheck(config *params.Config, output, tempOutput string, outputMutex *sync.Mutex) string {
 	outputMutex.Lock()
 	defer outputMutex.Unlock()
-	return utils.PrintAndCapture(func() {
+	result := utils.PrintAndCapture(func() {
 		var ipinfo string
 		tests.IPV4, tests.IPV6, ipinfo = utils.OnlyBasicsIpInfo(config.Language)
 		if ipinfo != "" {
@@ -125,13 +283,15 @@ func RunIpInfoCheck(config *params.Config, output, tempOutput string, outputMute
 			fmt.Printf("%s", ipinfo)
 		}
 	}, tempOutput, output)
+	recordSection("ipinfo", "", output, result, report.Section{})
+	return result
 }
 
 // RunBasicTests runs basic system tests
 func RunBasicTests(preCheck utils.NetCheckResult, config *params.Config, basicInfo, securityInfo *string, output, tempOutput string, outputMutex *sync.Mutex) string {
 	outputMutex.Lock()
 	defer outputMutex.Unlock()
-	return utils.PrintAndCapture(func() {
+	result := utils.PrintAndCapture(func() {
 		utils.PrintHead(config.Language, config.Width, config.EcsVersion)
 		if config.BasicStatus || config.SecurityTestStatus {
 			if config.BasicStatus {
@@ -164,80 +324,230 @@ func RunBasicTests(preCheck utils.NetCheckResult, config *params.Config, basicIn
 			}
 		}
 	}, tempOutput, output)
+	recordSection("basic", "", output, result, report.Section{})
+	return result
 }
 
 // RunCPUTest runs CPU test
 func RunCPUTest(config *params.Config, output, tempOutput string, outputMutex *sync.Mutex) string {
 	outputMutex.Lock()
 	defer outputMutex.Unlock()
-	return utils.PrintAndCapture(func() {
+	var (
+		realTestMethod string
+		details        report.Section
+	)
+	result := utils.PrintAndCapture(func() {
 		if config.CpuTestStatus {
-			realTestMethod, res := tests.CpuTest(config.Language, config.CpuTestMethod, config.CpuTestThreadMode)
+			var res string
+			monitor := contention.Start()
+			realTestMethod, res, details.Results = repeatTest(config, "cpu", func() (string, string, []report.Metric) {
+				method, text := tests.CpuTest(config.Language, config.CpuTestMethod, config.CpuTestThreadMode)
+				return method, text, libraryMetrics("cpu", text)
+			})
+			if config.CpuDuration > 0 {
+				res += cpubench.SustainedTest(config.Language, config.CpuDuration)
+			}
+			details.Contention = monitor.Stop()
 			if config.Language == "zh" {
 				utils.PrintCenteredTitle(fmt.Sprintf("CPU测试-通过%s测试", realTestMethod), config.Width)
 			} else {
 				utils.PrintCenteredTitle(fmt.Sprintf("CPU-Test--%s-Method", realTestMethod), config.Width)
 			}
 			fmt.Print(res)
+			fmt.Print(details.Contention.Format(config.Language))
 		}
 	}, tempOutput, output)
+	recordSection("cpu", realTestMethod, output, result, details)
+	return result
 }
 
 // RunMemoryTest runs memory test
 func RunMemoryTest(config *params.Config, output, tempOutput string, outputMutex *sync.Mutex) string {
 	outputMutex.Lock()
 	defer outputMutex.Unlock()
-	return utils.PrintAndCapture(func() {
+	var (
+		realTestMethod string
+		details        report.Section
+	)
+	result := utils.PrintAndCapture(func() {
 		if config.MemoryTestStatus {
-			realTestMethod, res := tests.MemoryTest(config.Language, config.MemoryTestMethod)
-			if config.Language == "zh" {
+			var res, latency, numaText string
+			// latency 方法在带宽测试之外追加延迟测试，带宽部分使用 stream
+			bandwidthMethod := config.MemoryTestMethod
+			if bandwidthMethod == "latency" {
+				bandwidthMethod = "stream"
+			}
+			// 按可用内存和 cgroup 限制选择测试方法，避免触发 OOM 导致整个报告丢失
+			decision := memguard.Plan(bandwidthMethod, memguard.Detect())
+			details.MemoryGuard = &decision
+			if decision.Skipped {
+				realTestMethod = "skipped"
+				if config.Language == "zh" {
+					utils.PrintCenteredTitle("内存测试-已跳过", config.Width)
+				} else {
+					utils.PrintCenteredTitle("Memory-Test--Skipped", config.Width)
+				}
+				fmt.Print(decision.Format(config.Language))
+				return
+			}
+			monitor := contention.Start()
+			realTestMethod, res, details.Results = repeatTest(config, "memory", func() (string, string, []report.Metric) {
+				method, text := tests.MemoryTest(config.Language, decision.Method, decision.AllowDD)
+				return method, text, libraryMetrics("memory", text)
+			})
+			if config.MemoryTestMethod == "latency" && decision.LatencyMB > 0 {
+				var levels []membench.Level
+				levels, latency = membench.LatencyTest(config.Language, decision.LatencyMB<<20)
+				details.Results = append(details.Results, membench.Metrics(levels)...)
+			}
+			// 多节点主机额外测试跨节点访问
+			if decision.NUMAMB > 0 {
+				details.NUMA, numaText = numa.Test(config.Language, decision.NUMAMB)
+			}
+			details.Contention = monitor.Stop()
+			switch {
+			case realTestMethod == "skipped" && config.Language == "zh":
+				utils.PrintCenteredTitle("内存测试-已跳过", config.Width)
+			case realTestMethod == "skipped":
+				utils.PrintCenteredTitle("Memory-Test--Skipped", config.Width)
+			case config.Language == "zh":
 				utils.PrintCenteredTitle(fmt.Sprintf("内存测试-通过%s测试", realTestMethod), config.Width)
-			} else {
+			default:
 				utils.PrintCenteredTitle(fmt.Sprintf("Memory-Test--%s-Method", realTestMethod), config.Width)
 			}
+			fmt.Print(decision.Format(config.Language))
 			fmt.Print(res)
+			if latency != "" {
+				if config.Language == "zh" {
+					utils.PrintCenteredTitle("内存延迟测试", config.Width)
+				} else {
+					utils.PrintCenteredTitle("Memory-Latency-Test", config.Width)
+				}
+				fmt.Print(latency)
+				realTestMethod += "+latency"
+			}
+			if numaText != "" {
+				if config.Language == "zh" {
+					utils.PrintCenteredTitle("NUMA内存测试", config.Width)
+				} else {
+					utils.PrintCenteredTitle("NUMA-Memory-Test", config.Width)
+				}
+				fmt.Print(numaText)
+			}
+			fmt.Print(details.Contention.Format(config.Language))
 		}
 	}, tempOutput, output)
+	recordSection("memory", realTestMethod, output, result, details)
+	return result
 }
 
 // RunDiskTest runs disk test
 func RunDiskTest(config *params.Config, output, tempOutput string, outputMutex *sync.Mutex) string {
 	outputMutex.Lock()
 	defer outputMutex.Unlock()
-	return utils.PrintAndCapture(func() {
-		if config.DiskTestStatus && config.AutoChangeDiskMethod {
-			realTestMethod, res := tests.DiskTest(config.Language, config.DiskTestMethod, config.DiskTestPath, config.DiskMultiCheck, config.AutoChangeDiskMethod)
+	var (
+		realTestMethod string
+		details        report.Section
+	)
+	result := utils.PrintAndCapture(func() {
+		if !config.DiskTestStatus {
+			return
+		}
+		// -diskp 可用逗号分隔多个路径，逐个测试后给出对比表
+		paths := diskpath.Split(config.DiskTestPath)
+		if len(paths) == 0 {
+			paths = []string{""}
+		}
+		// 预检只读挂载、可写性、剩余空间与内存文件系统，默认路径由测试库自行回退，只提示不跳过
+		var notes strings.Builder
+		required := diskRequiredBytes(config)
+		monitor := contention.Start()
+		runs := make([]diskRun, 0, len(paths))
+		for _, path := range paths {
+			var mount diskpath.Info
+			if path == "" {
+				mount = diskpath.Lookup(diskpath.Default())
+				mount.Preflight(required)
+				notes.WriteString(mount.FormatIssues(config.Language, false))
+			} else {
+				mount = diskpath.Lookup(path)
+				usable := mount.Preflight(required)
+				details.Mounts = append(details.Mounts, mount)
+				notes.WriteString(mount.FormatIssues(config.Language, !usable))
+				if !usable {
+					continue
+				}
+			}
+			runs = append(runs, testDiskPath(config, path, mount))
+		}
+		details.Contention = monitor.Stop()
+		if len(runs) == 0 {
+			realTestMethod = "skipped"
 			if config.Language == "zh" {
-				utils.PrintCenteredTitle(fmt.Sprintf("硬盘测试-通过%s测试", realTestMethod), config.Width)
+				utils.PrintCenteredTitle("硬盘测试-已跳过", config.Width)
 			} else {
-				utils.PrintCenteredTitle(fmt.Sprintf("Disk-Test--%s-Method", realTestMethod), config.Width)
+				utils.PrintCenteredTitle("Disk-Test--Skipped", config.Width)
 			}
-			fmt.Print(res)
-		} else if config.DiskTestStatus && !config.AutoChangeDiskMethod {
+			fmt.Print(notes.String())
+			return
+		}
+		realTestMethod = runs[0].method()
+		for _, run := range runs {
+			details.Results = append(details.Results, run.results...)
+			if run.fio != nil {
+				details.Fio = append(details.Fio, run.fio)
+			}
+			if run.latency != nil {
+				details.DiskLatency = append(details.DiskLatency, run.latency)
+			}
+			details.Devices = append(details.Devices, run.device)
+		}
+		for i, part := range runs[0].parts {
+			if config.Language == "zh" {
+				utils.PrintCenteredTitle(fmt.Sprintf("硬盘测试-通过%s测试", part.method), config.Width)
+			} else {
+				utils.PrintCenteredTitle(fmt.Sprintf("Disk-Test--%s-Method", part.method), config.Width)
+			}
+			if i == 0 {
+				fmt.Print(notes.String())
+			}
+			for _, run := range runs {
+				fmt.Print(run.header(config.Language, i == 0))
+				if i < len(run.parts) {
+					fmt.Print(run.parts[i].text)
+				}
+			}
+		}
+		if config.DiskLatency {
 			if config.Language == "zh" {
-				utils.PrintCenteredTitle(fmt.Sprintf("硬盘测试-通过%s测试", "dd"), config.Width)
-				_, res := tests.DiskTest(config.Language, "dd", config.DiskTestPath, config.DiskMultiCheck, config.AutoChangeDiskMethod)
-				fmt.Print(res)
-				utils.PrintCenteredTitle(fmt.Sprintf("硬盘测试-通过%s测试", "fio"), config.Width)
-				_, res = tests.DiskTest(config.Language, "fio", config.DiskTestPath, config.DiskMultiCheck, config.AutoChangeDiskMethod)
-				fmt.Print(res)
+				utils.PrintCenteredTitle("硬盘延迟测试", config.Width)
 			} else {
-				utils.PrintCenteredTitle(fmt.Sprintf("Disk-Test--%s-Method", "dd"), config.Width)
-				_, res := tests.DiskTest(config.Language, "dd", config.DiskTestPath, config.DiskMultiCheck, config.AutoChangeDiskMethod)
-				fmt.Print(res)
-				utils.PrintCenteredTitle(fmt.Sprintf("Disk-Test--%s-Method", "fio"), config.Width)
-				_, res = tests.DiskTest(config.Language, "fio", config.DiskTestPath, config.DiskMultiCheck, config.AutoChangeDiskMethod)
-				fmt.Print(res)
+				utils.PrintCenteredTitle("Disk-Latency-Test", config.Width)
+			}
+			for _, run := range runs {
+				fmt.Print(run.header(config.Language, false))
+				fmt.Print(run.latencyText)
 			}
 		}
+		if len(runs) > 1 {
+			if config.Language == "zh" {
+				utils.PrintCenteredTitle("硬盘测试-多路径对比", config.Width)
+			} else {
+				utils.PrintCenteredTitle("Disk-Test--Path-Comparison", config.Width)
+			}
+			fmt.Print(compareDiskRuns(config, runs))
+		}
+		fmt.Print(details.Contention.Format(config.Language))
 	}, tempOutput, output)
+	recordSection("disk", realTestMethod, output, result, details)
+	return result
 }
 
 // RunStreamingTests runs platform unlock tests
 func RunStreamingTests(config *params.Config, wg1 *sync.WaitGroup, mediaInfo *string, output, tempOutput string, outputMutex *sync.Mutex, infoMutex *sync.Mutex) string {
 	outputMutex.Lock()
 	defer outputMutex.Unlock()
-	return utils.PrintAndCapture(func() {
+	result := utils.PrintAndCapture(func() {
 		if config.UtTestStatus && (config.Language == "zh" && !config.OnlyChinaTest || config.Language == "en") {
 			wg1.Wait()
 			if config.Language == "zh" {
@@ -251,13 +561,15 @@ func RunStreamingTests(config *params.Config, wg1 *sync.WaitGroup, mediaInfo *st
 			fmt.Printf("%s", info)
 		}
 	}, tempOutput, output)
+	recordSection("unlock", "", output, result, report.Section{})
+	return result
 }
 
 // RunSecurityTests runs security tests
 func RunSecurityTests(config *params.Config, securityInfo, output, tempOutput string, outputMutex *sync.Mutex) string {
 	outputMutex.Lock()
 	defer outputMutex.Unlock()
-	return utils.PrintAndCapture(func() {
+	result := utils.PrintAndCapture(func() {
 		if config.SecurityTestStatus {
 			if config.Language == "zh" {
 				utils.PrintCenteredTitle("IP质量检测", config.Width)
@@ -267,13 +579,15 @@ func RunSecurityTests(config *params.Config, securityInfo, output, tempOutput st
 			fmt.Printf("%s", securityInfo)
 		}
 	}, tempOutput, output)
+	recordSection("security", "", output, result, report.Section{})
+	return result
 }
 
 // RunEmailTests runs email port tests
 func RunEmailTests(config *params.Config, wg2 *sync.WaitGroup, emailInfo *string, output, tempOutput string, outputMutex *sync.Mutex, infoMutex *sync.Mutex) string {
 	outputMutex.Lock()
 	defer outputMutex.Unlock()
-	return utils.PrintAndCapture(func() {
+	result := utils.PrintAndCapture(func() {
 		if config.EmailTestStatus {
 			wg2.Wait()
 			if config.Language == "zh" {
@@ -287,13 +601,90 @@ func RunEmailTests(config *params.Config, wg2 *sync.WaitGroup, emailInfo *string
 			fmt.Println(info)
 		}
 	}, tempOutput, output)
+	recordSection("email", "", output, result, report.Section{})
+	return result
+}
+
+// pingOptions returns the probe settings of -ping-count and -ping-interval
+func pingOptions(config *params.Config) pingstats.Options {
+	opts := pingstats.DefaultOptions()
+	opts.Count = config.PingCount
+	opts.Interval = config.PingInterval
+	return opts
+}
+
+// runPing measures targets and returns the table sorted by latency within each group
+func runPing(config *params.Config, targets []pingstats.Target, concurrency int) (string, []*pingstats.Stats) {
+	stats := pingstats.Run(targets, pingOptions(config), concurrency)
+	pingstats.Sort(stats)
+	return pingstats.Format(stats, config.Language), stats
+}
+
+// runChinaPing pings one target per province of the three operators
+func runChinaPing(config *params.Config) (string, []*pingstats.Stats) {
+	targets := pingstats.ChinaTargets()
+	if len(targets) == 0 {
+		// 目标列表下载失败时使用原有的测试，其中包含备用的测速节点列表
+		return pt.PingTest(), nil
+	}
+	return runPing(config, targets, 30)
+}
+
+// runExtraPing prints the Telegram DC, website and -ping-targets tables
+func runExtraPing(config *params.Config) []*pingstats.Stats {
+	var collected []*pingstats.Stats
+	if config.TgdcTestStatus {
+		text, stats := runPing(config, pingstats.TelegramTargets(), 10)
+		fmt.Print(text)
+		collected = append(collected, stats...)
+	}
+	if config.WebTestStatus {
+		text, stats := runPing(config, pingstats.WebsiteTargets(), 10)
+		fmt.Print(text)
+		collected = append(collected, stats...)
+	}
+	if config.PingTargets != "" {
+		targets, invalid, err := pingstats.LoadTargets(config.PingTargets)
+		warnInvalid("ping target", invalid)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[WARN] ping target list: %v\n", err)
+		} else if len(targets) > 0 {
+			text, stats := runPing(config, targets, 10)
+			fmt.Print(text)
+			collected = append(collected, stats...)
+		}
+	}
+	return collected
+}
+
+// extraPingEnabled reports whether any table of runExtraPing is enabled
+func extraPingEnabled(config *params.Config) bool {
+	return config.TgdcTestStatus || config.WebTestStatus || config.PingTargets != ""
+}
+
+// runMTU discovers the path MTU to the -mtu-targets hosts or the built-in targets and prints the table
+func runMTU(config *params.Config) []*pmtu.Result {
+	targets := pmtu.DefaultTargets
+	if config.MTUTargets != "" {
+		custom, invalid := pmtu.ParseList(config.MTUTargets)
+		warnInvalid("MTU target", invalid)
+		// 与 ValidateParams 一致，没有可用的自定义目标时检测内置目标
+		if len(custom) > 0 {
+			targets = custom
+		}
+	}
+	results := pmtu.Run(targets)
+	fmt.Print(pmtu.Format(results, config.Language))
+	return results
 }
 
 // RunNetworkTests runs network tests (Chinese mode)
-func RunNetworkTests(config *params.Config, wg3 *sync.WaitGroup, ptInfo *string, output, tempOutput string, outputMutex *sync.Mutex, infoMutex *sync.Mutex) string {
+func RunNetworkTests(config *params.Config, wg3 *sync.WaitGroup, ptInfo *string, ptStats *[]*pingstats.Stats, output, tempOutput string, outputMutex *sync.Mutex, infoMutex *sync.Mutex) string {
 	outputMutex.Lock()
 	defer outputMutex.Unlock()
-	return utils.PrintAndCapture(func() {
+	var collected []*pingstats.Stats
+	var mtu []*pmtu.Result
+	result := utils.PrintAndCapture(func() {
 		if config.BacktraceStatus && !config.OnlyChinaTest {
 			utils.PrintCenteredTitle("上游及回程线路检测", config.Width)
 			tests.UpstreamsCheck()
@@ -302,45 +693,77 @@ func RunNetworkTests(config *params.Config, wg3 *sync.WaitGroup, ptInfo *string,
 			utils.PrintCenteredTitle("三网回程路由检测", config.Width)
 			tests.NextTrace3Check(config.Language, config.Nt3Location, config.Nt3CheckType)
 		}
-		infoMutex.Lock()
-		info := *ptInfo
-		infoMutex.Unlock()
-		if config.OnlyChinaTest && info != "" {
+		var info string
+		if config.OnlyChinaTest || config.PingTestStatus {
 			wg3.Wait()
+			infoMutex.Lock()
+			info = strings.TrimRight(*ptInfo, "\n")
+			collected = *ptStats
+			infoMutex.Unlock()
+		}
+		if config.OnlyChinaTest && info != "" {
 			utils.PrintCenteredTitle("PING值检测", config.Width)
 			fmt.Println(info)
 		}
 		if config.PingTestStatus && info != "" {
-			wg3.Wait()
 			utils.PrintCenteredTitle("PING值检测", config.Width)
 			fmt.Println(info)
-			if config.TgdcTestStatus {
-				fmt.Println(pt.TelegramDCTest())
-			}
-			if config.WebTestStatus {
-				fmt.Println(pt.WebsiteTest())
-			}
+			collected = append(collected, runExtraPing(config)...)
 		}
-		if !config.OnlyChinaTest && !config.PingTestStatus && (config.TgdcTestStatus || config.WebTestStatus) {
+		// 三网PING没有结果时仍然输出其余的PING测试
+		if !config.OnlyChinaTest && !(config.PingTestStatus && info != "") && extraPingEnabled(config) {
 			utils.PrintCenteredTitle("PING值检测", config.Width)
-			if config.TgdcTestStatus {
-				fmt.Println(pt.TelegramDCTest())
-			}
-			if config.WebTestStatus {
-				fmt.Println(pt.WebsiteTest())
-			}
+			collected = append(collected, runExtraPing(config)...)
+		}
+		if config.MTUTestStatus {
+			utils.PrintCenteredTitle("MTU检测", config.Width)
+			mtu = runMTU(config)
 		}
 	}, tempOutput, output)
+	recordSection("network", "", output, result, report.Section{Ping: collected, MTU: mtu})
+	return result
+}
+
+// runCustomSpeedTests tests the servers of -speed-servers and -speed-server and the URLs of -speed-url,
+// it returns false when none were given so the built-in operator lists are used
+func runCustomSpeedTests(config *params.Config, details *report.Section) bool {
+	servers, err := speedlist.Load(config.SpeedServers, config.SpeedServer)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[WARN] speed server list: %v\n", err)
+	}
+	endpoints, invalid := httpspeed.ParseList(config.SpeedURL)
+	warnInvalid("speed test URL", invalid)
+	if len(servers) == 0 && len(endpoints) == 0 {
+		return false
+	}
+	details.SpeedServers = speedlist.Run(servers)
+	details.HTTPSpeed = httpspeed.Run(endpoints, config.SpeedStreams, config.Language)
+	return true
+}
+
+// warnInvalid prints the skipped items of a list flag, runs started by serve and schedule do not pass ValidateParams
+func warnInvalid(kind string, invalid []string) {
+	for _, item := range invalid {
+		fmt.Fprintf(os.Stderr, "[WARN] invalid %s %q, skipped\n", kind, item)
+	}
 }
 
 // RunSpeedTests runs speed tests (Chinese mode)
 func RunSpeedTests(config *params.Config, output, tempOutput string, outputMutex *sync.Mutex) string {
 	outputMutex.Lock()
 	defer outputMutex.Unlock()
-	return utils.PrintAndCapture(func() {
+	var (
+		details report.Section
+		builtin bool
+	)
+	result := utils.PrintAndCapture(func() {
 		if config.SpeedTestStatus {
 			utils.PrintCenteredTitle("就近节点测速", config.Width)
 			tests.ShowHead(config.Language)
+			if runCustomSpeedTests(config, &details) {
+				return
+			}
+			builtin = true
 			if config.Choice == "1" || !config.MenuMode {
 				tests.NearbySP()
 				tests.CustomSP("net", "global", 2, config.Language)
@@ -354,37 +777,154 @@ func RunSpeedTests(config *params.Config, output, tempOutput string, outputMutex
 			}
 		}
 	}, tempOutput, output)
+	// 测速库直接打印表格，只能从输出中取得结果
+	if builtin {
+		details.Results = libraryMetrics("speed", strings.TrimPrefix(result, output))
+	}
+	recordSection("speed", "", output, result, details)
+	return result
 }
 
 // RunEnglishNetworkTests runs network tests (English mode)
 func RunEnglishNetworkTests(config *params.Config, wg3 *sync.WaitGroup, ptInfo *string, output, tempOutput string, outputMutex *sync.Mutex) string {
 	outputMutex.Lock()
 	defer outputMutex.Unlock()
-	return utils.PrintAndCapture(func() {
-		if config.TgdcTestStatus || config.WebTestStatus {
+	var collected []*pingstats.Stats
+	var mtu []*pmtu.Result
+	result := utils.PrintAndCapture(func() {
+		if extraPingEnabled(config) {
 			utils.PrintCenteredTitle("PING-Test", config.Width)
-			if config.TgdcTestStatus {
-				fmt.Println(pt.TelegramDCTest())
-			}
-			if config.WebTestStatus {
-				fmt.Println(pt.WebsiteTest())
-			}
+			collected = runExtraPing(config)
+		}
+		if config.MTUTestStatus {
+			utils.PrintCenteredTitle("MTU-Test", config.Width)
+			mtu = runMTU(config)
 		}
 	}, tempOutput, output)
+	recordSection("network", "", output, result, report.Section{Ping: collected, MTU: mtu})
+	return result
 }
 
 // RunEnglishSpeedTests runs speed tests (English mode)
 func RunEnglishSpeedTests(config *params.Config, output, tempOutput string, outputMutex *sync.Mutex) string {
 	outputMutex.Lock()
 	defer outputMutex.Unlock()
-	return utils.PrintAndCapture(func() {
+	var (
+		details report.Section
+		builtin bool
+	)
+	result := utils.PrintAndCapture(func() {
 		if config.SpeedTestStatus {
 			utils.PrintCenteredTitle("Speed-Test", config.Width)
 			tests.ShowHead(config.Language)
+			if runCustomSpeedTests(config, &details) {
+				return
+			}
+			builtin = true
 			tests.NearbySP()
 			tests.CustomSP("net", "global", -1, config.Language)
 		}
 	}, tempOutput, output)
+	// 测速库直接打印表格，只能从输出中取得结果
+	if builtin {
+		details.Results = libraryMetrics("speed", strings.TrimPrefix(result, output))
+	}
+	recordSection("speed", "", output, result, details)
+	return result
+}
+
+// RunDNSTests benchmarks the system resolver and the public or -dns-servers resolvers
+func RunDNSTests(config *params.Config, output, tempOutput string, outputMutex *sync.Mutex) string {
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+	var res *dnsbench.Result
+	result := utils.PrintAndCapture(func() {
+		if config.Language == "zh" {
+			utils.PrintCenteredTitle("DNS解析测试", config.Width)
+		} else {
+			utils.PrintCenteredTitle("DNS-Test", config.Width)
+		}
+		resolvers := dnsbench.PublicResolvers
+		if config.DNSServers != "" {
+			custom, invalid := dnsbench.ParseList(config.DNSServers)
+			warnInvalid("DNS server", invalid)
+			// 与 ValidateParams 一致，没有可用的自定义服务器时测试公共解析器
+			if len(custom) > 0 {
+				resolvers = custom
+			}
+		}
+		res = dnsbench.Run(append(dnsbench.SystemResolvers(), resolvers...))
+		fmt.Print(dnsbench.Format(res, config.Language))
+	}, tempOutput, output)
+	recordSection("dns", "", output, result, report.Section{DNS: res})
+	return result
+}
+
+// RunNATTests detects the NAT behaviour of both stacks with the -stun-servers or built-in STUN servers
+func RunNATTests(config *params.Config, output, tempOutput string, outputMutex *sync.Mutex) string {
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+	var res *natcheck.Result
+	result := utils.PrintAndCapture(func() {
+		if config.Language == "zh" {
+			utils.PrintCenteredTitle("NAT检测", config.Width)
+		} else {
+			utils.PrintCenteredTitle("NAT-Test", config.Width)
+		}
+		servers, invalid := natcheck.ParseServers(config.STUNServers)
+		warnInvalid("STUN server", invalid)
+		res = natcheck.Run(servers)
+		fmt.Print(natcheck.Format(res, config.Language))
+	}, tempOutput, output)
+	recordSection("nat", "", output, result, report.Section{NAT: res})
+	return result
+}
+
+// RunIPv6Tests reports the IPv6 addresses, whether the /64 is routed and the IPv4 vs IPv6 latency of well-known targets
+func RunIPv6Tests(config *params.Config, output, tempOutput string, outputMutex *sync.Mutex) string {
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+	var res *ipv6check.Result
+	result := utils.PrintAndCapture(func() {
+		if config.Language == "zh" {
+			utils.PrintCenteredTitle("IPv6检测", config.Width)
+		} else {
+			utils.PrintCenteredTitle("IPv6-Test", config.Width)
+		}
+		res = ipv6check.Run(pingOptions(config))
+		fmt.Print(ipv6check.Format(res, config.Language))
+	}, tempOutput, output)
+	recordSection("ipv6", "", output, result, report.Section{IPv6: res})
+	return result
+}
+
+// RunIperfTests runs the TCP, and optionally UDP, throughput test against the -iperf server
+func RunIperfTests(config *params.Config, output, tempOutput string, outputMutex *sync.Mutex) string {
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+	var res []*iperf.Result
+	result := utils.PrintAndCapture(func() {
+		if config.Language == "zh" {
+			utils.PrintCenteredTitle("iPerf3测试", config.Width)
+		} else {
+			utils.PrintCenteredTitle("iPerf3-Test", config.Width)
+		}
+		bitrate, err := iperf.ParseBitrate(config.IperfBitrate)
+		if err != nil {
+			// 未经 ValidateParams 的配置也不能以 0 表示的不限速发送 UDP
+			bitrate = 100e6
+		}
+		var text string
+		text, res = iperf.Test(config.Language, iperf.Options{
+			Address:  config.IperfTarget,
+			Parallel: config.IperfParallel,
+			Duration: config.IperfTime,
+			Bitrate:  bitrate,
+		}, config.IperfUDP)
+		fmt.Print(text)
+	}, tempOutput, output)
+	recordSection("iperf", "", output, result, report.Section{Iperf: res})
+	return result
 }
 
 // AppendTimeInfo appends timing information
@@ -413,6 +953,8 @@ func AppendTimeInfo(config *params.Config, output, tempOutput string, startTime
 func HandleSignalInterrupt(sig chan os.Signal, config *params.Config, startTime *time.Time, output *string, tempOutput string, uploadDone chan bool, outputMutex *sync.Mutex) {
 	select {
 	case <-sig:
+		// 先删除测试中途留下的临时文件，后续上传可能耗时较长
+		cleanup.Run()
 		if !config.Finish {
 			endTime := time.Now()
 			duration := endTime.Sub(*startTime)
@@ -438,7 +980,18 @@ func HandleSignalInterrupt(sig chan os.Signal, config *params.Config, startTime
 				httpURL  string
 				httpsURL string
 			}, 1)
-			if config.EnableUpload {
+			if config.ReportTo != "" {
+				// 中断时也把已完成部分的结构化结果发送到收集端
+				if rep := activeReport.Load(); rep != nil {
+					rep.Finish()
+					HandleReportResults(config, finalOutput, rep)
+				}
+				if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+					fmt.Println("Press Enter to exit...")
+					fmt.Scanln()
+				}
+				os.Exit(0)
+			} else if config.EnableUpload {
 				// 使用context来控制上传goroutine
 				uploadCtx, uploadCancel := context.WithTimeout(context.Background(), 30*time.Second)
 				defer uploadCancel()
@@ -509,3 +1062,22 @@ func HandleUploadResults(config *params.Config, output string) {
 		}
 	}
 }
+
+// HandleReportResults saves the result locally and sends the structured report to the collector given by -report-to
+func HandleReportResults(config *params.Config, output string, rep *report.Report) {
+	utils.ProcessAndUpload(output, config.FilePath, false)
+	id, err := collector.Submit(config.ReportTo, config.ReportToken, rep)
+	if err != nil {
+		if config.Language == "en" {
+			fmt.Printf("Failed to send result to collector: %v\n", err)
+		} else {
+			fmt.Printf("发送结果到收集端失败: %v\n", err)
+		}
+		return
+	}
+	if config.Language == "en" {
+		fmt.Printf("Result sent to collector, ID: %s\n", id)
+	} else {
+		fmt.Printf("结果已发送到收集端, ID: %s\n", id)
+	}
+}
@@ -0,0 +1,53 @@
+package schedule
+
+import (
+	"fmt"
+
+	"github.com/oneclickvirt/ecs/internal/report"
+)
+
+// minBaselineRuns is the number of earlier runs needed before a metric is judged
+const minBaselineRuns = 3
+
+// Alert describes a metric that got worse than its rolling baseline
+type Alert struct {
+	Metric   report.Metric `json:"metric"`
+	Baseline float64       `json:"baseline"`
+	Change   float64       `json:"change_percent"`
+}
+
+func (a Alert) String() string {
+	return fmt.Sprintf("%s: %.2f %s, baseline %.2f %s (%+.1f%%)",
+		a.Metric.Key(), a.Metric.Value, a.Metric.Unit, a.Baseline, a.Metric.Unit, a.Change)
+}
+
+// CheckBaseline compares the metrics of current with the median of the same metric in previous runs,
+// returning an alert for every metric that degraded by at least threshold percent
+func CheckBaseline(current *report.Report, previous []*report.Report, threshold float64) []Alert {
+	history := make(map[string][]float64)
+	for _, rep := range previous {
+		for _, metric := range rep.Metrics() {
+			history[metric.Key()] = append(history[metric.Key()], metric.Value)
+		}
+	}
+	var alerts []Alert
+	for _, metric := range current.Metrics() {
+		values := history[metric.Key()]
+		if len(values) < minBaselineRuns {
+			continue
+		}
+		baseline := report.Summarize(values).Median
+		if baseline == 0 {
+			continue
+		}
+		change := (metric.Value - baseline) / baseline * 100
+		degraded := change <= -threshold
+		if metric.LowerIsBetter {
+			degraded = change >= threshold
+		}
+		if degraded {
+			alerts = append(alerts, Alert{Metric: metric, Baseline: baseline, Change: change})
+		}
+	}
+	return alerts
+}
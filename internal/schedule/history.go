@@ -0,0 +1,62 @@
+package schedule
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/oneclickvirt/ecs/internal/report"
+)
+
+// History stores the report of every scheduled run as a JSON file in dir
+type History struct {
+	dir string
+}
+
+// OpenHistory creates dir if needed
+func OpenHistory(dir string) (*History, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &History{dir: dir}, nil
+}
+
+// Save writes rep to a file named after its start time and returns the path
+func (h *History) Save(rep *report.Report) (string, error) {
+	data, err := json.MarshalIndent(rep.Clone(), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(h.dir, rep.StartTime.Format("20060102-150405")+".json")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Recent returns up to n of the latest reports, oldest first
+func (h *History) Recent(n int) ([]*report.Report, error) {
+	files, err := filepath.Glob(filepath.Join(h.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	// 文件名即开始时间，按名称排序即按时间排序
+	sort.Strings(files)
+	if len(files) > n {
+		files = files[len(files)-n:]
+	}
+	reports := make([]*report.Report, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		rep := &report.Report{}
+		if err := json.Unmarshal(data, rep); err != nil {
+			continue
+		}
+		reports = append(reports, rep)
+	}
+	return reports, nil
+}
@@ -0,0 +1,80 @@
+package schedule
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Notification is sent when a scheduled run degrades below its baseline
+type Notification struct {
+	Hostname  string    `json:"hostname"`
+	StartTime time.Time `json:"start_time"`
+	Alerts    []Alert   `json:"alerts"`
+	Text      string    `json:"text"`
+}
+
+// Notifier delivers notifications
+type Notifier interface {
+	Notify(n Notification) error
+}
+
+// NewNotifier returns a webhook notifier when url is set, otherwise one that prints to stderr
+func NewNotifier(url string) Notifier {
+	if url == "" {
+		return writerNotifier{w: os.Stderr}
+	}
+	return &webhookNotifier{
+		url:    url,
+		client: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+type writerNotifier struct {
+	w io.Writer
+}
+
+func (n writerNotifier) Notify(notification Notification) error {
+	_, err := fmt.Fprintln(n.w, notification.Text)
+	return err
+}
+
+// webhookNotifier posts the notification as JSON, the text field suits most chat webhooks
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (n *webhookNotifier) Notify(notification Notification) error {
+	data, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	resp, err := n.client.Post(n.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+func newNotification(hostname string, startTime time.Time, alerts []Alert) Notification {
+	lines := []string{fmt.Sprintf("goecs: %s degraded in run at %s", hostname, startTime.Format("2006-01-02 15:04:05"))}
+	for _, alert := range alerts {
+		lines = append(lines, "  "+alert.String())
+	}
+	return Notification{
+		Hostname:  hostname,
+		StartTime: startTime,
+		Alerts:    alerts,
+		Text:      strings.Join(lines, "\n"),
+	}
+}
@@ -0,0 +1,179 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/oneclickvirt/ecs/internal/menu"
+	"github.com/oneclickvirt/ecs/internal/params"
+	"github.com/oneclickvirt/ecs/internal/report"
+	"github.com/oneclickvirt/ecs/internal/runner"
+	"github.com/oneclickvirt/ecs/utils"
+)
+
+// scheduler runs the selected preset periodically and checks each result against the history
+type scheduler struct {
+	version      string
+	preset       string
+	args         []string
+	language     string
+	history      *History
+	baselineRuns int
+	threshold    float64
+	notifier     Notifier
+}
+
+// Main parses the schedule subcommand flags and runs benchmarks in the foreground until interrupted,
+// arguments after the schedule flags (or after "--") are passed to every run as goecs flags
+func Main(args []string, version string) error {
+	scheduleFlag := flag.NewFlagSet("schedule", flag.ContinueOnError)
+	interval := scheduleFlag.Duration("every", 0, "Run at a fixed interval, e.g., -every 6h")
+	cronExpr := scheduleFlag.String("cron", "", "Run on a cron expression (minute hour day month weekday), e.g., -cron \"0 */6 * * *\"")
+	jitter := scheduleFlag.Duration("jitter", 5*time.Minute, "Add a random delay up to this duration before each run")
+	preset := scheduleFlag.String("preset", "minimal", "Set the tests to run (supported: full, minimal, standard, network-focused, unlock-focused, network, unlock, hardware, ip-quality, route)")
+	historyDir := scheduleFlag.String("history", "goecs-history", "Directory to keep the result of every run")
+	baselineRuns := scheduleFlag.Int("baseline", 5, "Number of earlier runs forming the rolling baseline, at least 3")
+	threshold := scheduleFlag.Float64("threshold", 20, "Alert when a metric is this many percent worse than the baseline")
+	notifyURL := scheduleFlag.String("notify", "", "Webhook URL receiving alerts as JSON, alerts are printed to stderr when empty")
+	if err := scheduleFlag.Parse(args); err != nil {
+		return err
+	}
+	var sched Schedule
+	switch {
+	case *interval > 0 && *cronExpr != "":
+		return errors.New("use either -every or -cron, not both")
+	case *interval > 0:
+		sched = every(*interval)
+	case *cronExpr != "":
+		var err error
+		if sched, err = ParseCron(*cronExpr); err != nil {
+			return err
+		}
+	default:
+		return errors.New("one of -every or -cron is required")
+	}
+	if !menu.IsPreset(*preset) {
+		return fmt.Errorf("unknown preset %q", *preset)
+	}
+	if *baselineRuns < minBaselineRuns {
+		// 少于 minBaselineRuns 次历史结果的指标不会比较，告警永远不会触发
+		return fmt.Errorf("-baseline must be at least %d", minBaselineRuns)
+	}
+	if *threshold <= 0 || *jitter < 0 {
+		return errors.New("-threshold must be positive, -jitter must not be negative")
+	}
+	// 先校验传给每次测试的参数，避免等到首次运行才报错
+	config := params.NewConfig(version)
+	config.GoecsFlag.SetOutput(io.Discard)
+	if err := config.ParseFlags(scheduleFlag.Args()); err != nil {
+		return fmt.Errorf("invalid goecs flags: %v", err)
+	}
+	history, err := OpenHistory(*historyDir)
+	if err != nil {
+		return err
+	}
+	s := &scheduler{
+		version:      version,
+		preset:       *preset,
+		args:         scheduleFlag.Args(),
+		language:     config.Language,
+		history:      history,
+		baselineRuns: *baselineRuns,
+		threshold:    *threshold,
+		notifier:     NewNotifier(*notifyURL),
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		// 恢复默认信号处理，再次按下 Ctrl+C 可立即退出
+		stop()
+	}()
+	return s.loop(ctx, sched, *jitter)
+}
+
+func (s *scheduler) loop(ctx context.Context, sched Schedule, jitter time.Duration) error {
+	for {
+		next := sched.Next(time.Now())
+		if next.IsZero() {
+			return errors.New("schedule has no future activation")
+		}
+		if jitter > 0 {
+			next = next.Add(rand.N(jitter))
+		}
+		s.printf("下次测试时间: %s\n", "Next run at: %s\n", next.Format("2006-01-02 15:04:05"))
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+		if err := s.runOnce(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			s.printf("本次测试失败: %v\n", "Run failed: %v\n", err)
+		}
+	}
+}
+
+// runOnce executes one run, stores it and notifies when it falls below the baseline
+func (s *scheduler) runOnce(ctx context.Context) error {
+	config := params.NewConfig(s.version)
+	config.GoecsFlag.SetOutput(io.Discard)
+	if err := config.ParseFlags(s.args); err != nil {
+		return err
+	}
+	preCheck := utils.CheckPublicAccess(3 * time.Second)
+	if err := menu.ApplyPreset(s.preset, preCheck, config); err != nil {
+		return err
+	}
+	config.HandleLanguageSpecificSettings()
+	var (
+		output      string
+		outputMutex sync.Mutex
+	)
+	startTime := time.Now()
+	rep := report.New(config.EcsVersion, config.Language, startTime)
+	runner.RunTests(ctx, preCheck, config, rep, &output, "", startTime, &outputMutex)
+	rep.Finish()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	previous, err := s.history.Recent(s.baselineRuns)
+	if err != nil {
+		return err
+	}
+	path, err := s.history.Save(rep)
+	if err != nil {
+		return err
+	}
+	s.printf("测试结果已保存到 %s\n", "Result saved to %s\n", path)
+	if alerts := CheckBaseline(rep, previous, s.threshold); len(alerts) > 0 {
+		if err := s.notifier.Notify(newNotification(rep.Hostname, startTime, alerts)); err != nil {
+			s.printf("发送告警失败: %v\n", "Failed to send alert: %v\n", err)
+		}
+	}
+	if config.ReportTo != "" {
+		runner.HandleReportResults(config, output, rep)
+	}
+	return nil
+}
+
+func (s *scheduler) printf(zh, en string, args ...interface{}) {
+	if s.language == "en" {
+		fmt.Printf(en, args...)
+	} else {
+		fmt.Printf(zh, args...)
+	}
+}
@@ -0,0 +1,68 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oneclickvirt/ecs/internal/report"
+)
+
+func TestParseCron(t *testing.T) {
+	base := time.Date(2024, 2, 28, 13, 7, 30, 0, time.UTC)
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2024, 2, 28, 13, 15, 0, 0, time.UTC)},
+		{"0 */6 * * *", time.Date(2024, 2, 28, 18, 0, 0, 0, time.UTC)},
+		{"30 2 29 2 *", time.Date(2024, 2, 29, 2, 30, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2024, 2, 29, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 * 7", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		sched, err := ParseCron(c.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q): %v", c.expr, err)
+		}
+		if got := sched.Next(base); !got.Equal(c.want) {
+			t.Errorf("ParseCron(%q).Next = %v, want %v", c.expr, got, c.want)
+		}
+	}
+	for _, expr := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) succeeded", expr)
+		}
+	}
+}
+
+func cpuReport(score int) *report.Report {
+	rep := report.New("v0.0.0", "en", time.Now())
+	text := fmt.Sprintf("1 Thread(s) Test: %d\n", score)
+	section := report.NewSection("cpu", "sysbench", text)
+	section.Results = report.ParseOutput("cpu", text)
+	rep.Add(section)
+	return rep
+}
+
+func TestCheckBaseline(t *testing.T) {
+	previous := []*report.Report{cpuReport(1000), cpuReport(980), cpuReport(1020)}
+	if alerts := CheckBaseline(cpuReport(900), previous, 20); len(alerts) != 0 {
+		t.Fatalf("unexpected alerts %v", alerts)
+	}
+	alerts := CheckBaseline(cpuReport(700), previous, 20)
+	if len(alerts) != 1 || alerts[0].Baseline != 1000 || alerts[0].Change != -30 {
+		t.Fatalf("alerts = %v", alerts)
+	}
+	if alerts := CheckBaseline(cpuReport(100), previous[:2], 20); len(alerts) != 0 {
+		t.Fatalf("alerted without enough history: %v", alerts)
+	}
+}
+
+func TestMainRejectsShortBaseline(t *testing.T) {
+	err := Main([]string{"-every", "1h", "-baseline", "2"}, "test")
+	if err == nil || !strings.Contains(err.Error(), "-baseline") {
+		t.Fatalf("Main = %v", err)
+	}
+}
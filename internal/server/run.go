@@ -0,0 +1,163 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/oneclickvirt/ecs/internal/report"
+)
+
+// Run states
+const (
+	StatusQueued   = "queued"
+	StatusRunning  = "running"
+	StatusFinished = "finished"
+	StatusCanceled = "canceled"
+	StatusFailed   = "failed"
+)
+
+// RunRequest is the body accepted by POST /runs
+type RunRequest struct {
+	Preset   string                 `json:"preset,omitempty"`
+	Language string                 `json:"language,omitempty"`
+	Flags    map[string]interface{} `json:"flags,omitempty"`
+}
+
+// RunStatus is the representation returned by GET /runs/{id}
+type RunStatus struct {
+	ID         string         `json:"id"`
+	Status     string         `json:"status"`
+	Request    RunRequest     `json:"request"`
+	CreatedAt  time.Time      `json:"created_at"`
+	StartedAt  *time.Time     `json:"started_at,omitempty"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty"`
+	Error      string         `json:"error,omitempty"`
+	Result     *report.Report `json:"result,omitempty"`
+}
+
+// Run is one benchmark submitted through the API
+type Run struct {
+	id         string
+	request    RunRequest
+	args       []string
+	createdAt  time.Time
+	startedAt  time.Time
+	finishedAt time.Time
+	status     string
+	err        string
+	result     *report.Report
+	ctx        context.Context
+	cancel     context.CancelFunc
+	log        []byte
+	changed    chan struct{} // 日志追加或状态变化时关闭并替换
+	mu         sync.Mutex
+}
+
+func newRun(request RunRequest, args []string) *Run {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Run{
+		id:        newRunID(),
+		request:   request,
+		args:      args,
+		createdAt: time.Now(),
+		status:    StatusQueued,
+		ctx:       ctx,
+		cancel:    cancel,
+		changed:   make(chan struct{}),
+	}
+}
+
+func newRunID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
+
+// notify wakes up log followers, the caller must hold r.mu
+func (r *Run) notify() {
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// Write appends benchmark output to the run log
+func (r *Run) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.log = append(r.log, p...)
+	r.notify()
+	return len(p), nil
+}
+
+// SetReport attaches the structured result, which may still be filling while the run is active
+func (r *Run) SetReport(rep *report.Report) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.result = rep
+}
+
+func (r *Run) setStatus(status, errText string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+	r.err = errText
+	switch status {
+	case StatusRunning:
+		r.startedAt = time.Now()
+	case StatusFinished, StatusCanceled, StatusFailed:
+		r.finishedAt = time.Now()
+	}
+	r.notify()
+}
+
+// done reports whether the run reached a final state, the caller must hold r.mu
+func (r *Run) done() bool {
+	return r.status == StatusFinished || r.status == StatusCanceled || r.status == StatusFailed
+}
+
+// finished returns when the run reached a final state, ok is false while it is queued or running
+func (r *Run) finished() (time.Time, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.finishedAt, r.done()
+}
+
+// logSince returns log bytes after offset, a channel closed on the next change and whether the run is over
+func (r *Run) logSince(offset int) ([]byte, <-chan struct{}, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var chunk []byte
+	if offset < len(r.log) {
+		chunk = append(chunk, r.log[offset:]...)
+	}
+	return chunk, r.changed, r.done()
+}
+
+// Status returns a snapshot of the run for the API
+func (r *Run) Status() RunStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	status := RunStatus{
+		ID:        r.id,
+		Status:    r.status,
+		Request:   r.request,
+		CreatedAt: r.createdAt,
+		Error:     r.err,
+	}
+	if !r.startedAt.IsZero() {
+		startedAt := r.startedAt
+		status.StartedAt = &startedAt
+	}
+	if !r.finishedAt.IsZero() {
+		finishedAt := r.finishedAt
+		status.FinishedAt = &finishedAt
+	}
+	if r.result != nil {
+		status.Result = r.result.Clone()
+	}
+	return status
+}
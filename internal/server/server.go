@@ -0,0 +1,435 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/oneclickvirt/ecs/internal/listenaddr"
+	"github.com/oneclickvirt/ecs/internal/menu"
+	"github.com/oneclickvirt/ecs/internal/params"
+	"github.com/oneclickvirt/ecs/internal/report"
+	"github.com/oneclickvirt/ecs/internal/runner"
+	"github.com/oneclickvirt/ecs/utils"
+)
+
+const (
+	// finishedRunTTL and maxFinishedRuns bound how long a long-running server keeps finished runs queryable
+	finishedRunTTL  = 24 * time.Hour
+	maxFinishedRuns = 100
+)
+
+// Executor runs one benchmark until it finishes or ctx is canceled,
+// it returns context.Canceled only when the cancellation stopped the benchmark early
+type Executor func(ctx context.Context, run *Run) error
+
+// Server exposes benchmark runs over a REST API
+type Server struct {
+	version string
+	token   string
+	execute Executor
+	runs    map[string]*Run
+	slot    chan struct{} // 同一时间只允许一个测试运行，避免相互干扰
+	mu      sync.Mutex
+}
+
+// New creates a server, execute defaults to running the full goecs test flow
+func New(version, token string, execute Executor) *Server {
+	s := &Server{
+		version: version,
+		token:   token,
+		execute: execute,
+		runs:    make(map[string]*Run),
+		slot:    make(chan struct{}, 1),
+	}
+	if s.execute == nil {
+		s.execute = s.runBenchmark
+	}
+	return s
+}
+
+// Main parses the serve subcommand flags and serves until interrupted
+func Main(args []string, version string) error {
+	serveFlag := flag.NewFlagSet("serve", flag.ContinueOnError)
+	listen := serveFlag.String("listen", "127.0.0.1:8080", "Address to listen on, e.g., -listen 0.0.0.0:8080 (requires -token)")
+	token := serveFlag.String("token", "", "Require 'Authorization: Bearer <token>' on every request")
+	if err := serveFlag.Parse(args); err != nil {
+		return err
+	}
+	// 接口可以启动任意测试，监听非回环地址时必须设置令牌
+	if *token == "" && !listenaddr.IsLoopback(*listen) {
+		return errors.New("-token is required when -listen is not a loopback address")
+	}
+	s := New(version, *token, nil)
+	httpServer := &http.Server{
+		Addr:              *listen,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errChan := make(chan error, 1)
+	go func() {
+		fmt.Fprintf(os.Stderr, "goecs %s serving on %s\n", version, *listen)
+		errChan <- httpServer.ListenAndServe()
+	}()
+	select {
+	case err := <-errChan:
+		return err
+	case <-ctx.Done():
+	}
+	s.cancelAll()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return httpServer.Shutdown(shutdownCtx)
+}
+
+// Handler returns the HTTP routes of the API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /runs", s.handleCreate)
+	mux.HandleFunc("GET /runs", s.handleList)
+	mux.HandleFunc("GET /runs/{id}", s.handleGet)
+	mux.HandleFunc("GET /runs/{id}/log", s.handleLog)
+	mux.HandleFunc("DELETE /runs/{id}", s.handleCancel)
+	return s.authorize(mux)
+}
+
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given := r.Header.Get("Authorization")
+		if s.token != "" && subtle.ConstantTimeCompare([]byte(given), []byte("Bearer "+s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid or missing token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var request RunRequest
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := json.Unmarshal(body, &request); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+			return
+		}
+	}
+	if request.Preset != "" && !menu.IsPreset(request.Preset) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown preset %q", request.Preset))
+		return
+	}
+	args := BuildArgs(request)
+	// 先用独立的配置校验参数，避免排队后才发现错误
+	config := params.NewConfig(s.version)
+	config.GoecsFlag.SetOutput(io.Discard)
+	if err := config.ParseFlags(args); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid flags: "+err.Error())
+		return
+	}
+	run := newRun(request, args)
+	s.mu.Lock()
+	s.prune(time.Now())
+	s.runs[run.id] = run
+	s.mu.Unlock()
+	go s.process(run)
+	w.Header().Set("Location", "/runs/"+run.id)
+	writeJSON(w, http.StatusAccepted, run.Status())
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	statuses := make([]RunStatus, 0, len(s.runs))
+	for _, run := range s.runs {
+		status := run.Status()
+		status.Result = nil
+		statuses = append(statuses, status)
+	}
+	s.mu.Unlock()
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].CreatedAt.Before(statuses[j].CreatedAt)
+	})
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, run.Status())
+}
+
+// handleCancel cancels a run, a queued run never starts and a running one stops after its current section
+func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	run.cancel()
+	writeJSON(w, http.StatusAccepted, run.Status())
+}
+
+// handleLog streams the console output of a run as server-sent events, one event per line
+func (s *Server) handleLog(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	offset := 0
+	var pending []byte
+	for {
+		chunk, changed, done := run.logSince(offset)
+		offset += len(chunk)
+		pending = append(pending, chunk...)
+		for {
+			index := bytes.IndexByte(pending, '\n')
+			if index < 0 {
+				break
+			}
+			writeEvent(w, "", string(pending[:index]))
+			pending = pending[index+1:]
+		}
+		if done {
+			if len(pending) > 0 {
+				writeEvent(w, "", string(pending))
+			}
+			writeEvent(w, "end", run.Status().Status)
+			flusher.Flush()
+			return
+		}
+		flusher.Flush()
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *Server) lookup(w http.ResponseWriter, r *http.Request) (*Run, bool) {
+	s.mu.Lock()
+	run, ok := s.runs[r.PathValue("id")]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "run not found")
+	}
+	return run, ok
+}
+
+// process waits for the run slot and executes the run
+func (s *Server) process(run *Run) {
+	defer run.cancel()
+	select {
+	case s.slot <- struct{}{}:
+	case <-run.ctx.Done():
+		run.setStatus(StatusCanceled, "")
+		return
+	}
+	defer func() {
+		<-s.slot
+	}()
+	if run.ctx.Err() != nil {
+		run.setStatus(StatusCanceled, "")
+		return
+	}
+	run.setStatus(StatusRunning, "")
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return s.execute(run.ctx, run)
+	}()
+	// 取消时正在运行的部分会继续完成，全部完成后才取消的测试仍视为完成
+	switch {
+	case errors.Is(err, context.Canceled):
+		run.setStatus(StatusCanceled, "")
+	case err != nil:
+		run.setStatus(StatusFailed, err.Error())
+	default:
+		run.setStatus(StatusFinished, "")
+	}
+}
+
+// prune drops finished runs older than finishedRunTTL and the oldest ones beyond maxFinishedRuns,
+// queued and running runs are always kept, the caller must hold s.mu
+func (s *Server) prune(now time.Time) {
+	type finishedRun struct {
+		id string
+		at time.Time
+	}
+	var finished []finishedRun
+	for id, run := range s.runs {
+		at, ok := run.finished()
+		switch {
+		case !ok:
+		case now.Sub(at) > finishedRunTTL:
+			delete(s.runs, id)
+		default:
+			finished = append(finished, finishedRun{id, at})
+		}
+	}
+	if len(finished) <= maxFinishedRuns {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].at.Before(finished[j].at)
+	})
+	for _, run := range finished[:len(finished)-maxFinishedRuns] {
+		delete(s.runs, run.id)
+	}
+}
+
+func (s *Server) cancelAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, run := range s.runs {
+		run.cancel()
+	}
+}
+
+// runBenchmark is the default executor, it mirrors the non-interactive command line flow
+func (s *Server) runBenchmark(ctx context.Context, run *Run) error {
+	config := params.NewConfig(s.version)
+	config.GoecsFlag.SetOutput(io.Discard)
+	if err := config.ParseFlags(run.args); err != nil {
+		return err
+	}
+	preCheck := utils.CheckPublicAccess(3 * time.Second)
+	if run.request.Preset != "" {
+		if err := menu.ApplyPreset(run.request.Preset, preCheck, config); err != nil {
+			return err
+		}
+	} else {
+		config.MenuMode = false
+		config.OnlyIpInfoCheck = true
+		config.ValidateParams()
+	}
+	config.HandleLanguageSpecificSettings()
+	if !preCheck.Connected {
+		config.EnableUpload = false
+	}
+	restore, err := redirectStdout(run)
+	if err != nil {
+		return err
+	}
+	defer restore()
+	var (
+		output      string
+		outputMutex sync.Mutex
+	)
+	startTime := time.Now()
+	rep := report.New(config.EcsVersion, config.Language, startTime)
+	run.SetReport(rep)
+	stopped := runner.RunTests(ctx, preCheck, config, rep, &output, "", startTime, &outputMutex)
+	rep.Finish()
+	if stopped {
+		return context.Canceled
+	}
+	if ctx.Err() != nil {
+		// 所有部分已完成，只是不再上传
+		return nil
+	}
+	if config.ReportTo != "" {
+		runner.HandleReportResults(config, output, rep)
+	} else if preCheck.Connected {
+		runner.HandleUploadResults(config, output)
+	}
+	config.Finish = true
+	return nil
+}
+
+// redirectStdout copies everything printed to stdout into w as well, until the returned func is called
+func redirectStdout(w io.Writer) (func(), error) {
+	pipeR, pipeW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	oldStdout := os.Stdout
+	os.Stdout = pipeW
+	done := make(chan struct{})
+	go func() {
+		io.Copy(io.MultiWriter(oldStdout, w), pipeR)
+		close(done)
+	}()
+	return func() {
+		os.Stdout = oldStdout
+		pipeW.Close()
+		<-done
+		pipeR.Close()
+	}, nil
+}
+
+// BuildArgs converts a run request into command line arguments understood by params.Config
+func BuildArgs(request RunRequest) []string {
+	var args []string
+	if request.Language != "" {
+		args = append(args, "-l="+request.Language)
+	}
+	names := make([]string, 0, len(request.Flags))
+	for name := range request.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := fmt.Sprint(request.Flags[name])
+		// JSON 数字解码为 float64，%v 会把 1000000 写成 1e+06，flag 包无法解析
+		if number, ok := request.Flags[name].(float64); ok {
+			value = strconv.FormatFloat(number, 'f', -1, 64)
+		}
+		args = append(args, "-"+strings.TrimLeft(name, "-")+"="+value)
+	}
+	return args
+}
+
+func writeEvent(w io.Writer, event, data string) {
+	if event != "" {
+		fmt.Fprintf(w, "event: %s\n", event)
+	}
+	data = strings.TrimRight(utils.StripANSI(data), "\r")
+	if index := strings.LastIndex(data, "\r"); index >= 0 {
+		data = data[index+1:]
+	}
+	fmt.Fprintf(w, "data: %s\n\n", data)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, map[string]string{"error": message})
+}
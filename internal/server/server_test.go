@@ -0,0 +1,195 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func postRun(t *testing.T, url, body string) RunStatus {
+	t.Helper()
+	resp, err := http.Post(url+"/runs", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("POST /runs status = %d", resp.StatusCode)
+	}
+	var status RunStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	return status
+}
+
+func waitStatus(t *testing.T, url, id, want string) RunStatus {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err := http.Get(url + "/runs/" + id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var status RunStatus
+		json.NewDecoder(resp.Body).Decode(&status)
+		resp.Body.Close()
+		if status.Status == want {
+			return status
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("run %s did not reach status %s", id, want)
+	return RunStatus{}
+}
+
+func TestRunLifecycle(t *testing.T) {
+	release := make(chan struct{})
+	calls := 0
+	s := New("test", "", func(ctx context.Context, run *Run) error {
+		fmt.Fprintf(run, "args=%s\n", strings.Join(run.args, " "))
+		calls++
+		switch calls {
+		case 1:
+			<-release
+		case 2:
+			// 在取消后提前结束
+			<-ctx.Done()
+			return ctx.Err()
+		default:
+			// 取消时已完成所有工作
+			<-ctx.Done()
+		}
+		fmt.Fprintln(run, "done")
+		return nil
+	})
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	first := postRun(t, ts.URL, `{"preset":"minimal","language":"en","flags":{"spnum":3}}`)
+	second := postRun(t, ts.URL, `{}`)
+	waitStatus(t, ts.URL, first.ID, StatusRunning)
+	// 同一时间只运行一个测试
+	waitStatus(t, ts.URL, second.ID, StatusQueued)
+
+	resp, err := http.Get(ts.URL + "/runs/" + first.ID + "/log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	close(release)
+	var events []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			events = append(events, line)
+		}
+	}
+	got := strings.Join(events, "|")
+	want := "data: args=-l=en -spnum=3|data: done|event: end|data: finished"
+	if got != want {
+		t.Fatalf("log events = %q, want %q", got, want)
+	}
+
+	waitStatus(t, ts.URL, second.ID, StatusRunning)
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/runs/"+second.ID, nil)
+	if _, err := http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, ts.URL, second.ID, StatusCanceled)
+
+	third := postRun(t, ts.URL, `{}`)
+	waitStatus(t, ts.URL, third.ID, StatusRunning)
+	req, _ = http.NewRequest(http.MethodDelete, ts.URL+"/runs/"+third.ID, nil)
+	if _, err := http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, ts.URL, third.ID, StatusFinished)
+}
+
+func TestCreateValidation(t *testing.T) {
+	s := New("test", "secret", func(ctx context.Context, run *Run) error { return nil })
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+	cases := []struct {
+		body  string
+		token string
+		code  int
+	}{
+		{`{}`, "", http.StatusUnauthorized},
+		{`{"preset":"nope"}`, "secret", http.StatusBadRequest},
+		{`{"flags":{"no-such-flag":true}}`, "secret", http.StatusBadRequest},
+		{`{"flags":{"cpu":false}}`, "secret", http.StatusAccepted},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/runs", strings.NewReader(c.body))
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != c.code {
+			t.Errorf("POST %s with token %q: status = %d, want %d", c.body, c.token, resp.StatusCode, c.code)
+		}
+	}
+}
+
+func TestBuildArgs(t *testing.T) {
+	var request RunRequest
+	body := `{"language":"en","flags":{"spnum":3,"size":1000000,"ratio":0.5,"cpu":false,"-diskp":"/data"}}`
+	if err := json.Unmarshal([]byte(body), &request); err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(BuildArgs(request), " ")
+	want := "-l=en -diskp=/data -cpu=false -ratio=0.5 -size=1000000 -spnum=3"
+	if got != want {
+		t.Fatalf("BuildArgs = %q, want %q", got, want)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	s := New("test", "", func(ctx context.Context, run *Run) error { return nil })
+	now := time.Now()
+	running := newRun(RunRequest{}, nil)
+	running.setStatus(StatusRunning, "")
+	running.startedAt = now.Add(-2 * finishedRunTTL)
+	s.runs[running.id] = running
+	expired := newRun(RunRequest{}, nil)
+	expired.setStatus(StatusFinished, "")
+	expired.finishedAt = now.Add(-finishedRunTTL - time.Minute)
+	s.runs[expired.id] = expired
+	var oldest *Run
+	for i := 0; i < maxFinishedRuns+1; i++ {
+		run := newRun(RunRequest{}, nil)
+		run.setStatus(StatusFailed, "")
+		run.finishedAt = now.Add(-time.Duration(maxFinishedRuns-i) * time.Minute)
+		if i == 0 {
+			oldest = run
+		}
+		s.runs[run.id] = run
+	}
+	s.prune(now)
+	if len(s.runs) != maxFinishedRuns+1 {
+		t.Fatalf("%d runs kept, want %d", len(s.runs), maxFinishedRuns+1)
+	}
+	for _, run := range []*Run{expired, oldest} {
+		if _, ok := s.runs[run.id]; ok {
+			t.Errorf("run finished at %v was not pruned", run.finishedAt)
+		}
+	}
+	if _, ok := s.runs[running.id]; !ok {
+		t.Error("running run was pruned")
+	}
+}
@@ -0,0 +1,167 @@
+// Package speedlist runs the speed test against a user supplied list of speedtest.net server IDs or custom endpoints
+package speedlist
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oneclickvirt/ecs/internal/flaglist"
+	"github.com/oneclickvirt/ecs/internal/speedtable"
+	"github.com/showwin/speedtest-go/speedtest"
+	"github.com/showwin/speedtest-go/speedtest/transport"
+)
+
+// Entry is one server of the list, Target is a speedtest.net server ID or the URL of a speedtest endpoint
+type Entry struct {
+	Target string
+	Label  string
+}
+
+// IsID reports whether the target is a speedtest.net server ID
+func (e Entry) IsID() bool {
+	_, err := strconv.Atoi(e.Target)
+	return err == nil
+}
+
+// ParseList parses the -speed-server value, IDs or URLs separated by commas, each optionally followed by "=label"
+func ParseList(value string) []Entry {
+	var entries []Entry
+	for _, item := range flaglist.Split(value) {
+		entries = append(entries, Entry{Target: item.Value, Label: item.Label})
+	}
+	return entries
+}
+
+// ParseFile parses a server list file, one ID or URL per line followed by an optional label,
+// blank lines and lines starting with # are ignored
+func ParseFile(text string) []Entry {
+	var entries []Entry
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		entries = append(entries, Entry{Target: fields[0], Label: strings.Join(fields[1:], " ")})
+	}
+	return entries
+}
+
+// Load reads the list file given by -speed-servers and appends the -speed-server entries, dropping duplicates
+func Load(file, list string) ([]Entry, error) {
+	var entries []Entry
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		entries = ParseFile(string(data))
+	}
+	entries = append(entries, ParseList(list)...)
+	seen := make(map[string]bool)
+	unique := entries[:0]
+	for _, entry := range entries {
+		if !seen[entry.Target] {
+			seen[entry.Target] = true
+			unique = append(unique, entry)
+		}
+	}
+	return unique, nil
+}
+
+var client = speedtest.New(speedtest.WithUserConfig(
+	&speedtest.UserConfig{
+		UserAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/99.0.4844.74 Safari/537.36",
+		PingMode:       speedtest.TCP,
+		MaxConnections: 8,
+	}))
+
+// resolve fetches the server of an entry and fills in the label when none was given
+func resolve(entry *Entry) (*speedtest.Server, error) {
+	if entry.IsID() {
+		server, err := client.FetchServerByID(entry.Target)
+		if err != nil {
+			return nil, err
+		}
+		if entry.Label == "" {
+			entry.Label = strings.TrimSpace(server.Sponsor + " " + server.Name)
+		}
+		return server, nil
+	}
+	target := entry.Target
+	if !strings.Contains(target, "://") {
+		target = "http://" + target
+	}
+	server, err := client.CustomServer(target)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Label == "" {
+		entry.Label = server.Host
+	}
+	return server, nil
+}
+
+// Result is the measurement of one entry, Error is set when the server could not be tested
+type Result struct {
+	Label      string  `json:"label"`
+	Target     string  `json:"target"`
+	Upload     float64 `json:"upload_mbps"`
+	Download   float64 `json:"download_mbps"`
+	Latency    float64 `json:"latency_ms"`
+	PacketLoss string  `json:"packet_loss,omitempty"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// Run tests every entry in list order and prints one row per server in the layout of the built-in speed test,
+// servers that cannot be reached are printed with N/A so the label still shows up
+func Run(entries []Entry) []Result {
+	analyzer := speedtest.NewPacketLossAnalyzer(nil)
+	results := make([]Result, 0, len(entries))
+	for _, entry := range entries {
+		server, err := resolve(&entry)
+		if err == nil {
+			// 测速前先测延迟，下载和上传测试依赖延迟结果选择并发数
+			if err = server.PingTest(nil); err == nil {
+				if err = server.DownloadTest(); err == nil {
+					err = server.UploadTest()
+				}
+			}
+		}
+		if entry.Label == "" {
+			entry.Label = entry.Target
+		}
+		if err != nil {
+			fmt.Print(speedtable.Row(entry.Label, "N/A", "N/A", "N/A", "N/A"))
+			results = append(results, Result{Label: entry.Label, Target: entry.Target, Error: err.Error()})
+			if server != nil {
+				server.Context.Reset()
+			}
+			continue
+		}
+		// 自定义端点通常不支持丢包探测
+		packetLoss := "N/A"
+		if entry.IsID() {
+			analyzer.Run(server.Host, func(loss *transport.PLoss) {
+				packetLoss = strings.ReplaceAll(loss.String(), "Packet Loss: ", "")
+			})
+		}
+		fmt.Print(speedtable.Row(entry.Label, fmt.Sprintf("%.2f Mbps", server.ULSpeed.Mbps()),
+			fmt.Sprintf("%.2f Mbps", server.DLSpeed.Mbps()), server.Latency.String(), packetLoss))
+		results = append(results, Result{
+			Label:      entry.Label,
+			Target:     entry.Target,
+			Upload:     server.ULSpeed.Mbps(),
+			Download:   server.DLSpeed.Mbps(),
+			Latency:    float64(server.Latency) / float64(time.Millisecond),
+			PacketLoss: packetLoss,
+		})
+		server.Context.Reset()
+	}
+	return results
+}
@@ -0,0 +1,31 @@
+package speedlist
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	list := ParseList(" 5145 ,speed.example.com:8080=Our DC,,http://speed.example.com/upload.php?x=1")
+	if fmt.Sprint(list) != "[{5145 } {speed.example.com:8080 Our DC} {http://speed.example.com/upload.php?x=1 }]" || !list[0].IsID() || list[1].IsID() {
+		t.Fatalf("ParseList = %v", list)
+	}
+	file := filepath.Join(t.TempDir(), "servers.txt")
+	content := "# 自建节点\n\n3633\t上海电信\nhttps://speed.example.com/speedtest/upload.php  Example  Frankfurt\n5145\n"
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := Load(file, "5145,27594")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[{3633 上海电信} {https://speed.example.com/speedtest/upload.php Example Frankfurt} {5145 } {27594 }]"
+	if got := fmt.Sprint(entries); got != want {
+		t.Fatalf("Load = %s, want %s", got, want)
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing"), ""); err == nil {
+		t.Fatal("Load of a missing file succeeded")
+	}
+}
@@ -0,0 +1,26 @@
+// Package speedtable lays out the rows of the custom speed tests in the columns of the built-in speed test table
+package speedtable
+
+import (
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// ColumnWidth matches the column width of the speed test table header
+const ColumnWidth = 16
+
+// cell pads text to one column, counting wide characters such as Chinese labels as two
+func cell(text string) string {
+	return runewidth.FillRight(text, ColumnWidth)
+}
+
+// Row renders one line of the table: label, upload, download, latency and packet loss
+func Row(cells ...string) string {
+	var builder strings.Builder
+	for _, text := range cells {
+		builder.WriteString(cell(text))
+	}
+	builder.WriteString("\n")
+	return builder.String()
+}
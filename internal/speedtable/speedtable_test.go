@@ -0,0 +1,19 @@
+package speedtable
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mattn/go-runewidth"
+)
+
+func TestCell(t *testing.T) {
+	for _, text := range []string{"Example", "上海电信", "123.45 Mbps"} {
+		if got := cell(text); runewidth.StringWidth(got) != ColumnWidth {
+			t.Errorf("cell(%q) = %q", text, got)
+		}
+	}
+	if got := Row("上海电信", "N/A"); got != "上海电信"+strings.Repeat(" ", 8)+"N/A"+strings.Repeat(" ", 13)+"\n" {
+		t.Fatalf("Row = %q", got)
+	}
+}
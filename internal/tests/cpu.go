@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/oneclickvirt/cputest/cpu"
+	"github.com/oneclickvirt/ecs/internal/cpubench"
 )
 
 func CpuTest(language, testMethod, testThread string) (realTestMethod, res string) {
@@ -18,7 +19,14 @@ func CpuTest(language, testMethod, testThread string) (realTestMethod, res strin
 		}
 	}()
 	
-	if runtime.GOOS == "windows" {
+	if testThread == "scaling" {
+		// 扩展性测试需要逐级改变线程数，统一使用内置测试
+		realTestMethod = "builtin"
+		res = cpubench.ScalingTest(language, cpubench.DefaultDuration)
+	} else if testMethod == "builtin" {
+		realTestMethod = "builtin"
+		res = cpubench.Test(language, testThread, cpubench.DefaultDuration)
+	} else if runtime.GOOS == "windows" {
 		if testMethod != "winsat" && testMethod != "" {
 			// res = "Detected host is Windows, using Winsat for testing.\n"
 			realTestMethod = "winsat"
@@ -48,6 +56,11 @@ func CpuTest(language, testMethod, testThread string) (realTestMethod, res strin
 			res = "Invalid test method specified.\n"
 			realTestMethod = "null"
 		}
+		// sysbench 与 geekbench 均不可用时（离线或 geekbench 不支持的架构）使用内置测试
+		if strings.TrimSpace(res) == "" {
+			realTestMethod = "builtin"
+			res = cpubench.Test(language, testThread, cpubench.DefaultDuration)
+		}
 	}
 	if !strings.Contains(res, "\n") && res != "" {
 		res += "\n"
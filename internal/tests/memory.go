@@ -9,7 +9,16 @@ import (
 	"github.com/oneclickvirt/memorytest/memory"
 )
 
-func MemoryTest(language, testMethod string) (realTestMethod, res string) {
+// ddSkipped explains that no method could run once the memory guard ruled out dd
+func ddSkipped(language string) string {
+	if language == "zh" {
+		return "sysbench 不可用，可用内存不足以运行 dd 测试，已跳过\n"
+	}
+	return "sysbench is unavailable and there is not enough memory for the dd test, skipped\n"
+}
+
+// MemoryTest runs the bandwidth test, allowDD is false when the memory guard rules out the dd fallback
+func MemoryTest(language, testMethod string, allowDD bool) (realTestMethod, res string) {
 	defer func() {
 		if r := recover(); r != nil {
 			fmt.Fprintf(os.Stderr, "[WARN] MemoryTest panic: %v\n", r)
@@ -49,7 +58,14 @@ func MemoryTest(language, testMethod string) (realTestMethod, res string) {
 		switch testMethod {
 		case "stream":
 			res = memory.StreamTest(language)
-			if res == "" || strings.TrimSpace(res) == "" {
+			if (res == "" || strings.TrimSpace(res) == "") && !allowDD {
+				res = memory.SysBenchTest(language)
+				if res == "" || strings.TrimSpace(res) == "" {
+					realTestMethod, res = "skipped", ddSkipped(language)
+				} else {
+					realTestMethod = "sysbench"
+				}
+			} else if res == "" || strings.TrimSpace(res) == "" {
 				res += memory.DDTest(language)
 				realTestMethod = "dd"
 			} else {
@@ -60,7 +76,9 @@ func MemoryTest(language, testMethod string) (realTestMethod, res string) {
 			realTestMethod = "dd"
 		case "sysbench":
 			res = memory.SysBenchTest(language)
-			if res == "" || strings.TrimSpace(res) == "" {
+			if (res == "" || strings.TrimSpace(res) == "") && !allowDD {
+				realTestMethod, res = "skipped", ddSkipped(language)
+			} else if res == "" || strings.TrimSpace(res) == "" {
 				res += memory.DDTest(language)
 				realTestMethod = "dd"
 			} else {
@@ -69,10 +87,15 @@ func MemoryTest(language, testMethod string) (realTestMethod, res string) {
 		case "auto":
 			res = memory.StreamTest(language)
 			if res == "" || strings.TrimSpace(res) == "" {
-				res = memory.DDTest(language)
+				// 内存不足以容纳 dd 的 tmpfs 文件时直接尝试 sysbench
+				if allowDD {
+					res = memory.DDTest(language)
+				}
 				if res == "" || strings.TrimSpace(res) == "" {
 					res = memory.SysBenchTest(language)
-					if res == "" || strings.TrimSpace(res) == "" {
+					if (res == "" || strings.TrimSpace(res) == "") && !allowDD {
+						realTestMethod, res = "skipped", ddSkipped(language)
+					} else if res == "" || strings.TrimSpace(res) == "" {
 						realTestMethod = ""
 					} else {
 						realTestMethod = "sysbench"
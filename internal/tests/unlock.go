@@ -9,7 +9,8 @@ import (
 	"github.com/oneclickvirt/defaultset"
 )
 
-func MediaTest(language string) string {
+// MediaTest runs the unlock tests over every stack the host has, stackType is the StackType of the network check
+func MediaTest(language, stackType string) string {
 	defer func() {
 		if r := recover(); r != nil {
 			fmt.Fprintf(os.Stderr, "[WARN] MediaTest panic: %v\n", r)
@@ -21,15 +22,14 @@ func MediaTest(language string) string {
 	if !readStatus {
 		return ""
 	}
-	if executor.IPV4 {
+	// 双栈时两个协议栈都测试，仅有 IPv6 时跳过 IPv4
+	if executor.IPV4 && stackType != "IPv6" {
 		res += defaultset.Blue("IPV4:") + "\n"
 		res += executor.RunTests(utils.Ipv4HttpClient, "ipv4", language, false)
-		return res
 	}
-	if executor.IPV6 {
+	if executor.IPV6 && (stackType == "DualStack" || stackType == "IPv6") {
 		res += defaultset.Blue("IPV6:") + "\n"
 		res += executor.RunTests(utils.Ipv6HttpClient, "ipv6", language, false)
-		return res
 	}
-	return ""
+	return res
 }
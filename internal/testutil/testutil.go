@@ -0,0 +1,28 @@
+// Package testutil holds the fixtures shared by the tests of packages that read sysfs and procfs
+package testutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// WriteFile creates path and its parent directories with content followed by a newline, as sysfs files end
+func WriteFile(t testing.TB, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Root points *root, such as the sysRoot of a package, at a new temporary directory until the test ends
+func Root(t testing.TB, root *string) string {
+	t.Helper()
+	old := *root
+	*root = t.TempDir()
+	t.Cleanup(func() { *root = old })
+	return *root
+}
@@ -308,6 +308,14 @@ func UploadText(absPath string) (string, string, error) {
 	return httpURL, httpsURL, nil
 }
 
+// 匹配 ANSI 转义序列
+var ansiRegex = regexp.MustCompile("\x1B\\[[0-9;]+[a-zA-Z]")
+
+// StripANSI 移除文本中的 ANSI 转义序列
+func StripANSI(text string) string {
+	return ansiRegex.ReplaceAllString(text, "")
+}
+
 // ProcessAndUpload 创建结果文件并上传文件
 func ProcessAndUpload(output string, filePath string, enableUplaod bool) (string, string) {
 	// 使用 defer 来处理 panic
@@ -334,10 +342,8 @@ func ProcessAndUpload(output string, filePath string, enableUplaod bool) (string
 		return "", ""
 	}
 	defer file.Close()
-	// 匹配 ANSI 转义序列
-	ansiRegex := regexp.MustCompile("\x1B\\[[0-9;]+[a-zA-Z]")
 	// 移除 ANSI 转义序列
-	cleanedOutput := ansiRegex.ReplaceAllString(output, "")
+	cleanedOutput := StripANSI(output)
 	// 使用 bufio.Writer 提高写入效率
 	writer := bufio.NewWriter(file)
 	_, err = writer.WriteString(cleanedOutput)